@@ -0,0 +1,273 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/boggo/neural"
+)
+
+// Runs the experiment with an age-layered population structure (ALPS, Hornby
+// 2006). Each layer is a population of its own, speciated and rolled
+// independently, holding the organisms whose ALPS age, the generations since
+// their oldest genetic material was seeded, fits the layer. Organisms too old
+// for their layer move up to the next one after each roll, and on schedule
+// the bottom layer is promoted whole and reseeded with fresh clones of the
+// initial genome. The layers are evaluated, reported and archived together
+// as one population, each species recording its layer, and the best organism
+// is tracked across them all. Neither the phased search, PreEval nor
+// deferred speciation is supported.
+func trainALPS(ctx context.Context, settings *Settings, n int, dcode Decoder, popEval PopEval, orgEval OrgEval, arch Archiver, rep Reporter) (best *Organism, population *Population, err error) {
+	if err = settings.Validate(); err != nil {
+		return
+	}
+	settings.base = settings.snapshot()
+	if settings.PreEval != nil || settings.deferSpeciation() {
+		err = errors.New("ALPS supports neither PreEval nor deferred speciation")
+		return
+	}
+	if settings.Seed != 0 {
+		settings.rand().seed(settings.Seed)
+	}
+
+	// Restore the layers or begin new ones
+	if population, err = restorePopulation(settings, arch); err != nil {
+		return
+	}
+	inno := settings.Innovations
+	if inno == nil {
+		inno = newInnovation(population)
+		defer inno.close()
+	} else {
+		inno.persistent = true
+		inno.skipPast(population)
+	}
+	var layers []*Population
+	var seed *Genome
+	if population != nil {
+		layers = splitLayers(settings, population)
+		seed = restoredSeed(settings, inno, population)
+	}
+
+	defer settings.saveRates()()
+	total := 0 // Evaluations made in this run
+	for i := 0; i < n; i++ {
+
+		// Stop between generations if the context is done
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		start := time.Now()
+		prev := population
+		gen := 1
+		if layers != nil {
+			gen = layers[0].Generation + 1
+		}
+		settings.applySchedules(gen, total)
+
+		// Seed the bottom layer or roll them all
+		if layers == nil {
+			if seed, err = initialGenome(settings, inno); err != nil {
+				return
+			}
+			layers = make([]*Population, settings.ALPSLayers)
+			for j := range layers {
+				layers[j] = &Population{Generation: 1}
+			}
+			seedLayer(settings, inno, layers[0], seed)
+		} else if layers, err = rollLayers(settings, inno, layers, seed); err != nil {
+			return
+		}
+		population = joinLayers(layers)
+
+		// Evaluate every layer at once
+		orgs := population.Organisms()
+		if err = decode(dcode, orgs); err != nil {
+			return
+		}
+		prior := priorFitness(settings, orgs)
+		evals := len(orgs)
+		if settings.EvaluationBudget > 0 {
+			evals, err = evaluateBudgeted(ctx, settings, popEval, prev, population, orgEval, prior)
+		} else {
+			err = evaluateCounted(ctx, settings, popEval, population, orgEval, prior)
+		}
+		if err != nil {
+			return
+		}
+		for _, o := range orgs {
+			if err = checkFitness(settings, o); err != nil {
+				return
+			}
+		}
+		total += evals
+		if best, err = endGeneration(settings, population, best, time.Since(start), evals, i, n, arch, rep); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Returns the ALPS age of the organism in the given generation
+func (org *Organism) alpsAge(generation int) int {
+	return generation - org.Birth
+}
+
+// Returns true if the organism is too old for the ith layer. The top layer
+// has no limit.
+func (s *Settings) overAge(org *Organism, generation, layer int) bool {
+	return layer < s.ALPSLayers-1 && org.alpsAge(generation) >= s.ALPSAgeGap*(layer+1)
+}
+
+// Rolls each layer to the next generation, reseeding the bottom layer when it
+// is due and moving the organisms which have grown too old up a layer
+func rollLayers(settings *Settings, inno *innovation, layers []*Population, seed *Genome) (next []*Population, err error) {
+	gen := layers[0].Generation + 1
+	next = make([]*Population, len(layers))
+	for j, l := range layers {
+		if len(l.Organisms()) == 0 {
+			next[j] = &Population{Generation: gen} // Still waiting for organisms to age into it
+			continue
+		}
+		if next[j], err = rollPop(settings, inno, l); err != nil {
+			return
+		}
+	}
+
+	// Promote the bottom layer and reseed it
+	if (gen-1)%settings.ALPSAgeGap == 0 {
+		promoted := next[0].Organisms()
+		next[0] = &Population{Generation: gen, counters: next[0].counters} // Keeping the roll's tallies
+		if len(next) > 1 {
+			SpeciateInto(settings, inno, next[1], promoted)
+		}
+		seedLayer(settings, inno, next[0], seed)
+		settings.log().Info("alps bottom layer reseeded", "generation", gen, "promoted", len(promoted))
+	}
+
+	// Move the organisms too old for their layer
+	for j := 0; j < len(next)-1; j++ {
+		var movers OrganismSlice
+		for _, s := range next[j].Species {
+			keep := s.Orgs[:0]
+			for _, o := range s.Orgs {
+				if settings.overAge(o, gen, j) {
+					movers = append(movers, o)
+				} else {
+					keep = append(keep, o)
+				}
+			}
+			s.Orgs = keep
+		}
+		SpeciateInto(settings, inno, next[j], nil) // Prunes the species left empty
+		SpeciateInto(settings, inno, next[j+1], movers)
+	}
+	for j, l := range next {
+		for _, s := range l.Species {
+			s.Layer = j
+		}
+	}
+	return
+}
+
+// Fills the layer with fresh organisms cloned from the seed genome
+func seedLayer(settings *Settings, inno *innovation, layer *Population, seed *Genome) {
+	SpeciateInto(settings, inno, layer, seedOrganisms(settings, inno, seed, settings.PopulationSize, layer.Generation))
+}
+
+// Returns the layers as one population, sharing their species and tallies
+func joinLayers(layers []*Population) *Population {
+	pop := &Population{Generation: layers[0].Generation}
+	for _, l := range layers {
+		pop.Species = append(pop.Species, l.Species...)
+		pop.counters.merge(&l.counters)
+	}
+	return pop
+}
+
+// Splits a restored population into its layers by the layer each species
+// recorded
+func splitLayers(settings *Settings, pop *Population) []*Population {
+	layers := make([]*Population, settings.ALPSLayers)
+	for j := range layers {
+		layers[j] = &Population{Generation: pop.Generation}
+	}
+	for _, s := range pop.Species {
+		j := s.Layer
+		if j >= len(layers) {
+			j = len(layers) - 1
+		}
+		layers[j].Species = append(layers[j].Species, s)
+	}
+	return layers
+}
+
+// Returns the initial genome the restored organisms descend from, taking its
+// nodes from them and the markers of its connections from any which still
+// carry them, so reseeded organisms remain comparable with the rest
+func restoredSeed(settings *Settings, inno *innovation, pop *Population) *Genome {
+	orgs := pop.Organisms()
+	seed := &Genome{ID: -1, Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
+	if settings.SelfAdaptive {
+		seed.Profile = unitProfile()
+	}
+	if len(orgs) == 0 {
+		return seed
+	}
+	for _, ng := range orgs[0].Nodes {
+		if ng.Type == neural.BIAS || ng.Type == neural.INPUT || ng.Type == neural.OUTPUT {
+			seed.Nodes[ng.Marker] = cloneNode(ng)
+		}
+	}
+	type pair struct{ source, target int }
+	markers := make(map[pair]int)
+	for _, o := range orgs {
+		for _, cg := range o.Conns {
+			if seed.Nodes[cg.Source] != nil && seed.Nodes[cg.Target] != nil {
+				markers[pair{cg.Source, cg.Target}] = cg.Marker
+			}
+		}
+	}
+	nodes := seed.Nodes.sortedMarkers()
+	for _, i := range nodes {
+		for _, j := range nodes {
+			in, out := seed.Nodes[i], seed.Nodes[j]
+			if out.Type != neural.OUTPUT || in.Type == neural.OUTPUT {
+				continue
+			}
+			m, ok := markers[pair{i, j}]
+			if !ok {
+				m = inno.nextMarker()
+			}
+			seed.Conns[m] = &ConnGene{Marker: m, Enabled: true, Source: i, Target: j}
+		}
+	}
+	return seed
+}
@@ -0,0 +1,185 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Returns small settings split into three age layers
+func alpsSettings() *Settings {
+	s := testSettings()
+	s.PopulationSize = 20
+	s.ALPSLayers, s.ALPSAgeGap = 3, 3
+	return s
+}
+
+// Checks that every organism sits in a layer its ALPS age allows and, in a
+// generation reseeding the bottom layer, that the layer holds only fresh
+// organisms
+func checkLayers(t *testing.T, s *Settings, pop *Population) {
+	t.Helper()
+	gen := pop.Generation
+	reseeded := gen > 1 && (gen-1)%s.ALPSAgeGap == 0
+	bottom := 0
+	for _, sp := range pop.Species {
+		if sp.Layer < 0 || sp.Layer >= s.ALPSLayers {
+			t.Fatalf("Generation %d species %d is in layer %d", gen, sp.ID, sp.Layer)
+		}
+		for _, o := range sp.Orgs {
+			if s.overAge(o, gen, sp.Layer) || o.alpsAge(gen) < 0 {
+				t.Errorf("Generation %d organism %d of ALPS age %d is in layer %d", gen, o.ID, o.alpsAge(gen),
+					sp.Layer)
+			}
+			if sp.Layer == 0 {
+				bottom += 1
+				if reseeded && o.Birth != gen {
+					t.Errorf("Generation %d organism %d, born %d, is in the reseeded bottom layer", gen, o.ID,
+						o.Birth)
+				}
+			}
+		}
+	}
+	if reseeded && bottom != s.PopulationSize {
+		t.Errorf("Generation %d reseeded the bottom layer with %d organisms", gen, bottom)
+	}
+}
+
+func TestALPS(t *testing.T) {
+	s := alpsSettings()
+	var champ *Organism
+	layersUsed := make(map[int]bool)
+	s.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		checkLayers(t, s, pop)
+		for _, sp := range pop.Species {
+			layersUsed[sp.Layer] = true
+		}
+		if c := pop.Champion(); champ == nil || c.Fitness[0] > champ.Fitness[0] {
+			champ = c
+		}
+		return nil
+	}
+	best, pop := trainTest(t, s, 12)
+	if pop.Generation != 12 {
+		t.Errorf("Ended at generation %d", pop.Generation)
+	}
+	if len(layersUsed) != s.ALPSLayers {
+		t.Errorf("Species were only ever in layers %v", layersUsed)
+	}
+	if best.ID != champ.ID || best.Fitness[0] != champ.Fitness[0] {
+		t.Errorf("Returned organism %d of fitness %v, the best being %d of %v", best.ID, best.Fitness,
+			champ.ID, champ.Fitness)
+	}
+}
+
+func TestALPSRestore(t *testing.T) {
+	arch := &memoryArchiver{}
+	s := alpsSettings()
+	if _, _, err := Train(s, 5, genomeDecoder{}, serialEval{}, weightEval{}, arch, nil); err != nil {
+		t.Fatal(err)
+	}
+	archived := arch.pop.Copy()
+
+	// The layers split as they were joined
+	layers := splitLayers(s, archived)
+	joined := joinLayers(layers)
+	if d := joined.Difference(archived, 0); d != "" {
+		t.Errorf("Split and joined layers differ at %s", d)
+	}
+	for j, l := range layers {
+		for _, sp := range l.Species {
+			if sp.Layer != j {
+				t.Errorf("Species %d of layer %d split into layer %d", sp.ID, sp.Layer, j)
+			}
+		}
+	}
+
+	// The reseeding genome has the structure and markers of the original
+	inno := newInnovationAt(1000, 1000)
+	defer inno.close()
+	seed := restoredSeed(s, inno, archived)
+	var fresh *Organism
+	for _, sp := range archived.Species {
+		for _, o := range sp.Orgs {
+			if sp.Layer == 0 && o.Birth == 4 { // Reseeded in generation 4
+				fresh = o
+			}
+		}
+	}
+	if fresh == nil {
+		t.Fatal("No reseeded organism in the bottom layer")
+	}
+	if fmt.Sprint(connMarkers(seed)) != fmt.Sprint(connMarkers(fresh.Genome)) ||
+		fmt.Sprint(seed.Nodes.sortedMarkers()) != fmt.Sprint(fresh.Nodes.sortedMarkers()) {
+		t.Errorf("Restored seed has nodes %v and connections %v, a reseeded organism %v and %v",
+			seed.Nodes.sortedMarkers(), connMarkers(seed), fresh.Nodes.sortedMarkers(), connMarkers(fresh.Genome))
+	}
+
+	// The resumed run carries on with the archived layers, the elite staying
+	// in theirs unless they have aged out of it
+	layer := make(map[int]int)
+	for _, sp := range archived.Species {
+		for _, o := range sp.Orgs {
+			layer[o.ID] = sp.Layer
+		}
+	}
+	carried := 0
+	s = alpsSettings()
+	s.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		checkLayers(t, s, pop)
+		if pop.Generation != 6 {
+			return nil
+		}
+		for _, sp := range pop.Species {
+			for _, o := range sp.Orgs {
+				was, ok := layer[o.ID]
+				if !ok {
+					continue
+				}
+				carried += 1
+				if s.overAge(o, pop.Generation, was) {
+					was += 1
+				}
+				if sp.Layer != was {
+					t.Errorf("Organism %d moved from layer %d to %d on resuming", o.ID, layer[o.ID], sp.Layer)
+				}
+			}
+		}
+		return nil
+	}
+	_, pop, err := Train(s, 3, genomeDecoder{}, serialEval{}, weightEval{}, arch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pop.Generation != 8 {
+		t.Errorf("Resumed run ended at generation %d, want 8", pop.Generation)
+	}
+	if carried == 0 {
+		t.Error("No organism was carried over into the resumed run")
+	}
+}
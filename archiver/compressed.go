@@ -0,0 +1,151 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package archiver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/boggo/neat"
+	"io"
+	"os"
+	"time"
+)
+
+// Version of the compressed checkpoint format this build reads and writes
+const CheckpointFormat = 1
+
+// Header written ahead of the population in a compressed checkpoint
+type CheckpointHeader struct {
+	Format  int       // Version of the checkpoint format
+	Version string    // Version of the package which wrote the checkpoint
+	Created time.Time // When the checkpoint was written
+	SHA256  string    // Hex digest of the JSON payload which follows
+}
+
+type compressedArchiver struct {
+	path string
+}
+
+// Returns an archiver writing the population as gzipped JSON, preceded by a
+// header whose digest is checked when it is restored. Plain JSON, as written
+// by the JSON archiver, is restored as well.
+func NewCompressed(path string) neat.Archiver {
+	return &compressedArchiver{path}
+}
+
+// Load the population from a compressed or plain JSON file
+func (x *compressedArchiver) Restore() (pop *neat.Population, err error) {
+	return restoreJSON(x.path)
+}
+
+// Loads the population from a file of JSON, telling a compressed checkpoint
+// from plain JSON by the gzip magic number at its start
+func restoreJSON(path string) (pop *neat.Population, err error) {
+	var f *os.File
+	f, err = os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// Read plain JSON as it is
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(2)
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		pop = new(neat.Population)
+		err = json.NewDecoder(r).Decode(pop)
+		return
+	}
+
+	// Read the header and the payload
+	var z *gzip.Reader
+	if z, err = gzip.NewReader(r); err != nil {
+		return
+	}
+	defer z.Close()
+	zr := bufio.NewReader(z)
+	var line []byte
+	if line, err = zr.ReadBytes('\n'); err != nil {
+		err = fmt.Errorf("Checkpoint %s has no header: %v", path, err)
+		return
+	}
+	var hdr CheckpointHeader
+	if err = json.Unmarshal(line, &hdr); err != nil {
+		err = fmt.Errorf("Checkpoint %s has an unreadable header: %v", path, err)
+		return
+	}
+	if hdr.Format != CheckpointFormat {
+		err = fmt.Errorf("Checkpoint %s written by format v%d, this build reads v%d", path, hdr.Format,
+			CheckpointFormat)
+		return
+	}
+	var payload []byte
+	if payload, err = io.ReadAll(zr); err != nil {
+		return
+	}
+
+	// Verify the payload
+	sum := sha256.Sum256(payload)
+	if got := hex.EncodeToString(sum[:]); got != hdr.SHA256 {
+		err = fmt.Errorf("Checkpoint %s is corrupt: payload digest is %s, header records %s", path, got,
+			hdr.SHA256)
+		return
+	}
+	pop = new(neat.Population)
+	err = json.Unmarshal(payload, pop)
+	return
+}
+
+// Save the population to a compressed file
+func (x *compressedArchiver) Archive(pop *neat.Population) (err error) {
+	var payload []byte
+	if payload, err = json.Marshal(pop); err != nil {
+		return
+	}
+	sum := sha256.Sum256(payload)
+	hdr := CheckpointHeader{Format: CheckpointFormat, Version: neat.Version, Created: time.Now().UTC(),
+		SHA256: hex.EncodeToString(sum[:])}
+	var line []byte
+	if line, err = json.Marshal(hdr); err != nil {
+		return
+	}
+
+	// Compress into memory so a failure leaves any earlier checkpoint whole
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write(append(line, '\n'))
+	z.Write(payload)
+	if err = z.Close(); err != nil {
+		return
+	}
+	return os.WriteFile(x.path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,155 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/popeval"
+)
+
+type nullPhenome struct{}
+
+func (nullPhenome) Analyze(inputs []float64) ([]float64, error) { return []float64{0}, nil }
+
+type nullDecoder struct{}
+
+func (nullDecoder) Decode(g *neat.Genome) (neat.Phenome, error) { return nullPhenome{}, nil }
+
+// Scores an organism by its number of connections
+type sizeEval struct{}
+
+func (sizeEval) Evaluate(org *neat.Organism) error {
+	org.Fitness = []float64{float64(len(org.Conns))}
+	return nil
+}
+
+// Returns a small trained population
+func testPopulation(t *testing.T) *neat.Population {
+	t.Helper()
+	s := neat.SettingsForXOR()
+	s.PopulationSize = 20
+	s.Seed = 1
+	_, pop, err := neat.Train(s, 3, nullDecoder{}, popeval.NewSerial(), sizeEval{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pop
+}
+
+// Rewrites the compressed checkpoint at path after changing its contents
+func rewrite(t *testing.T, path string, change func([]byte) []byte) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(change(raw))
+	w.Close()
+	if err = os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	pop := testPopulation(t)
+	dir := t.TempDir()
+	gz, plain := filepath.Join(dir, "pop.gz"), filepath.Join(dir, "pop.json")
+	if err := NewCompressed(gz).Archive(pop); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewJSON(plain).Archive(pop); err != nil {
+		t.Fatal(err)
+	}
+
+	// Either archiver restores either format
+	for _, c := range []struct {
+		name string
+		arch neat.Archiver
+	}{
+		{"compressed from compressed", NewCompressed(gz)},
+		{"compressed from plain", NewCompressed(plain)},
+		{"json from compressed", NewJSON(gz)},
+		{"json from plain", NewJSON(plain)},
+	} {
+		r, err := c.arch.Restore()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if d := pop.Difference(r, 0); d != "" {
+			t.Errorf("%s: restored population differs at %s", c.name, d)
+		}
+	}
+}
+
+func TestCompressedChecks(t *testing.T) {
+	pop := testPopulation(t)
+	path := filepath.Join(t.TempDir(), "pop.gz")
+	for _, c := range []struct {
+		name   string
+		change func([]byte) []byte
+		want   string
+	}{
+		{"format", func(b []byte) []byte {
+			return bytes.Replace(b, []byte(`"Format":1`), []byte(`"Format":3`), 1)
+		}, "written by format v3, this build reads v1"},
+		{"corrupt", func(b []byte) []byte {
+			b[len(b)-10] ^= 1
+			return b
+		}, "is corrupt"},
+		{"headless", func(b []byte) []byte {
+			return b[:bytes.IndexByte(b, '\n')]
+		}, "has no header"},
+	} {
+		if err := NewCompressed(path).Archive(pop); err != nil {
+			t.Fatal(err)
+		}
+		rewrite(t, path, c.change)
+		_, err := NewCompressed(path).Restore()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: error %v, want one containing %q", c.name, err, c.want)
+		}
+	}
+}
@@ -40,19 +40,10 @@ func NewJSON(path string) neat.Archiver {
 	return &jsonArchiver{path}
 }
 
-// Load the population from a JSON file
+// Load the population from a JSON file, or from a compressed checkpoint
+// written by the compressed archiver
 func (x *jsonArchiver) Restore() (pop *neat.Population, err error) {
-	var f *os.File
-	f, err = os.Open(x.path)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-
-	pop = new(neat.Population)
-	d := json.NewDecoder(f)
-	err = d.Decode(pop)
-	return
+	return restoreJSON(x.path)
 }
 
 // Save the population to a JSON file
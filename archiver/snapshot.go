@@ -0,0 +1,101 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package archiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boggo/neat"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Writes the champion of each generation to a directory
+type snapshotWriter struct {
+	dir      string  // Directory holding the snapshots
+	template string  // Filename given the generation and the champion's fitness
+	improved bool    // Write only when the champion improves on the last written
+	dot      bool    // Also write the genome in DOT beside the JSON
+	best     float64 // Fitness of the last champion written
+	written  bool    // Has a champion been written?
+}
+
+// Returns a new snapshot writer saving the genome of each generation's
+// champion, in JSON, to a file in dir named by the template, as in
+// "gen_%04d_fit_%.3f.json", from the generation and the champion's fitness.
+// With improved only champions fitter than the last one written are saved;
+// with dot the genome is also drawn to a file of the same name ending in
+// ".dot". An existing file of the same name is overwritten. Set the
+// returned hooks as the settings' Hooks.
+func NewSnapshot(dir, template string, improved, dot bool) neat.Hooks {
+	w := &snapshotWriter{dir: dir, template: template, improved: improved, dot: dot}
+	return neat.Hooks{OnGenerationEnd: w.OnGenerationEnd}
+}
+
+// Writes the population's champion
+func (w *snapshotWriter) OnGenerationEnd(pop *neat.Population, stats *neat.Stats) (err error) {
+	champ := pop.Champion()
+	if champ == nil {
+		return
+	}
+	fit := champ.Fitness[0]
+	if w.improved && w.written && fit <= w.best {
+		return
+	}
+
+	if err = os.MkdirAll(w.dir, 0755); err != nil {
+		return
+	}
+	path := filepath.Join(w.dir, fmt.Sprintf(w.template, pop.Generation, fit))
+	if err = writeFile(path, func(f *os.File) error { return json.NewEncoder(f).Encode(champ.Genome) }); err != nil {
+		return
+	}
+	if w.dot {
+		dot := strings.TrimSuffix(path, filepath.Ext(path)) + ".dot"
+		if err = writeFile(dot, func(f *os.File) error { return champ.Genome.WriteDOT(f) }); err != nil {
+			return
+		}
+	}
+	w.best, w.written = fit, true
+	return
+}
+
+// Creates the file and writes it, returning the first error including any
+// from closing it
+func writeFile(path string, write func(f *os.File) error) (err error) {
+	var f *os.File
+	f, err = os.Create(path)
+	if err != nil {
+		return
+	}
+	err = write(f)
+	if e2 := f.Close(); err == nil {
+		err = e2
+	}
+	return
+}
@@ -0,0 +1,88 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package archiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/popeval"
+)
+
+func TestSnapshot(t *testing.T) {
+	for _, improved := range []bool{false, true} {
+		dir := t.TempDir()
+		s := neat.SettingsForXOR()
+		s.PopulationSize, s.Seed = 20, 1
+		s.MutateAddConnection = 0.5
+		s.Hooks = NewSnapshot(dir, "gen_%04d_fit_%07.3f.json", improved, true)
+		if _, _, err := neat.Train(s, 8, nullDecoder{}, popeval.NewSerial(), sizeEval{}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !improved && len(names) != 8 {
+			t.Errorf("Wrote %d snapshots in 8 generations", len(names))
+		}
+		if len(names) == 0 {
+			t.Fatalf("Improved %v: no snapshots written", improved)
+		}
+		last := -1.0
+		for _, name := range names { // Sorted by generation
+			var gen int
+			var fit float64
+			if _, err := fmt.Sscanf(filepath.Base(name), "gen_%04d_fit_%f.json", &gen, &fit); err != nil {
+				t.Fatalf("Snapshot %s: %v", name, err)
+			}
+			if improved && fit <= last {
+				t.Errorf("Snapshot %s did not improve on %v", name, last)
+			}
+			last = fit
+			b, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := &neat.Genome{}
+			if err = json.Unmarshal(b, g); err != nil {
+				t.Fatalf("Snapshot %s: %v", name, err)
+			}
+			if g.Fitness[0] < fit-0.001 || g.Fitness[0] > fit+0.001 {
+				t.Errorf("Snapshot %s holds a genome of fitness %v", name, g.Fitness)
+			}
+			if _, err = os.Stat(name[:len(name)-len(".json")] + ".dot"); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
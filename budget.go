@@ -0,0 +1,188 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"sort"
+)
+
+// Evaluates as many of the population's organisms as Settings.EvaluationBudget
+// allows, estimating the fitness of the others from their parents in the
+// previous population, and returns the number evaluated. Organisms carried
+// over from the previous population with an evaluated fitness, such as the
+// elite, are left alone.
+func evaluateBudgeted(ctx context.Context, settings *Settings, popEval PopEval, prev, pop *Population, orgEval OrgEval,
+	prior map[*Organism][]float64) (evals int, err error) {
+
+	// Fitness of the possible parents
+	parentFit := make(map[int][]float64)
+	carried := make(map[*Organism]bool)
+	if prev != nil {
+		for _, o := range prev.Organisms() {
+			if len(o.Fitness) > 0 {
+				parentFit[o.ID] = o.Fitness
+			}
+			carried[o] = true
+		}
+	}
+
+	// Estimate the fitness of those needing an evaluation, and the promise
+	// of each species
+	estimate := make(map[*Organism][]float64)
+	needy := make([]OrganismSlice, len(pop.Species))
+	promise := make([]float64, len(pop.Species))
+	total := float64(0)
+	for i, s := range pop.Species {
+		sum, n := float64(0), 0
+		for _, o := range s.Orgs {
+			f := o.Fitness
+			if !carried[o] || len(f) == 0 || o.Estimated {
+				f = parentMean(o, parentFit)
+				estimate[o] = f
+				needy[i] = append(needy[i], o)
+			}
+			if len(f) > 0 {
+				sum += f[0]
+				n += 1
+			}
+		}
+		if n > 0 {
+			promise[i] = sum / float64(n)
+			total += promise[i]
+		}
+	}
+
+	// Share the budget, handing what the smaller species cannot use to the
+	// most promising
+	shares := make([]int, len(pop.Species))
+	left := settings.EvaluationBudget
+	for i, orgs := range needy {
+		shares[i] = settings.EvaluationBudget / len(pop.Species)
+		if total > 0 {
+			shares[i] = int(float64(settings.EvaluationBudget) * promise[i] / total)
+		}
+		if shares[i] < settings.BudgetSpeciesMinimum {
+			shares[i] = settings.BudgetSpeciesMinimum
+		}
+		if shares[i] > len(orgs) {
+			shares[i] = len(orgs)
+		}
+		left -= shares[i]
+	}
+	ranked := make([]int, len(pop.Species))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return promise[ranked[a]] > promise[ranked[b]] })
+	for _, i := range ranked {
+		if left <= 0 {
+			break
+		}
+		extra := len(needy[i]) - shares[i]
+		if extra > left {
+			extra = left
+		}
+		shares[i] += extra
+		left -= extra
+	}
+
+	// Choose whom to evaluate, those of unknown parentage first and then the
+	// most promising
+	var chosen OrganismSlice
+	for i, orgs := range needy {
+		share := shares[i]
+		sort.SliceStable(orgs, func(a, b int) bool {
+			ea, eb := estimate[orgs[a]], estimate[orgs[b]]
+			switch {
+			case len(ea) == 0 || len(eb) == 0:
+				return len(ea) == 0 && len(eb) > 0
+			case ea[0] != eb[0]:
+				return ea[0] > eb[0]
+			}
+			return orgs[a].ID < orgs[b].ID
+		})
+		for j, o := range orgs {
+			if j < share || len(estimate[o]) == 0 {
+				chosen = append(chosen, o)
+			}
+		}
+	}
+
+	// Evaluate the chosen and estimate the rest
+	picked := make(map[*Organism]bool, len(chosen))
+	for _, o := range chosen {
+		picked[o] = true
+		o.Fitness, o.Estimated = nil, false
+	}
+	if len(chosen) > 0 {
+		brood := &Population{Generation: pop.Generation, Species: SpeciesSlice{{Orgs: chosen}}}
+		if err = evaluateCounted(ctx, settings, popEval, brood, orgEval, prior); err != nil {
+			return
+		}
+	}
+	discount := settings.BudgetDiscount
+	if discount == 0 {
+		discount = 1
+	}
+	for o, f := range estimate {
+		if picked[o] {
+			continue
+		}
+		o.Fitness = make([]float64, len(f))
+		for j := range f {
+			o.Fitness[j] = f[j] * discount
+		}
+		o.Estimated = true
+	}
+	return len(chosen), nil
+}
+
+// Returns the mean fitness of the organism's parents, or nil if none of them
+// is known
+func parentMean(o *Organism, parentFit map[int][]float64) (mean []float64) {
+	n := 0
+	for _, id := range o.Parents {
+		f, ok := parentFit[id]
+		if !ok {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float64, len(f))
+		}
+		for j := range mean {
+			if j < len(f) {
+				mean[j] += f[j]
+			}
+		}
+		n += 1
+	}
+	for j := range mean {
+		mean[j] /= float64(n)
+	}
+	return
+}
@@ -0,0 +1,150 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Champions of past generations, kept as opponents for coevolution. Record
+// the champion of each generation by setting Record as the
+// Hooks.OnGenerationEnd of a run.
+type HallOfFame struct {
+	Size    int           // Most champions kept, the oldest going first. 0 = no limit
+	Members OrganismSlice // The champions kept, oldest first
+
+	dcode      Decoder    // Decodes the champions as they are admitted
+	seed       int64      // Seed of the opponent sampling, mixed with the generation
+	generation int        // Generation last recorded
+	mu         sync.Mutex // Guards the members while evaluators read them
+}
+
+// Returns a new hall of fame keeping up to size champions, decoding each as
+// it is admitted. The opponents are sampled from the seed, such as the
+// run's Settings.Seed, so that a seeded run samples them alike every time.
+func NewHallOfFame(size int, seed int64, dcode Decoder) *HallOfFame {
+	return &HallOfFame{Size: size, seed: seed, dcode: dcode}
+}
+
+// Admits a copy of the population's champion, suitable as the
+// Hooks.OnGenerationEnd of a run
+func (h *HallOfFame) Record(pop *Population, stats *Stats) (err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.generation = pop.Generation
+	champ := pop.Champion()
+	if champ == nil {
+		return
+	}
+	c := champ.Copy()
+	if h.dcode != nil {
+		if c.Phenome, err = h.dcode.Decode(c.Genome); err != nil {
+			return fmt.Errorf("decoding champion %d for the hall of fame: %v", c.ID, err)
+		}
+	}
+	h.Members = append(h.Members, c)
+	if h.Size > 0 && len(h.Members) > h.Size {
+		h.Members = h.Members[len(h.Members)-h.Size:]
+	}
+	return
+}
+
+// Returns up to n members chosen at random without replacement, the choice
+// depending only on the seed, the generation and the candidate so that the
+// sampling is the same however the evaluations are spread over goroutines
+func (h *HallOfFame) sample(candidate *Organism, n int) OrganismSlice {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n <= 0 || n >= len(h.Members) {
+		return append(OrganismSlice(nil), h.Members...)
+	}
+	r := rand.New(rand.NewSource(h.seed*0x5851f42d4c957f2d + int64(h.generation)*0x14057b7ef767814f +
+		int64(candidate.ID)))
+	opps := make(OrganismSlice, n)
+	for i, j := range r.Perm(len(h.Members))[:n] {
+		opps[i] = h.Members[j]
+	}
+	return opps
+}
+
+// PairEval scores a candidate in play against a single opponent
+type PairEval interface {
+	EvaluatePair(candidate, opponent *Organism) float64
+}
+
+// Organism evaluator scoring each candidate against opponents from the hall
+// of fame
+type coevolutionEval struct {
+	hof       *HallOfFame
+	pair      PairEval
+	opponents int    // Opponents sampled for each candidate. 0 = all
+	aggregate string // "mean" or "min" of the scores
+}
+
+// Returns an organism evaluator playing each candidate against opponents
+// sampled from the hall of fame and taking the mean, or with aggregate "min"
+// the worst, of the scores as its fitness. A candidate is given 0 before the
+// hall of fame has any members.
+func NewCoevolutionEval(hof *HallOfFame, pair PairEval, opponents int, aggregate string) (OrgEval, error) {
+	switch aggregate {
+	case "", "mean", "min":
+	default:
+		return nil, fmt.Errorf("Unknown aggregate %q", aggregate)
+	}
+	return &coevolutionEval{hof: hof, pair: pair, opponents: opponents, aggregate: aggregate}, nil
+}
+
+func (c *coevolutionEval) Evaluate(org *Organism) (err error) {
+	opps := c.hof.sample(org, c.opponents)
+	if len(opps) == 0 {
+		org.Fitness = []float64{0}
+		return
+	}
+
+	var f float64
+	if c.aggregate == "min" {
+		f = math.Inf(1)
+	}
+	for _, o := range opps {
+		s := c.pair.EvaluatePair(org, o)
+		if c.aggregate == "min" {
+			f = math.Min(f, s)
+		} else {
+			f += s
+		}
+	}
+	if c.aggregate != "min" {
+		f /= float64(len(opps))
+	}
+	org.Fitness = []float64{f}
+	return
+}
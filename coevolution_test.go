@@ -0,0 +1,147 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// Returns a hall of fame which has recorded a champion, with ID and fitness
+// g, for each of the generations
+func recordedHallOfFame(t *testing.T, size int, seed int64, generations int) *HallOfFame {
+	t.Helper()
+	h := NewHallOfFame(size, seed, nil)
+	for g := 1; g <= generations; g++ {
+		pop := &Population{Generation: g,
+			Species: SpeciesSlice{{Orgs: OrganismSlice{{Genome: testGenome(g, float64(g))}}}}}
+		if err := h.Record(pop, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+// Returns the IDs of the organisms
+func orgIDs(orgs OrganismSlice) []int {
+	ids := make([]int, len(orgs))
+	for i, o := range orgs {
+		ids[i] = o.ID
+	}
+	return ids
+}
+
+func TestHallOfFame(t *testing.T) {
+
+	// Only the latest Size champions are kept
+	h := recordedHallOfFame(t, 6, 1, 10)
+	if got := orgIDs(h.Members); fmt.Sprint(got) != "[5 6 7 8 9 10]" {
+		t.Fatalf("Kept champions %v", got)
+	}
+	if n := len(recordedHallOfFame(t, 0, 1, 10).Members); n != 10 {
+		t.Errorf("Unlimited hall of fame kept %d champions", n)
+	}
+
+	// Sampling takes distinct members, or all of them when asked for as many
+	candidates := make([]*Organism, 20)
+	for i := range candidates {
+		candidates[i] = &Organism{Genome: testGenome(100+i, 0)}
+	}
+	for _, c := range candidates {
+		opps := h.sample(c, 3)
+		seen := make(map[int]bool)
+		for _, o := range opps {
+			seen[o.ID] = true
+		}
+		if len(opps) != 3 || len(seen) != 3 {
+			t.Fatalf("Sampled %v for candidate %d", orgIDs(opps), c.ID)
+		}
+	}
+	if n := len(h.sample(candidates[0], 0)); n != 6 {
+		t.Errorf("Sampling all gave %d members", n)
+	}
+	if n := len(h.sample(candidates[0], 8)); n != 6 {
+		t.Errorf("Sampling more than there are gave %d members", n)
+	}
+
+	// The sample depends on the seed, generation and candidate alone
+	same := recordedHallOfFame(t, 6, 1, 10)
+	later := recordedHallOfFame(t, 6, 1, 10)
+	later.Record(&Population{Generation: 11, Species: SpeciesSlice{{Orgs: OrganismSlice{}}}}, nil)
+	differs := map[string]bool{}
+	for _, c := range candidates {
+		want := fmt.Sprint(orgIDs(h.sample(c, 3)))
+		if got := fmt.Sprint(orgIDs(same.sample(c, 3))); got != want {
+			t.Errorf("Candidate %d sampled %s and then %s", c.ID, want, got)
+		}
+		differs["candidate"] = differs["candidate"] || want != fmt.Sprint(orgIDs(h.sample(candidates[0], 3)))
+		differs["generation"] = differs["generation"] || want != fmt.Sprint(orgIDs(later.sample(c, 3)))
+		other := recordedHallOfFame(t, 6, 2, 10)
+		differs["seed"] = differs["seed"] || want != fmt.Sprint(orgIDs(other.sample(c, 3)))
+	}
+	for _, k := range []string{"candidate", "generation", "seed"} {
+		if !differs[k] {
+			t.Errorf("Changing the %s never changed the sample", k)
+		}
+	}
+}
+
+// Scores the candidate by its weights, less a little for the opponent's
+type opponentPair struct{}
+
+func (opponentPair) EvaluatePair(candidate, opponent *Organism) float64 {
+	weightEval{}.Evaluate(candidate)
+	return candidate.Fitness[0] - 0.01*float64(opponent.ID%7)
+}
+
+func TestCoevolutionDeterministic(t *testing.T) {
+	var runs [2][]byte
+	for i := range runs {
+		s := testSettings()
+		h := NewHallOfFame(5, s.Seed, nil)
+		s.Hooks.OnGenerationEnd = h.Record
+		eval, err := NewCoevolutionEval(h, opponentPair{}, 2, "mean")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, pop, err := Train(s, 10, genomeDecoder{}, serialEval{}, eval, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs[i], err = json.Marshal(pop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(runs[0], runs[1]) {
+		t.Error("Coevolution runs with the same seed serialized differently")
+	}
+	if _, err := NewCoevolutionEval(nil, opponentPair{}, 2, "median"); err == nil {
+		t.Error("Accepted an unknown aggregate")
+	}
+}
@@ -0,0 +1,64 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder
+
+import (
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/phenome"
+	"github.com/boggo/neural"
+)
+
+// Decoder producing compiled phenomes, for experiments which activate each
+// network many times
+type compiledDecoder struct {
+	hidden, output neural.FuncType // Activation functions of the hidden and output nodes
+	feedforward    bool            // Reject genomes whose networks have a cycle
+}
+
+// Returns a new decoder which compiles each genome into flat arrays
+func NewCompiled() (decoder neat.Decoder) {
+	return &compiledDecoder{hidden: neural.SIGMOID, output: neural.SIGMOID}
+}
+
+// Returns a new compiling decoder giving the nodes the activation functions
+// of the settings. As with NewNEATFor, genomes with a cycle fail to decode
+// unless the settings allow recurrent connections.
+func NewCompiledFor(settings *neat.Settings) (decoder neat.Decoder) {
+	d := &compiledDecoder{feedforward: !settings.AllowRecurrent}
+	d.hidden, d.output = settings.Activations()
+	return d
+}
+
+// Decodes the genome into a compiled phenome
+func (d compiledDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error) {
+	if d.feedforward {
+		if err = checkFeedforward(genome); err != nil {
+			return
+		}
+	}
+	return phenome.NewCompiledWith(genome, d.hidden, d.output)
+}
@@ -0,0 +1,180 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder_test
+
+import (
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neat/phenome"
+	"github.com/boggo/neural"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Returns a random feedforward genome with the inputs, hidden nodes in a few
+// layers and outputs, some of its connections disabled
+func randomGenome(r *rand.Rand, inputs, hidden, outputs int) *neat.Genome {
+	g := &neat.Genome{Nodes: make(neat.NodeGeneMap), Conns: make(neat.ConnGeneMap)}
+	add := func(t neural.NodeType, x, y float64) {
+		m := len(g.Nodes) + 1
+		ng := &neat.NodeGene{Marker: m, Type: t, X: x, Y: y}
+		if t == neural.HIDDEN || t == neural.OUTPUT {
+			ng.Response = 0.5 + 1.5*r.Float64()
+		}
+		g.Nodes[m] = ng
+	}
+	add(neural.BIAS, 0, 0)
+	for i := 0; i < inputs; i++ {
+		add(neural.INPUT, float64(i+1)/float64(inputs), 0)
+	}
+	for i := 0; i < hidden; i++ {
+		add(neural.HIDDEN, r.Float64(), 0.25+0.5*float64(r.Intn(3))/2)
+	}
+	for i := 0; i < outputs; i++ {
+		add(neural.OUTPUT, float64(i)/float64(outputs), 1)
+	}
+	for s := 1; s <= len(g.Nodes); s++ {
+		for t := 1; t <= len(g.Nodes); t++ {
+			if g.Nodes[s].Y >= g.Nodes[t].Y || r.Float64() < 0.4 {
+				continue
+			}
+			m := len(g.Conns) + 1
+			g.Conns[m] = &neat.ConnGene{Marker: m, Source: s, Target: t, Weight: 2 * r.NormFloat64(),
+				Enabled: r.Float64() < 0.9}
+		}
+	}
+	return g
+}
+
+// Returns random inputs in [-1, 1)
+func randomInputs(r *rand.Rand, n int) []float64 {
+	in := make([]float64, n)
+	for i := range in {
+		in[i] = 2*r.Float64() - 1
+	}
+	return in
+}
+
+func TestCompiledMatchesNEAT(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := neat.SettingsForXOR()
+	for _, acts := range [][2]string{{"sigmoid", "sigmoid"}, {"sigmoid", "linear"}} {
+		s.HiddenActivation, s.OutputActivation = acts[0], acts[1]
+		naive, compiled := decoder.NewNEATFor(s), decoder.NewCompiledFor(s)
+		for i := 0; i < 50; i++ {
+			g := randomGenome(r, 3, 8, 2)
+			p1, err := naive.Decode(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p2, err := compiled.Decode(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 20; j++ {
+				in := randomInputs(r, 3)
+				o1, err1 := p1.Analyze(in)
+				o2, err2 := p2.Analyze(in)
+				if err1 != nil || err2 != nil {
+					t.Fatal(err1, err2)
+				}
+				for k := range o1 {
+					if math.Float64bits(o1[k]) != math.Float64bits(o2[k]) {
+						t.Fatalf("%v genome %d, inputs %v: NEAT gave %v, compiled %v", acts, i, in, o1, o2)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Measures activating networks of a few sizes decoded by each decoder
+func BenchmarkActivate(b *testing.B) {
+	for _, size := range []int{10, 100} {
+		g := randomGenome(rand.New(rand.NewSource(1)), 8, size, 4)
+		for _, d := range []struct {
+			name    string
+			decoder neat.Decoder
+		}{{"naive", decoder.NewNEAT()}, {"compiled", decoder.NewCompiled()}} {
+			b.Run(fmt.Sprintf("%s/%d", d.name, size), func(b *testing.B) {
+				p, err := d.decoder.Decode(g)
+				if err != nil {
+					b.Fatal(err)
+				}
+				in := randomInputs(rand.New(rand.NewSource(2)), 8)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err = p.Analyze(in); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+
+		// Compiled phenomes can also write into the caller's outputs
+		b.Run(fmt.Sprintf("compiled-into/%d", size), func(b *testing.B) {
+			p, err := decoder.NewCompiled().Decode(g)
+			if err != nil {
+				b.Fatal(err)
+			}
+			into := p.(interface {
+				AnalyzeInto(inputs, outputs []float64) error
+			})
+			in, out := randomInputs(rand.New(rand.NewSource(2)), 8), make([]float64, 4)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err = into.AnalyzeInto(in, out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		// And be quantized
+		b.Run(fmt.Sprintf("quantized/%d", size), func(b *testing.B) {
+			p, err := decoder.NewCompiled().Decode(g)
+			if err != nil {
+				b.Fatal(err)
+			}
+			q, err := phenome.Quantize(p, 8, phenome.PLAN)
+			if err != nil {
+				b.Fatal(err)
+			}
+			in := randomInputs(rand.New(rand.NewSource(2)), 8)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = q.Analyze(in); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
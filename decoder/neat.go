@@ -27,6 +27,7 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package decoder
 
 import (
+	"fmt"
 	"github.com/boggo/neat"
 	"github.com/boggo/neat/phenome"
 	"github.com/boggo/neural" // TODO: Should this library be moved to code.google.com, too?
@@ -34,15 +35,32 @@ import (
 )
 
 // Default NEAT decoder
-type neatDecoder struct{}
+type neatDecoder struct {
+	hidden, output neural.FuncType // Activation functions of the hidden and output nodes
+	feedforward    bool            // Reject genomes whose networks have a cycle
+}
 
 // Returns a new NEAT decoder
 func NewNEAT() (decoder neat.Decoder) {
-	return &neatDecoder{}
+	return &neatDecoder{hidden: neural.SIGMOID, output: neural.SIGMOID}
+}
+
+// Returns a new NEAT decoder giving the nodes the activation functions of
+// the settings. Without recurrent connections in the settings, decoding a
+// genome whose enabled connections form a cycle fails.
+func NewNEATFor(settings *neat.Settings) (decoder neat.Decoder) {
+	d := &neatDecoder{feedforward: !settings.AllowRecurrent}
+	d.hidden, d.output = settings.Activations()
+	return d
 }
 
 // Decodes a genome into a phenome using the NEAT decoder
 func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error) {
+	if d.feedforward {
+		if err = checkFeedforward(genome); err != nil {
+			return
+		}
+	}
 
 	// Extract the nodes into the correct sorted order (by its position)
 	nodes := make([]*neat.NodeGene, len(genome.Nodes))
@@ -69,10 +87,13 @@ func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error)
 	nmap := make(map[int]neural.Node)
 	for _, ng := range nodes {
 		var node neural.Node
-		if ng.Type == neural.BIAS || ng.Type == neural.INPUT {
+		switch ng.Type {
+		case neural.BIAS, neural.INPUT:
 			node = neural.NewNode(neural.DIRECT, ng.Type)
-		} else {
-			node = neural.NewNode(neural.SIGMOID, ng.Type)
+		case neural.OUTPUT:
+			node = neural.NewNode(d.output, ng.Type)
+		default:
+			node = neural.NewNode(d.hidden, ng.Type)
 		}
 		nmap[ng.Marker] = node
 		network.AddNode(node)
@@ -81,7 +102,13 @@ func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error)
 	for _, cg := range conns {
 		if cg.Enabled {
 			var conn neural.Connection
-			conn = neural.NewConnection(nmap[cg.Source], nmap[cg.Target], cg.Weight)
+			// The target's response scales its summed input, so it is folded
+			// into the weight of each incoming connection
+			w := cg.Weight
+			if tgt, ok := genome.Nodes[cg.Target]; ok {
+				w *= tgt.EffectiveResponse()
+			}
+			conn = neural.NewConnection(nmap[cg.Source], nmap[cg.Target], w)
 			network.AddConnection(conn)
 		}
 	}
@@ -92,6 +119,21 @@ func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error)
 	return
 }
 
+// Returns an error naming the connections and nodes of a cycle in the
+// genome's network, if it has one
+func checkFeedforward(genome *neat.Genome) error {
+	cycle := genome.Cycle()
+	if cycle == nil {
+		return nil
+	}
+	nodes := make([]int, len(cycle))
+	for i, k := range cycle {
+		nodes[i] = genome.Conns[k].Source
+	}
+	return fmt.Errorf("Genome %d is not feedforward: connections %v form a cycle through nodes %v",
+		genome.ID, cycle, nodes)
+}
+
 type sortNodes struct {
 	nodes []*neat.NodeGene
 }
@@ -99,6 +141,9 @@ type sortNodes struct {
 func (s *sortNodes) Len() int { return len(s.nodes) }
 func (s *sortNodes) Less(i, j int) bool {
 	if s.nodes[i].Y == s.nodes[j].Y {
+		if s.nodes[i].X == s.nodes[j].X {
+			return s.nodes[i].Marker < s.nodes[j].Marker
+		}
 		return s.nodes[i].X < s.nodes[j].X
 	} else {
 		return s.nodes[i].Y < s.nodes[j].Y
@@ -116,6 +161,12 @@ func (s *sortConns) Less(i, j int) bool {
 	a := s.genome.Nodes[s.conns[i].Target]
 	b := s.genome.Nodes[s.conns[j].Target]
 	if a.Y == b.Y {
+		if a.X == b.X {
+			if a.Marker == b.Marker {
+				return s.conns[i].Marker < s.conns[j].Marker
+			}
+			return a.Marker < b.Marker
+		}
 		return a.X < b.X
 	} else {
 		return a.Y < b.Y
@@ -0,0 +1,163 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder_test
+
+import (
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neural"
+	"math"
+	"strings"
+	"testing"
+)
+
+func sigmoid(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+
+// Decodes a genome with one hidden node under each pair of activations
+func TestActivations(t *testing.T) {
+	g := &neat.Genome{Nodes: neat.NodeGeneMap{
+		1: {Marker: 1, Type: neural.BIAS, X: 0, Y: 0},
+		2: {Marker: 2, Type: neural.INPUT, X: 0.5, Y: 0},
+		3: {Marker: 3, Type: neural.INPUT, X: 1, Y: 0},
+		4: {Marker: 4, Type: neural.OUTPUT, X: 0.5, Y: 1},
+		5: {Marker: 5, Type: neural.HIDDEN, X: 0.5, Y: 0.5},
+	}, Conns: neat.ConnGeneMap{
+		1: {Marker: 1, Source: 2, Target: 5, Weight: 1, Enabled: true},
+		2: {Marker: 2, Source: 3, Target: 5, Weight: -1, Enabled: true},
+		3: {Marker: 3, Source: 5, Target: 4, Weight: 2, Enabled: true},
+		4: {Marker: 4, Source: 1, Target: 4, Weight: 0.5, Enabled: true},
+	}}
+	id := func(x float64) float64 { return x }
+	for _, c := range []struct {
+		hidden, output string
+		h, o           func(float64) float64
+	}{
+		{"sigmoid", "linear", sigmoid, id},
+		{"", "", sigmoid, sigmoid},
+		{"linear", "sigmoid", id, sigmoid},
+		{"linear", "linear", id, id},
+	} {
+		s := neat.SettingsForXOR()
+		s.HiddenActivation, s.OutputActivation = c.hidden, c.output
+		if err := s.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range []neat.Decoder{decoder.NewNEATFor(s), decoder.NewCompiledFor(s)} {
+			p, err := d.Decode(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, in := range [][]float64{{0, 0}, {1, 0}, {0, 1}, {3, -2}} {
+				want := c.o(2*c.h(in[0]-in[1]) + 0.5)
+				got, err := p.Analyze(in)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(got[0]-want) > 1e-12 {
+					t.Errorf("%T hidden %q output %q: %v gave %v, want %v", d, c.hidden, c.output, in, got[0], want)
+				}
+			}
+		}
+	}
+
+	s := neat.SettingsForXOR()
+	s.OutputActivation = "tanh"
+	if err := s.Validate(); err == nil {
+		t.Error("Validate accepted an unknown activation")
+	}
+}
+
+// A cycle which slipped into a genome is named by the feedforward decoders
+func TestDecodeCycle(t *testing.T) {
+	g := &neat.Genome{ID: 9, Nodes: neat.NodeGeneMap{
+		1: {Marker: 1, Type: neural.BIAS, X: 0, Y: 0},
+		2: {Marker: 2, Type: neural.INPUT, X: 0.5, Y: 0},
+		3: {Marker: 3, Type: neural.INPUT, X: 1, Y: 0},
+		4: {Marker: 4, Type: neural.OUTPUT, X: 0.5, Y: 1},
+		5: {Marker: 5, Type: neural.HIDDEN, X: 0.25, Y: 0.5},
+		6: {Marker: 6, Type: neural.HIDDEN, X: 0.75, Y: 0.5},
+	}, Conns: neat.ConnGeneMap{
+		1: {Marker: 1, Source: 2, Target: 5, Weight: 1, Enabled: true},
+		2: {Marker: 2, Source: 5, Target: 6, Weight: 1, Enabled: true},
+		3: {Marker: 3, Source: 6, Target: 5, Weight: 1, Enabled: true},
+		4: {Marker: 4, Source: 6, Target: 4, Weight: 1, Enabled: true},
+	}}
+	s := neat.SettingsForXOR()
+	for _, d := range []neat.Decoder{decoder.NewNEATFor(s), decoder.NewCompiledFor(s)} {
+		_, err := d.Decode(g)
+		if err == nil || !strings.Contains(err.Error(), "connections [2 3]") ||
+			!strings.Contains(err.Error(), "nodes [5 6]") {
+			t.Errorf("%T gave error %v", d, err)
+		}
+	}
+
+	// Disabling a link of the cycle, or allowing recurrence, lets it decode
+	g.Conns[3].Enabled = false
+	if _, err := decoder.NewNEATFor(s).Decode(g); err != nil {
+		t.Error(err)
+	}
+	g.Conns[3].Enabled = true
+	s.AllowRecurrent = true
+	if _, err := decoder.NewCompiledFor(s).Decode(g); err != nil {
+		t.Error(err)
+	}
+}
+
+// Crosses tied parents holding opposite links between two hidden nodes and
+// decodes the child, which should be feedforward
+func TestDecodeCrossedParents(t *testing.T) {
+	parent := func(id int, link *neat.ConnGene) *neat.Organism {
+		g := &neat.Genome{ID: id, Fitness: []float64{1}, Nodes: neat.NodeGeneMap{
+			1: {Marker: 1, Type: neural.BIAS, X: 0, Y: 0},
+			2: {Marker: 2, Type: neural.INPUT, X: 0.5, Y: 0},
+			3: {Marker: 3, Type: neural.INPUT, X: 1, Y: 0},
+			4: {Marker: 4, Type: neural.OUTPUT, X: 0.5, Y: 1},
+			5: {Marker: 5, Type: neural.HIDDEN, X: 0.25, Y: 0.5},
+			6: {Marker: 6, Type: neural.HIDDEN, X: 0.75, Y: 0.5},
+		}, Conns: neat.ConnGeneMap{
+			1: {Marker: 1, Source: 2, Target: 5, Weight: 1, Enabled: true},
+			2: {Marker: 2, Source: 6, Target: 4, Weight: 1, Enabled: true},
+		}}
+		g.Conns[link.Marker] = link
+		return &neat.Organism{Genome: g}
+	}
+	p1 := parent(1, &neat.ConnGene{Marker: 3, Source: 5, Target: 6, Weight: 1, Enabled: true})
+	p2 := parent(2, &neat.ConnGene{Marker: 4, Source: 6, Target: 5, Weight: 1, Enabled: true})
+	s := neat.SettingsForXOR()
+	inno := neat.NewInnovation(nil)
+	defer inno.Close()
+	d := decoder.NewNEATFor(s)
+	for i := 0; i < 100; i++ {
+		child, err := neat.BreedWith(s, inno, p1, p2, neat.BreedCrossover)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = d.Decode(child.Genome); err != nil {
+			t.Fatalf("Child %d: %v", i, err)
+		}
+	}
+}
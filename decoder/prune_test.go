@@ -0,0 +1,130 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder_test
+
+import (
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neural"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Adds a hidden node fed by the first input which feeds nothing, and one no
+// sensor reaches which feeds every output
+func addDeadStructure(r *rand.Rand, g *neat.Genome) {
+	conn := func(s, t int) {
+		m := len(g.Conns) + 1
+		g.Conns[m] = &neat.ConnGene{Marker: m, Source: s, Target: t, Weight: 2 * r.NormFloat64(), Enabled: true}
+	}
+	dead := len(g.Nodes) + 1
+	g.Nodes[dead] = &neat.NodeGene{Marker: dead, Type: neural.HIDDEN, X: 0.5, Y: 0.5, Response: 1}
+	conn(2, dead)
+	constant := len(g.Nodes) + 1
+	g.Nodes[constant] = &neat.NodeGene{Marker: constant, Type: neural.HIDDEN, X: 0.5, Y: 0.1, Response: 1}
+	for _, ng := range g.Nodes {
+		if ng.Type == neural.OUTPUT {
+			conn(constant, ng.Marker)
+		}
+	}
+}
+
+func TestPrunePreservesOutputs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := neat.SettingsForXOR()
+	for _, hidden := range []neural.FuncType{neural.SIGMOID, neural.DIRECT} {
+		s.HiddenActivation = "sigmoid"
+		if hidden == neural.DIRECT {
+			s.HiddenActivation = "linear"
+		}
+		d := decoder.NewCompiledFor(s)
+		var total neat.PruneReport
+		for i := 0; i < 50; i++ {
+			g := randomGenome(r, 3, 8, 2)
+			addDeadStructure(r, g)
+			disabled := 0
+			for _, cg := range g.Conns {
+				if !cg.Enabled {
+					disabled += 1
+				}
+			}
+			pruned, report := g.PruneWith(hidden)
+
+			// Count what went and check what must stay
+			if report.Disabled != disabled {
+				t.Errorf("%s genome %d: reported %d disabled connections, want %d", s.HiddenActivation, i,
+					report.Disabled, disabled)
+			}
+			if n := len(g.Nodes) - report.Dead - report.Constant; len(pruned.Nodes) != n {
+				t.Errorf("%s genome %d: pruned genome has %d nodes, want %d", s.HiddenActivation, i,
+					len(pruned.Nodes), n)
+			}
+			for _, ng := range g.Nodes {
+				if _, ok := pruned.Nodes[ng.Marker]; !ok && ng.Type != neural.HIDDEN {
+					t.Fatalf("%s genome %d: pruned node %d of type %v", s.HiddenActivation, i, ng.Marker, ng.Type)
+				}
+			}
+			for _, cg := range pruned.Conns {
+				if !cg.Enabled {
+					t.Fatalf("%s genome %d: kept disabled connection %d", s.HiddenActivation, i, cg.Marker)
+				}
+			}
+			total.Disabled += report.Disabled
+			total.Dead += report.Dead
+			total.Constant += report.Constant
+			total.Attached += report.Attached
+
+			// And compare the networks
+			p1, err := d.Decode(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p2, err := d.Decode(pruned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 20; j++ {
+				in := randomInputs(r, 3)
+				o1, err1 := p1.Analyze(in)
+				o2, err2 := p2.Analyze(in)
+				if err1 != nil || err2 != nil {
+					t.Fatal(err1, err2)
+				}
+				for k := range o1 {
+					if math.Abs(o1[k]-o2[k]) > 1e-9 {
+						t.Fatalf("%s genome %d, inputs %v: gave %v before pruning, %v after", s.HiddenActivation,
+							i, in, o1, o2)
+					}
+				}
+			}
+		}
+		if total.Disabled == 0 || total.Dead == 0 || total.Constant == 0 || total.Attached == 0 {
+			t.Errorf("%s: pruning never exercised every case: %v", s.HiddenActivation, total)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// A connection gene found in both genomes
+type GeneDelta struct {
+	Marker           int     // Innovation marker of the gene
+	WeightA, WeightB float64 // Weights in each genome
+	Delta            float64 // WeightB - WeightA
+}
+
+// How two genomes differ, as seen by the compatibility distance
+type GenomeDiff struct {
+	Matching             []GeneDelta // Connection genes in both genomes
+	DisjointA, DisjointB []int       // Markers of the disjoint connection genes of each genome
+	ExcessA, ExcessB     []int       // Markers of the excess connection genes of each genome
+	NodesOnlyA           []int       // Markers of the node genes only in genome a
+	NodesOnlyB           []int       // Markers of the node genes only in genome b
+
+	// Terms of the compatibility distance, already multiplied by their
+	// coefficients, and their sum. Weight includes the response difference
+	// of the matching nodes.
+	Excess, Disjoint, Weight float64
+	Distance                 float64
+}
+
+// Compares the genomes gene by gene, breaking down their compatibility
+// distance using the coefficients in the settings. As in speciation, excess
+// genes are those of the genome with more connections beyond the other's
+// last marker.
+func DiffGenomes(settings *Settings, a, b *Genome) (diff GenomeDiff) {
+
+	// Look first at the genome with the most conn genes
+	big, small := a, b
+	swapped := len(a.Conns) < len(b.Conns)
+	if swapped {
+		big, small = b, a
+	}
+	mm := 0 // Max marker in the smaller genome
+	for _, cg := range small.Conns {
+		if cg.Marker > mm {
+			mm = cg.Marker
+		}
+	}
+
+	// Classify the connection genes
+	var disjointBig, excessBig, disjointSmall []int
+	var w float64
+	for _, k := range big.Conns.sortedMarkers() {
+		cg1 := big.Conns[k]
+		if cg2, ok := small.Conns[k]; ok {
+			ga, gb := cg1, cg2
+			if swapped {
+				ga, gb = cg2, cg1
+			}
+			diff.Matching = append(diff.Matching, GeneDelta{Marker: k, WeightA: ga.Weight, WeightB: gb.Weight,
+				Delta: gb.Weight - ga.Weight})
+			w += math.Abs(cg1.Weight - cg2.Weight)
+		} else if k > mm {
+			excessBig = append(excessBig, k)
+		} else {
+			disjointBig = append(disjointBig, k)
+		}
+	}
+	for _, k := range small.Conns.sortedMarkers() {
+		if _, ok := big.Conns[k]; !ok {
+			disjointSmall = append(disjointSmall, k)
+		}
+	}
+	if swapped {
+		diff.DisjointA, diff.DisjointB, diff.ExcessB = disjointSmall, disjointBig, excessBig
+	} else {
+		diff.DisjointA, diff.DisjointB, diff.ExcessA = disjointBig, disjointSmall, excessBig
+	}
+
+	// Compare the node genes
+	for _, k := range a.Nodes.sortedMarkers() {
+		if _, ok := b.Nodes[k]; !ok {
+			diff.NodesOnlyA = append(diff.NodesOnlyA, k)
+		}
+	}
+	for _, k := range b.Nodes.sortedMarkers() {
+		if _, ok := a.Nodes[k]; !ok {
+			diff.NodesOnlyB = append(diff.NodesOnlyB, k)
+		}
+	}
+
+	// Break down the distance
+	if len(diff.Matching) > 0 {
+		w = w / float64(len(diff.Matching))
+	}
+	diff.Excess = settings.ExcessCoefficient * float64(len(excessBig))
+	diff.Disjoint = settings.DisjointCoefficient * float64(len(disjointBig)+len(disjointSmall))
+	diff.Weight = settings.WeightCoefficient * w
+	if settings.ResponseCoefficient > 0 {
+		diff.Weight += settings.ResponseCoefficient * responseDifference(a, b)
+	}
+	diff.Distance = diff.Excess + diff.Disjoint + diff.Weight
+	return
+}
+
+// Describes the difference over several lines, suitable for logs
+func (d GenomeDiff) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Distance %.4f = excess %.4f + disjoint %.4f + weight %.4f\n", d.Distance,
+		d.Excess, d.Disjoint, d.Weight)
+	fmt.Fprintf(&b, "Matching: %d genes\n", len(d.Matching))
+	for _, m := range d.Matching {
+		if m.Delta != 0 {
+			fmt.Fprintf(&b, "  [%4d] %+8.6f -> %+8.6f (%+8.6f)\n", m.Marker, m.WeightA, m.WeightB, m.Delta)
+		}
+	}
+	fmt.Fprintf(&b, "Disjoint: a %v, b %v\n", d.DisjointA, d.DisjointB)
+	fmt.Fprintf(&b, "Excess:   a %v, b %v\n", d.ExcessA, d.ExcessB)
+	fmt.Fprintf(&b, "Nodes:    only a %v, only b %v", d.NodesOnlyA, d.NodesOnlyB)
+	return b.String()
+}
@@ -0,0 +1,91 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Returns two genomes differing in every way DiffGenomes reports
+func diffGenomes() (a, b *Genome) {
+	a = testGenome(1, 0,
+		testConn{1, 1, 4, 1}, testConn{2, 2, 4, 2}, testConn{3, 3, 4, 0.5},
+		testConn{6, 2, 5, 0.1}, testConn{7, 5, 4, 0.2})
+	b = testGenome(2, 0,
+		testConn{1, 1, 4, 1.5}, testConn{2, 2, 4, 1}, testConn{4, 3, 6, 0.3})
+	return
+}
+
+func TestDiffGenomes(t *testing.T) {
+	s := SettingsForXOR()
+	s.ExcessCoefficient, s.DisjointCoefficient, s.WeightCoefficient = 1, 2, 0.5
+	s.ResponseCoefficient, s.ModuleCoefficient = 0, 0
+	a, b := diffGenomes()
+
+	d := DiffGenomes(s, a, b)
+	want := GenomeDiff{
+		Matching: []GeneDelta{
+			{Marker: 1, WeightA: 1, WeightB: 1.5, Delta: 0.5},
+			{Marker: 2, WeightA: 2, WeightB: 1, Delta: -1},
+		},
+		DisjointA: []int{3}, DisjointB: []int{4},
+		ExcessA:    []int{6, 7},
+		NodesOnlyA: []int{5}, NodesOnlyB: []int{6},
+		Excess: 2, Disjoint: 4, Weight: 0.375, Distance: 6.375,
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Diff\n%#v\nwant\n%#v", d, want)
+	}
+
+	// Swapping the genomes swaps the sides, leaving the distance
+	r := DiffGenomes(s, b, a)
+	if !reflect.DeepEqual(r.DisjointA, []int{4}) || !reflect.DeepEqual(r.DisjointB, []int{3}) ||
+		!reflect.DeepEqual(r.ExcessB, []int{6, 7}) || r.ExcessA != nil {
+		t.Errorf("Swapped diff has disjoint %v %v and excess %v %v", r.DisjointA, r.DisjointB,
+			r.ExcessA, r.ExcessB)
+	}
+	if r.Matching[0].Delta != -0.5 || r.Distance != d.Distance {
+		t.Errorf("Swapped diff has delta %v and distance %v", r.Matching[0].Delta, r.Distance)
+	}
+
+	// The breakdown adds up to the distance used by speciation
+	a.Nodes[4].Response, b.Nodes[4].Response = 1, 3
+	for _, rc := range []float64{0, 0.5} {
+		s.ResponseCoefficient = rc
+		d := DiffGenomes(s, a, b)
+		if x := distance(s, &Organism{Genome: a}, &Organism{Genome: b}); math.Abs(d.Distance-x) > 1e-12 {
+			t.Errorf("Response coefficient %v: diff distance %v, speciation %v", rc, d.Distance, x)
+		}
+	}
+
+	if lines := strings.Split(d.String(), "\n"); len(lines) != 7 || !strings.HasPrefix(lines[0], "Distance 6.3750") {
+		t.Errorf("String\n%s", d)
+	}
+}
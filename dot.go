@@ -0,0 +1,81 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/boggo/neural"
+	"io"
+)
+
+// Writes the genome as a Graphviz DOT graph. Nodes are labelled with their
+// names, when they have one, or their markers. Disabled connections are
+// drawn dashed.
+func (g *Genome) WriteDOT(w io.Writer) (err error) {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph genome_%d {\n", g.ID)
+	fmt.Fprintf(b, "\trankdir=BT;\n")
+
+	// Write the nodes
+	for _, k := range g.Nodes.sortedMarkers() {
+		ng := g.Nodes[k]
+		label := ng.Name
+		if label == "" {
+			label = fmt.Sprintf("%d", ng.Marker)
+		}
+		var shape string
+		switch ng.Type {
+		case neural.BIAS:
+			shape = "diamond"
+		case neural.INPUT:
+			shape = "box"
+		case neural.OUTPUT:
+			shape = "doublecircle"
+		default:
+			shape = "circle"
+		}
+		if r := ng.EffectiveResponse(); r != 1.0 {
+			label = fmt.Sprintf("%s\nr=%.3f", label, r)
+		}
+		fmt.Fprintf(b, "\tn%d [label=%q, shape=%s];\n", ng.Marker, label, shape)
+	}
+
+	// Write the connections
+	for _, k := range g.Conns.sortedMarkers() {
+		cg := g.Conns[k]
+		style := "solid"
+		if !cg.Enabled {
+			style = "dashed"
+		}
+		fmt.Fprintf(b, "\tn%d -> n%d [label=\"%.3f\", style=%s];\n", cg.Source, cg.Target, cg.Weight, style)
+	}
+
+	fmt.Fprintf(b, "}\n")
+	err = b.Flush()
+	return
+}
@@ -0,0 +1,137 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A field whose value differs between two settings
+type FieldDiff struct {
+	Field string      // Name of the field of Settings
+	A, B  interface{} // Values in the first and second settings
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v -> %v", d.Field, d.A, d.B)
+}
+
+// Returns the fields of the settings whose values differ, in the order of
+// their declaration. Only the persisted fields are compared, leaving out the
+// runtime extensions such as Collectors and Hooks.
+func DiffSettings(a, b *Settings) (diffs []FieldDiff) {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue // Unexported or not persisted
+		}
+		x, y := va.Field(i).Interface(), vb.Field(i).Interface()
+		if !reflect.DeepEqual(x, y) {
+			diffs = append(diffs, FieldDiff{Field: f.Name, A: x, B: y})
+		}
+	}
+	return
+}
+
+// Returns a copy of the persisted settings, without the runtime extensions,
+// for embedding in an archived population
+func (s *Settings) snapshot() *Settings {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	snap := new(Settings)
+	if json.Unmarshal(b, snap) != nil {
+		return nil
+	}
+	return snap
+}
+
+// Compares the settings a restored population was archived with to those it
+// resumes with. Changes which the population cannot carry on under are an
+// error: different sensor or output counts, a PopulationSize below the
+// organisms it holds, unless it was resized, or recurrent connections turned off while its genomes
+// have cycles. Other changes are logged as a warning, or printed without a
+// Logger. A rate with a schedule in either settings is not compared, as the
+// schedule sets it each generation. A population archived without its
+// settings is taken as it is.
+func checkDrift(settings *Settings, pop *Population) error {
+	if pop.Settings == nil {
+		return nil
+	}
+	scheduled := make(map[string]bool)
+	for _, s := range []*Settings{pop.Settings, settings} {
+		for _, rs := range s.Schedules {
+			scheduled[rs.Rate] = true
+		}
+	}
+	var problems, changes []string
+	for _, d := range DiffSettings(pop.Settings, settings) {
+		if scheduled[d.Field] {
+			continue
+		}
+		switch d.Field {
+		case "BiasCount", "InputCount", "OutputCount":
+			problems = append(problems, fmt.Sprintf("%s changed from %v to %v", d.Field, d.A, d.B))
+		case "PopulationSize":
+			if n := len(pop.Organisms()); pop.Size == 0 && settings.PopulationSize < n {
+				problems = append(problems, fmt.Sprintf("PopulationSize of %d is below the %d organisms archived",
+					settings.PopulationSize, n))
+			}
+		case "AllowRecurrent":
+			if !settings.AllowRecurrent {
+				for _, o := range pop.Organisms() {
+					if o.Genome.Cycle() != nil {
+						problems = append(problems, fmt.Sprintf(
+							"AllowRecurrent was turned off but genome %d has a cycle", o.ID))
+						break
+					}
+				}
+			}
+		}
+		changes = append(changes, d.String())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("Cannot resume generation %d with these settings: %s", pop.Generation,
+			strings.Join(problems, "; "))
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if settings.Logger == nil {
+		fmt.Println("Settings differ from those archived:", strings.Join(changes, "; "))
+		return nil
+	}
+	settings.log().Warn("settings differ from those archived", "generation", pop.Generation,
+		"changes", strings.Join(changes, "; "))
+	return nil
+}
@@ -0,0 +1,164 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Archiver keeping a copy of the latest population in memory
+type memoryArchiver struct{ pop *Population }
+
+func (a *memoryArchiver) Archive(pop *Population) error {
+	a.pop = pop.Copy()
+	return nil
+}
+
+func (a *memoryArchiver) Restore() (*Population, error) {
+	if a.pop == nil {
+		return nil, errors.New("Nothing archived")
+	}
+	return a.pop.Copy(), nil
+}
+
+// Returns what f prints to standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestDiffSettings(t *testing.T) {
+	a, b := testSettings(), testSettings()
+	b.MutateWeight, b.InputCount = 0.5, 3
+	b.Logger = &recordingLogger{}
+	diffs := DiffSettings(a, b)
+	if len(diffs) != 2 || diffs[0].Field != "InputCount" || diffs[1].Field != "MutateWeight" {
+		t.Fatalf("Got diffs %v", diffs)
+	}
+	if d := diffs[1]; d.A != a.MutateWeight || d.B != 0.5 {
+		t.Errorf("MutateWeight diff is %v", d)
+	}
+	if diffs := DiffSettings(a, a.snapshot()); len(diffs) != 0 {
+		t.Errorf("Snapshot differs: %v", diffs)
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	archived := testSettings()
+	archived.Schedules = []RateSchedule{{Rate: "MutateAddNode", Start: 0.1, End: 0.01, Length: 10}}
+	pop, inno := evaluatedPopulation(t, testSettings())
+	defer inno.close()
+	cyclic := pop.Copy()
+	o := cyclic.Species[0].Orgs[0]
+	o.Conns[100] = &ConnGene{Marker: 100, Source: 4, Target: 4, Enabled: true} // Output 4 feeds itself
+
+	for _, c := range []struct {
+		name    string
+		pop     *Population
+		change  func(s *Settings)
+		problem string // Part of the error, if the change is one
+		warned  bool
+	}{
+		{"same", pop, func(s *Settings) {}, "", false},
+		{"safe", pop, func(s *Settings) { s.MutateWeight, s.CompatThreshold = 0.5, 2 }, "", true},
+		{"scheduled", pop, func(s *Settings) { s.MutateAddNode = 0.9 }, "", false},
+		{"inputs", pop, func(s *Settings) { s.InputCount = 3 }, "InputCount changed from 2 to 3", false},
+		{"smaller", pop, func(s *Settings) { s.PopulationSize = 10 }, "PopulationSize of 10 is below the 50", false},
+		{"larger", pop, func(s *Settings) { s.PopulationSize = 100 }, "", true},
+		{"recurrent", cyclic, func(s *Settings) { s.AllowRecurrent = false }, "has a cycle", false},
+	} {
+		archived.AllowRecurrent = c.pop == cyclic
+		c.pop.Settings = archived.snapshot()
+		s := archived.snapshot()
+		c.change(s)
+		log := &recordingLogger{}
+		s.Logger = log
+		err := checkDrift(s, c.pop)
+		switch {
+		case c.problem == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.problem != "" && (err == nil || !strings.Contains(err.Error(), c.problem)):
+			t.Errorf("%s: got %v, want an error naming %q", c.name, err, c.problem)
+		case len(log.events["settings differ from those archived"]) > 0 != c.warned:
+			t.Errorf("%s: warned %v, want %v", c.name, log.events, c.warned)
+		}
+
+		// Without a logger the warning is printed
+		s.Logger = nil
+		out := captureStdout(t, func() { checkDrift(s, c.pop) })
+		if strings.Contains(out, "Settings differ") != c.warned {
+			t.Errorf("%s: printed %q", c.name, out)
+		}
+	}
+}
+
+// Archived settings are those the run was given, not the rates it moved
+func TestArchivedSettings(t *testing.T) {
+	settings := func() *Settings {
+		s := testSettings()
+		s.Schedules = []RateSchedule{{Rate: "Crossover", Start: 0.8, End: 0.2, Length: 5}}
+		s.PruneThreshold, s.PruneFloor, s.MutateDelNode = 0.5, 2, 0.1 // Phases zero the rates they do not use
+		return s
+	}
+	arch := &memoryArchiver{}
+	s := settings()
+	if _, _, err := Train(s, 8, genomeDecoder{}, serialEval{}, weightEval{}, arch, nil); err != nil {
+		t.Fatal(err)
+	}
+	if diffs := DiffSettings(settings(), arch.pop.Settings); len(diffs) != 0 {
+		t.Errorf("Archived settings differ from those given: %v", diffs)
+	}
+
+	// And the run resumes quietly
+	log := &recordingLogger{}
+	s = settings()
+	s.Logger = log
+	if _, _, err := Train(s, 2, genomeDecoder{}, serialEval{}, weightEval{}, arch, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w := log.events["settings differ from those archived"]; len(w) > 0 {
+		t.Errorf("Resuming warned of %v", w)
+	}
+}
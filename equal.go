@@ -0,0 +1,252 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+)
+
+// Tolerance used by Equal when comparing weights, positions and fitness
+const equalTolerance = 1e-9
+
+// Returns a deep copy of the population sharing no pointers with the
+// original. A species' example which is one of its organisms remains one of
+// the copied organisms.
+func (pop *Population) Copy() *Population {
+	clone := &Population{Generation: pop.Generation, Size: pop.Size, counters: pop.counters,
+		Species: make([]*Species, len(pop.Species))}
+	if pop.Settings != nil {
+		clone.Settings = pop.Settings.snapshot()
+	}
+	for i, s := range pop.Species {
+		cs := &Species{ID: s.ID, Age: s.Age, CreatedAt: s.CreatedAt, BestFitness: s.BestFitness, BestFitAge: s.BestFitAge,
+			Offspring: s.Offspring, Layer: s.Layer, Meta: s.Meta.Copy(), currFitness: s.currFitness,
+			Trail: s.trailCopy(), TrailHead: s.TrailHead, Threshold: s.Threshold,
+			Orgs: make([]*Organism, len(s.Orgs))}
+		for j, o := range s.Orgs {
+			cs.Orgs[j] = o.Copy()
+			if o == s.Example {
+				cs.Example = cs.Orgs[j]
+			}
+		}
+		if cs.Example == nil && s.Example != nil {
+			cs.Example = s.Example.Copy()
+		}
+		clone.Species[i] = cs
+	}
+	return clone
+}
+
+// Returns true if the populations are structurally equal, comparing floating
+// point values within a small tolerance
+func (pop *Population) Equal(other *Population) bool {
+	return pop.Difference(other, equalTolerance) == ""
+}
+
+// Returns the path of the first difference found between the populations,
+// such as "Species[2].Orgs[5].Conns[3].Weight", or an empty string if there
+// is none. Floating point values are equal within the tolerance. Conns and
+// Nodes are indexed by marker. Metadata and phenomes are not compared.
+func (pop *Population) Difference(other *Population, tol float64) string {
+	switch {
+	case pop == nil || other == nil:
+		if pop != other {
+			return "Population"
+		}
+		return ""
+	case pop.Generation != other.Generation:
+		return "Generation"
+	case pop.Size != other.Size:
+		return "Size"
+	case len(pop.Species) != len(other.Species):
+		return "len(Species)"
+	}
+	for i, s := range pop.Species {
+		if d := s.difference(other.Species[i], tol); d != "" {
+			return fmt.Sprintf("Species[%d].%s", i, d)
+		}
+	}
+	return ""
+}
+
+func (s *Species) difference(other *Species, tol float64) string {
+	switch {
+	case s.ID != other.ID:
+		return "ID"
+	case s.Age != other.Age:
+		return "Age"
+	case s.CreatedAt != other.CreatedAt:
+		return "CreatedAt"
+	case !floatEqual(s.BestFitness, other.BestFitness, tol):
+		return "BestFitness"
+	case s.BestFitAge != other.BestFitAge:
+		return "BestFitAge"
+	case s.Offspring != other.Offspring:
+		return "Offspring"
+	case s.Layer != other.Layer:
+		return "Layer"
+	case !floatEqual(s.Threshold, other.Threshold, tol):
+		return "Threshold"
+	case (s.Example == nil) != (other.Example == nil):
+		return "Example"
+	case len(s.Orgs) != len(other.Orgs):
+		return "len(Orgs)"
+	case len(s.Trail) != len(other.Trail):
+		return "len(History)"
+	}
+	oh := other.History()
+	for i, p := range s.History() {
+		q := oh[i]
+		if p.Generation != q.Generation || !floatEqual(p.Best, q.Best, tol) || !floatEqual(p.Mean, q.Mean, tol) {
+			return fmt.Sprintf("History[%d]", i)
+		}
+	}
+	if s.Example != nil {
+		if d := s.Example.difference(other.Example, tol); d != "" {
+			return "Example." + d
+		}
+	}
+	for i, o := range s.Orgs {
+		if d := o.difference(other.Orgs[i], tol); d != "" {
+			return fmt.Sprintf("Orgs[%d].%s", i, d)
+		}
+	}
+	return ""
+}
+
+func (org *Organism) difference(other *Organism, tol float64) string {
+	if d := floatsDifference(org.Behavior, other.Behavior, tol); d != "" {
+		return "Behavior" + d
+	}
+	if d := floatsDifference(org.Cases, other.Cases, tol); d != "" {
+		return "Cases" + d
+	}
+	return org.Genome.difference(other.Genome, tol)
+}
+
+func (g *Genome) difference(other *Genome, tol float64) string {
+	switch {
+	case g.ID != other.ID:
+		return "ID"
+	case len(g.Nodes) != len(other.Nodes):
+		return "len(Nodes)"
+	case len(g.Conns) != len(other.Conns):
+		return "len(Conns)"
+	case (g.Profile == nil) != (other.Profile == nil):
+		return "Profile"
+	}
+	if d := floatsDifference(g.Fitness, other.Fitness, tol); d != "" {
+		return "Fitness" + d
+	}
+	for _, k := range g.Nodes.sortedMarkers() {
+		n1 := g.Nodes[k]
+		n2, ok := other.Nodes[k]
+		var d string
+		switch {
+		case !ok:
+			d = ""
+		case n1.Marker != n2.Marker:
+			d = ".Marker"
+		case n1.Type != n2.Type:
+			d = ".Type"
+		case !floatEqual(n1.X, n2.X, tol):
+			d = ".X"
+		case !floatEqual(n1.Y, n2.Y, tol):
+			d = ".Y"
+		case n1.Name != n2.Name:
+			d = ".Name"
+		case n1.Frozen != n2.Frozen:
+			d = ".Frozen"
+		case n1.Module != n2.Module:
+			d = ".Module"
+		case !floatEqual(n1.EffectiveResponse(), n2.EffectiveResponse(), tol):
+			d = ".Response"
+		default:
+			continue
+		}
+		return fmt.Sprintf("Nodes[%d]%s", k, d)
+	}
+	for _, k := range g.Conns.sortedMarkers() {
+		c1 := g.Conns[k]
+		c2, ok := other.Conns[k]
+		var d string
+		switch {
+		case !ok:
+			d = ""
+		case c1.Marker != c2.Marker:
+			d = ".Marker"
+		case c1.Source != c2.Source:
+			d = ".Source"
+		case c1.Target != c2.Target:
+			d = ".Target"
+		case !floatEqual(c1.Weight, c2.Weight, tol):
+			d = ".Weight"
+		case c1.Enabled != c2.Enabled:
+			d = ".Enabled"
+		case c1.Frozen != c2.Frozen:
+			d = ".Frozen"
+		case c1.Split != c2.Split:
+			d = ".Split"
+		default:
+			continue
+		}
+		return fmt.Sprintf("Conns[%d]%s", k, d)
+	}
+	if g.Profile != nil {
+		m1, m2 := g.Profile.multipliers(), other.Profile.multipliers()
+		for i := range m1 {
+			if !floatEqual(*m1[i], *m2[i], tol) {
+				return fmt.Sprintf("Profile[%d]", i)
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the index of the first difference between the slices, as "[i]", or
+// "" if there is none. A difference in length is reported as ".len".
+func floatsDifference(a, b []float64, tol float64) string {
+	if len(a) != len(b) {
+		return ".len"
+	}
+	for i := range a {
+		if !floatEqual(a[i], b[i], tol) {
+			return fmt.Sprintf("[%d]", i)
+		}
+	}
+	return ""
+}
+
+// Returns true if the values are within the tolerance of each other. NaN is
+// equal to NaN so that populations holding it can still be compared.
+func floatEqual(a, b, tol float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b || math.Abs(a-b) <= tol
+}
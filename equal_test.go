@@ -0,0 +1,116 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"github.com/boggo/neural"
+	"testing"
+)
+
+func TestPopulationCopy(t *testing.T) {
+	pop := reportPopulation()
+	orig := reportPopulation()
+	cp := pop.Copy()
+	if d := pop.Difference(cp, 0); d != "" {
+		t.Fatalf("Copy differs at %s", d)
+	}
+
+	// The copy shares no organism or gene with the original, yet keeps each
+	// example aliasing its member
+	ptrs := make(map[interface{}]bool)
+	for _, s := range pop.Species {
+		ptrs[s] = true
+		for _, o := range s.Orgs {
+			ptrs[o], ptrs[o.Genome] = true, true
+			for _, cg := range o.Conns {
+				ptrs[cg] = true
+			}
+			for _, ng := range o.Nodes {
+				ptrs[ng] = true
+			}
+		}
+	}
+	for i, s := range cp.Species {
+		if ptrs[s] || ptrs[s.Example] {
+			t.Errorf("Species[%d] shares pointers with the original", i)
+		}
+		if s.Example != s.Orgs[0] {
+			t.Errorf("Species[%d].Example no longer aliases its member", i)
+		}
+		for j, o := range s.Orgs {
+			if ptrs[o] || ptrs[o.Genome] {
+				t.Errorf("Species[%d].Orgs[%d] shares pointers with the original", i, j)
+			}
+			for _, cg := range o.Conns {
+				if ptrs[cg] {
+					t.Errorf("Species[%d].Orgs[%d].Conns[%d] is shared", i, j, cg.Marker)
+				}
+			}
+			for _, ng := range o.Nodes {
+				if ptrs[ng] {
+					t.Errorf("Species[%d].Orgs[%d].Nodes[%d] is shared", i, j, ng.Marker)
+				}
+			}
+		}
+	}
+
+	// Changing the copy leaves the original alone
+	cp.Species[1].Orgs[0].Conns[3].Weight = 10
+	cp.Species[0].Example.Fitness[0] = 10
+	if d := pop.Difference(orig, 0); d != "" {
+		t.Errorf("Changing the copy changed the original at %s", d)
+	}
+}
+
+func TestPopulationDifference(t *testing.T) {
+	cases := []struct {
+		change func(*Population)
+		want   string
+	}{
+		{func(p *Population) {}, ""},
+		{func(p *Population) { p.Generation++ }, "Generation"},
+		{func(p *Population) { p.Species = p.Species[:1] }, "len(Species)"},
+		{func(p *Population) { p.Species[0].Orgs[1].Conns[1].Weight += 0.5 }, "Species[0].Orgs[1].Conns[1].Weight"},
+		{func(p *Population) { p.Species[0].Orgs[1].Conns[1].Weight += 1e-12 }, ""},
+		{func(p *Population) { p.Species[0].Orgs[1].Fitness[0] = 9 }, "Species[0].Orgs[1].Fitness[0]"},
+		{func(p *Population) { p.Species[1].Orgs[0].Conns[3].Weight = 0 }, "Species[1].Example.Conns[3].Weight"},
+		{func(p *Population) { delete(p.Species[0].Orgs[1].Conns, 1) }, "Species[0].Orgs[1].len(Conns)"},
+		{func(p *Population) { p.Species[0].Example = p.Species[0].Orgs[1] }, "Species[0].Example.ID"},
+		{func(p *Population) { p.Species[1].Orgs[1].Nodes[4].Type = neural.INPUT }, "Species[1].Orgs[1].Nodes[4].Type"},
+	}
+	for i, c := range cases {
+		p := reportPopulation()
+		c.change(p)
+		got := reportPopulation().Difference(p, equalTolerance)
+		if got != c.want {
+			t.Errorf("Case %d: difference %q, want %q", i, got, c.want)
+		}
+		if eq := reportPopulation().Equal(p); eq != (c.want == "") {
+			t.Errorf("Case %d: Equal is %v", i, eq)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReproductionErrors(t *testing.T) {
+	s := testSettings()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	inno := newInnovationAt(100, 100)
+	defer inno.close()
+
+	// A population without organisms to breed from
+	for _, pop := range []*Population{
+		{Generation: 3},
+		{Generation: 3, Species: SpeciesSlice{{ID: 1}, {ID: 2, Orgs: OrganismSlice{}}}},
+	} {
+		if _, err := rollPop(s, inno, pop); !errors.Is(err, ErrEmptyPopulation) {
+			t.Errorf("Rolling %d empty species gave %v, want ErrEmptyPopulation", len(pop.Species), err)
+		}
+		if _, _, err := Reproduce(s, inno, pop); !errors.Is(err, ErrEmptyPopulation) {
+			t.Errorf("Reproducing %d empty species gave %v, want ErrEmptyPopulation", len(pop.Species), err)
+		}
+	}
+
+	// A selector failing to pick a parent
+	orgs := OrganismSlice{&Organism{Genome: testGenome(1, 1, testConn{1, 2, 4, 1})}}
+	sp := &Species{ID: 1, Orgs: orgs, Example: orgs[0]}
+	_, err := breed(s, inno, &listSelector{}, sp, orgs, nil, 3, &rollCounters{})
+	if !errors.Is(err, ErrSelectionFailed) {
+		t.Errorf("Breeding with a failing selector gave %v, want ErrSelectionFailed", err)
+	}
+}
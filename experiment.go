@@ -0,0 +1,310 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Returned by the hook which ends a solved trial, and not reported as an
+// error
+var errTrialSolved = errors.New("Trial solved")
+
+// Options of RunExperiment
+type ExperimentOptions struct {
+	Decoder     Decoder // Decodes the genomes of every trial
+	PopEval     PopEval // Evaluates the populations of every trial
+	Generations int     // Generations in each trial
+	Target      float64 // Fitness at which a trial counts as solved
+	RunOn       bool    // Keep a solved trial running to Generations, instead of stopping it
+	Workers     int     // Trials run at once. 0 = one at a time
+	Seed        int64   // Seed from which the trial seeds are derived. 0 = Settings.Seed, or the clock
+}
+
+// Outcome of a single trial of an experiment
+type TrialResult struct {
+	Trial       int       // Index of the trial
+	Seed        int64     // Seed the trial ran with
+	Solved      bool      // Did the champion reach the target fitness?
+	SolvedIn    int       // Generations taken to reach the target. 0 = not solved
+	Generations int       // Generations run
+	BestFitness float64   // Fitness of the best organism found
+	Complexity  int       // Genes of the best organism found
+	Best        []float64 // Best fitness of each generation
+	Mean        []float64 // Mean fitness of each generation
+	Error       string    `json:",omitempty"` // Error which ended the trial
+	Err         error     `json:"-"`
+}
+
+// Fitness of one generation averaged across the trials, with the standard
+// deviations as bands
+type CurvePoint struct {
+	Generation int     // Generation of the trials, counted from 1
+	Trials     int     // Trials contributing to the point
+	MeanBest   float64 // Mean of the trials' best fitness
+	StddevBest float64 // Standard deviation of the trials' best fitness
+	MeanMean   float64 // Mean of the trials' mean fitness
+	StddevMean float64 // Standard deviation of the trials' mean fitness
+}
+
+// Results of an experiment over several trials. The aggregates leave out
+// trials which failed with an error.
+type ExperimentResult struct {
+	Trials            []TrialResult
+	Failed            int          // Trials which ended with an error
+	SolveRate         float64      // Fraction of the completed trials which were solved
+	MeanGenerations   float64      // Mean generations taken by the solved trials to solve
+	MedianGenerations float64      // Median generations taken by the solved trials to solve
+	MeanComplexity    float64      // Mean genes of the completed trials' best organisms
+	Curve             []CurvePoint // Fitness by generation averaged across the completed trials
+}
+
+// Runs the given number of independent trials of the experiment, each with
+// its own seed derived from the options' Seed, and aggregates their
+// results. Each trial trains a copy of the settings for opts.Generations,
+// stopping early once its champion reaches opts.Target unless opts.RunOn is
+// set. A trial ending with an error is reported in its TrialResult and
+// the others go on.
+//
+// With opts.Workers above 1 trials run at the same time, each with its own
+// random generator, so the decoder, evaluators, collectors and hooks must
+// be safe for concurrent use. A solved trial's curve carries its last
+// fitness on to the end, so that every trial contributes to every point.
+func RunExperiment(settings *Settings, eval OrgEval, trials int, opts ExperimentOptions) (result ExperimentResult, err error) {
+	if trials <= 0 {
+		err = fmt.Errorf("Trials must be positive, not %d", trials)
+		return
+	}
+	if opts.Generations <= 0 {
+		err = fmt.Errorf("Generations must be positive, not %d", opts.Generations)
+		return
+	}
+	if opts.Decoder == nil || opts.PopEval == nil {
+		err = errors.New("ExperimentOptions needs a Decoder and a PopEval")
+		return
+	}
+	if err = settings.Validate(); err != nil {
+		return
+	}
+
+	// Derive the seeds of the trials
+	seed := opts.Seed
+	if seed == 0 {
+		seed = settings.Seed
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := newRng(seed)
+	result.Trials = make([]TrialResult, trials)
+	for i := range result.Trials {
+		result.Trials[i] = TrialResult{Trial: i, Seed: r.Int63()}
+		if result.Trials[i].Seed == 0 {
+			result.Trials[i].Seed = 1 // 0 would seed from the clock
+		}
+	}
+
+	// Run the trials
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var w sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range result.Trials {
+		w.Add(1)
+		sem <- struct{}{}
+		go func(t *TrialResult) {
+			defer func() {
+				<-sem
+				w.Done()
+			}()
+			runTrial(settings, eval, opts, workers > 1, t)
+		}(&result.Trials[i])
+	}
+	w.Wait()
+	result.aggregate(opts.Generations)
+	return
+}
+
+// Runs a single trial, filling in its result
+func runTrial(settings *Settings, eval OrgEval, opts ExperimentOptions, own bool, t *TrialResult) {
+	local := *settings
+	local.Seed = t.Seed
+	if own {
+		local.rng = newRng(t.Seed)
+	}
+
+	// Watch each generation, after the settings' own hook
+	var best *Organism
+	hook := settings.Hooks.OnGenerationEnd
+	local.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		if hook != nil {
+			if err := hook(pop, stats); err != nil {
+				return err
+			}
+		}
+		t.Generations += 1
+		t.Best = append(t.Best, stats.BestFitness)
+		t.Mean = append(t.Mean, stats.MeanFitness)
+		if c := pop.Champion(); c != nil && (best == nil || c.Fitness[0] > best.Fitness[0]) {
+			best = c
+		}
+		if best != nil && !t.Solved && best.Fitness[0] >= opts.Target {
+			t.Solved, t.SolvedIn = true, t.Generations
+			if !opts.RunOn {
+				return errTrialSolved
+			}
+		}
+		return nil
+	}
+
+	_, _, err := TrainContext(context.Background(), &local, opts.Generations, opts.Decoder, opts.PopEval, eval,
+		nil, nil)
+	if err != nil && err != errTrialSolved {
+		t.Err, t.Error = err, err.Error()
+	}
+	if best != nil {
+		t.BestFitness = best.Fitness[0]
+		t.Complexity = len(best.Nodes) + len(best.Conns)
+	}
+}
+
+// Aggregates the completed trials
+func (r *ExperimentResult) aggregate(generations int) {
+	var solvedIn, complexity []float64
+	completed := 0
+	for _, t := range r.Trials {
+		if t.Err != nil {
+			r.Failed += 1
+			continue
+		}
+		completed += 1
+		complexity = append(complexity, float64(t.Complexity))
+		if t.Solved {
+			solvedIn = append(solvedIn, float64(t.SolvedIn))
+		}
+	}
+	if completed == 0 {
+		return
+	}
+	r.SolveRate = float64(len(solvedIn)) / float64(completed)
+	r.MeanComplexity, _ = meanStddev(complexity)
+	if len(solvedIn) > 0 {
+		r.MeanGenerations, _ = meanStddev(solvedIn)
+		sort.Float64s(solvedIn)
+		n := len(solvedIn)
+		r.MedianGenerations = (solvedIn[(n-1)/2] + solvedIn[n/2]) / 2
+	}
+
+	// Average the curves, carrying each trial's last values forward
+	r.Curve = make([]CurvePoint, 0, generations)
+	best, mean := make([]float64, 0, completed), make([]float64, 0, completed)
+	for g := 0; g < generations; g++ {
+		best, mean = best[:0], mean[:0]
+		for _, t := range r.Trials {
+			if t.Err != nil || len(t.Best) == 0 {
+				continue
+			}
+			i := g
+			if i >= len(t.Best) {
+				i = len(t.Best) - 1
+			}
+			best = append(best, t.Best[i])
+			mean = append(mean, t.Mean[i])
+		}
+		if len(best) == 0 {
+			break
+		}
+		p := CurvePoint{Generation: g + 1, Trials: len(best)}
+		p.MeanBest, p.StddevBest = meanStddev(best)
+		p.MeanMean, p.StddevMean = meanStddev(mean)
+		r.Curve = append(r.Curve, p)
+	}
+}
+
+// Returns the mean and the (population) standard deviation of the values
+func meanStddev(xs []float64) (mean, sd float64) {
+	if len(xs) == 0 {
+		return
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean = mean / float64(len(xs))
+	for _, x := range xs {
+		sd += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(xs)))
+}
+
+// Writes the result as indented JSON
+func (r ExperimentResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Writes the averaged fitness curve as CSV, one row per generation
+func (r ExperimentResult) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"generation", "trials", "mean_best", "stddev_best", "mean_mean", "stddev_mean"})
+	for _, p := range r.Curve {
+		out.Write([]string{strconv.Itoa(p.Generation), strconv.Itoa(p.Trials), formatFloat(p.MeanBest),
+			formatFloat(p.StddevBest), formatFloat(p.MeanMean), formatFloat(p.StddevMean)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Writes the outcome of each trial as CSV, one row per trial
+func (r ExperimentResult) WriteTrialsCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"trial", "seed", "solved", "solved_in", "generations", "best_fitness", "complexity",
+		"error"})
+	for _, t := range r.Trials {
+		out.Write([]string{strconv.Itoa(t.Trial), strconv.FormatInt(t.Seed, 10), strconv.FormatBool(t.Solved),
+			strconv.Itoa(t.SolvedIn), strconv.Itoa(t.Generations), formatFloat(t.BestFitness),
+			strconv.Itoa(t.Complexity), t.Error})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
@@ -0,0 +1,147 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"errors"
+	"math"
+)
+
+// Options of FineTune. The zero value gives a short, gentle tuning.
+type FineTuneOptions struct {
+	Lambda      int     // Candidates perturbed from the best each step. 0 = 10
+	Evaluations int     // Budget of candidate evaluations. 0 = 1000
+	Sigma       float64 // Initial standard deviation of the perturbations. 0 = 0.5
+	Decay       float64 // Multiplier of sigma after each step. 0 = 0.99
+	MinSigma    float64 // Floor below which sigma does not decay
+	WeightRange float64 // Weights are clamped to within this of zero. 0 = no limit
+	Seed        int64   // Seed for the perturbations. 0 = draw from the shared generator
+}
+
+// Tunes the weights of the organism's fixed topology with a (1+λ) evolution
+// strategy. Each step perturbs the enabled, unfrozen connection weights of
+// the best organism so far to give λ candidates, evaluates them and keeps the
+// fittest if it improves on the first objective. Sigma decays from step to
+// step until the budget of evaluations is spent. An organism without a
+// fitness is evaluated first, counting against the budget. Evaluators
+// implementing BatchEval are handed each step's candidates as one batch.
+//
+// The organism is not changed; the best copy is returned, keeping its ID.
+func FineTune(org *Organism, dcode Decoder, eval OrgEval, opts FineTuneOptions) (best *Organism, err error) {
+	if opts.Lambda < 0 || opts.Evaluations < 0 || opts.Sigma < 0 || opts.Decay < 0 || opts.MinSigma < 0 ||
+		opts.WeightRange < 0 {
+		err = errors.New("FineTuneOptions cannot be negative")
+		return
+	}
+	lambda, budget, sigma, decay := opts.Lambda, opts.Evaluations, opts.Sigma, opts.Decay
+	if lambda == 0 {
+		lambda = 10
+	}
+	if budget == 0 {
+		budget = 1000
+	}
+	if sigma == 0 {
+		sigma = 0.5
+	}
+	if decay == 0 {
+		decay = 0.99
+	}
+	r := newRng(opts.Seed)
+	if opts.Seed == 0 {
+		r = newRng(random.Int63())
+	}
+
+	// Start from a copy of the organism with a fitness
+	best = org.Copy()
+	used := 0
+	if len(best.Fitness) == 0 {
+		if err = tuneEvaluate(dcode, eval, OrganismSlice{best}); err != nil {
+			return
+		}
+		used += 1
+	}
+
+	// Perturb and keep the improvements
+	for used < budget {
+		k := lambda
+		if budget-used < k {
+			k = budget - used
+		}
+		cands := make(OrganismSlice, k)
+		for i := range cands {
+			c := best.Copy()
+			c.Fitness = nil
+			for _, m := range c.Conns.sortedMarkers() {
+				if cg := c.Conns[m]; cg.Enabled && !cg.Frozen {
+					cg.Weight += sigma * r.Gaussian()
+					if opts.WeightRange > 0 {
+						cg.Weight = math.Max(-opts.WeightRange, math.Min(opts.WeightRange, cg.Weight))
+					}
+				}
+			}
+			cands[i] = c
+		}
+		if err = tuneEvaluate(dcode, eval, cands); err != nil {
+			return
+		}
+		used += k
+		for _, c := range cands {
+			if c.Fitness[0] > best.Fitness[0] {
+				best = c
+			}
+		}
+		sigma = math.Max(sigma*decay, opts.MinSigma)
+	}
+	return
+}
+
+// Decodes and evaluates the candidates, as one batch if the evaluator takes
+// batches, making sure each is given a usable fitness
+func tuneEvaluate(dcode Decoder, eval OrgEval, orgs OrganismSlice) (err error) {
+	if err = decode(dcode, orgs); err != nil {
+		return
+	}
+	if be, ok := eval.(BatchEval); ok {
+		err = EvaluateBatch(be, orgs)
+	} else {
+		for _, o := range orgs {
+			if err = eval.Evaluate(o); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	for _, o := range orgs {
+		if len(o.Fitness) == 0 || math.IsNaN(o.Fitness[0]) {
+			return errors.New("Fine-tuning candidate was not given a usable fitness")
+		}
+		o.Evaluations += 1
+	}
+	return
+}
@@ -30,7 +30,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/boggo/neural"
+	"sort"
 	"strconv"
+	"sync"
 )
 
 var (
@@ -43,6 +45,22 @@ type NodeGene struct {
 	Marker int             // Innovation marker for this gene
 	Type   neural.NodeType // Network node type
 	X, Y   float64         // 2-D Position of this node within the network
+	Name   string          `json:",omitempty"` // Name of an input or output node
+	Frozen bool            `json:",omitempty"` // Protects the node from removal by mutation
+	Module string          `json:",omitempty"` // Module the node belongs to. "" = shared by all
+
+	// Gain applied to the node's summed input before its activation
+	// function, f(Response * sum). Genomes saved without it read as 0,
+	// which counts as 1.
+	Response float64 `json:",omitempty"`
+}
+
+// Returns the response used during activation, 1 for an unset response
+func (ng NodeGene) EffectiveResponse() float64 {
+	if ng.Response == 0 {
+		return 1.0
+	}
+	return ng.Response
 }
 
 func (ng NodeGene) String() string {
@@ -59,7 +77,14 @@ func (ng NodeGene) String() string {
 	default:
 		t = "UNKNOWN"
 	}
-	return fmt.Sprintf("NodeGene [%4d] %7v at %3.2f, %3.2f", ng.Marker, t, ng.X, ng.Y)
+	s := fmt.Sprintf("NodeGene [%4d] %7v at %3.2f, %3.2f", ng.Marker, t, ng.X, ng.Y)
+	if ng.Name != "" {
+		s += " named " + ng.Name
+	}
+	if r := ng.EffectiveResponse(); r != 1.0 {
+		s += fmt.Sprintf(" response %.3f", r)
+	}
+	return s
 }
 
 type NodeGeneMap map[int]*NodeGene
@@ -104,8 +129,25 @@ func (im *NodeGeneMap) UnmarshalJSON(bytes []byte) (err error) {
 
 }
 
+// Returns the markers of the node genes in ascending order. Iterating genes in
+// this order, rather than the map's, keeps seeded runs repeatable.
+func (im NodeGeneMap) sortedMarkers() []int {
+	return im.appendSortedMarkers(make([]int, 0, len(im)))
+}
+
+// Appends the markers in ascending order to dst, which may be reused
+// scratch space from the marker pool
+func (im NodeGeneMap) appendSortedMarkers(dst []int) []int {
+	for k := range im {
+		dst = append(dst, k)
+	}
+	sort.Ints(dst)
+	return dst
+}
+
 func cloneNode(source *NodeGene) (clone *NodeGene) {
-	clone = &NodeGene{Marker: source.Marker, Type: source.Type, X: source.X, Y: source.Y}
+	clone = &NodeGene{Marker: source.Marker, Type: source.Type, X: source.X, Y: source.Y, Name: source.Name,
+		Frozen: source.Frozen, Module: source.Module, Response: source.Response}
 	return
 }
 
@@ -115,6 +157,8 @@ type ConnGene struct {
 	Source, Target int     // Innovation markers for the source and target node genes
 	Weight         float64 // Weight applied during activation
 	Enabled        bool    // Is this connection gene enabled?
+	Frozen         bool    `json:",omitempty"` // Protects the weight and connection from mutation
+	Split          bool    `json:",omitempty"` // Was disabled by the add node mutation splitting it
 }
 
 type ConnGeneMap map[int]*ConnGene
@@ -159,6 +203,34 @@ func (im *ConnGeneMap) UnmarshalJSON(bytes []byte) (err error) {
 
 }
 
+// Returns the markers of the connection genes in ascending order
+func (im ConnGeneMap) sortedMarkers() []int {
+	return im.appendSortedMarkers(make([]int, 0, len(im)))
+}
+
+// Appends the markers in ascending order to dst
+func (im ConnGeneMap) appendSortedMarkers(dst []int) []int {
+	for k := range im {
+		dst = append(dst, k)
+	}
+	sort.Ints(dst)
+	return dst
+}
+
+// Scratch slices for markers which are needed only for the length of a call,
+// saving an allocation on every comparison during speciation
+var markerPool = sync.Pool{New: func() interface{} { return new([]int) }}
+
+func getMarkers() *[]int {
+	ms := markerPool.Get().(*[]int)
+	*ms = (*ms)[:0]
+	return ms
+}
+
+func putMarkers(ms *[]int) {
+	markerPool.Put(ms)
+}
+
 func (cg ConnGene) String() string {
 	var e string
 	if cg.Enabled {
@@ -172,7 +244,7 @@ func (cg ConnGene) String() string {
 
 func cloneConn(source *ConnGene) (clone *ConnGene) {
 	clone = &ConnGene{Marker: source.Marker, Source: source.Source, Target: source.Target,
-		Weight: source.Weight, Enabled: source.Enabled}
+		Weight: source.Weight, Enabled: source.Enabled, Frozen: source.Frozen, Split: source.Split}
 	return
 }
 
@@ -182,6 +254,9 @@ type Genome struct {
 	Nodes   NodeGeneMap // Collection of node genes identified by their markers
 	Conns   ConnGeneMap // Collection of conn genes identified by their markers
 	Fitness []float64   // Fitness of this Genome
+
+	// Mutation multipliers carried by the genome when self-adapting
+	Profile *MutationProfile `json:",omitempty"`
 }
 
 // Describes the genome
@@ -190,20 +265,195 @@ func (g Genome) String() string {
 		len(g.Nodes), len(g.Conns), g.Fitness)
 }
 
-// Creates a deep copy of the genome
+// Returns true if the genome has a connection gene, enabled or not, from the
+// source node to the target
+func (g *Genome) connected(source, target int) bool {
+	for _, c := range g.Conns {
+		if c.Source == source && c.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if a path of enabled connections leads from one node to the
+// other, as it does from a node to itself
+func (g *Genome) reaches(from, to int) bool {
+	seen := map[int]bool{from: true}
+	stack := []int{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == to {
+			return true
+		}
+		for _, c := range g.Conns {
+			if c.Enabled && c.Source == n && !seen[c.Target] {
+				seen[c.Target] = true
+				stack = append(stack, c.Target)
+			}
+		}
+	}
+	return false
+}
+
+// Returns the markers of the enabled connections of a cycle in the network,
+// in the order the signal runs, or nil if the network is feedforward. A self
+// connection is a cycle of one.
+func (g *Genome) Cycle() []int {
+	out := make(map[int][]*ConnGene, len(g.Nodes))
+	for _, k := range g.Conns.sortedMarkers() {
+		if c := g.Conns[k]; c.Enabled {
+			out[c.Source] = append(out[c.Source], c)
+		}
+	}
+
+	// Search depth first from each node, noting the connections on the path
+	const (
+		unseen = iota
+		onPath
+		done
+	)
+	state := make(map[int]int, len(g.Nodes))
+	var path []*ConnGene
+	var visit func(n int) []int
+	visit = func(n int) []int {
+		state[n] = onPath
+		for _, c := range out[n] {
+			switch state[c.Target] {
+			case onPath:
+				// Unwind the path back to where the cycle starts
+				i := len(path)
+				if c.Target != n {
+					for path[i-1].Source != c.Target {
+						i--
+					}
+					i--
+				}
+				cycle := make([]int, 0, len(path)-i+1)
+				for _, p := range path[i:] {
+					cycle = append(cycle, p.Marker)
+				}
+				return append(cycle, c.Marker)
+			case unseen:
+				path = append(path, c)
+				if cycle := visit(c.Target); cycle != nil {
+					return cycle
+				}
+				path = path[:len(path)-1]
+			}
+		}
+		state[n] = done
+		return nil
+	}
+	for _, k := range g.Nodes.sortedMarkers() {
+		if state[k] == unseen {
+			if cycle := visit(k); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Removes connection genes which duplicate the node pair of an older gene,
+// such as a crossover may bring together when the same connection arose
+// in both parents under different markers
+func (g *Genome) dedupeConns() {
+	seen := make(map[connKey]bool, len(g.Conns))
+	for _, k := range g.Conns.sortedMarkers() {
+		c := g.Conns[k]
+		key := connKey{c.Source, c.Target}
+		if seen[key] {
+			delete(g.Conns, k)
+		}
+		seen[key] = true
+	}
+}
+
+// Checks the genome's structure, returning an error describing the first
+// problem found: genes filed under the wrong marker, connections to missing
+// nodes or into a bias or input node, or more than one connection between
+// the same pair of nodes.
+func (g *Genome) Validate() error {
+	for _, k := range g.Nodes.sortedMarkers() {
+		if ng := g.Nodes[k]; ng.Marker != k {
+			return fmt.Errorf("Genome %d has node %d filed under marker %d", g.ID, ng.Marker, k)
+		}
+	}
+	seen := make(map[connKey]int, len(g.Conns))
+	for _, k := range g.Conns.sortedMarkers() {
+		c := g.Conns[k]
+		switch {
+		case c.Marker != k:
+			return fmt.Errorf("Genome %d has connection %d filed under marker %d", g.ID, c.Marker, k)
+		case g.Nodes[c.Source] == nil:
+			return fmt.Errorf("Genome %d connection %d has a missing source node %d", g.ID, k, c.Source)
+		case g.Nodes[c.Target] == nil:
+			return fmt.Errorf("Genome %d connection %d has a missing target node %d", g.ID, k, c.Target)
+		case g.Nodes[c.Target].Type == neural.BIAS || g.Nodes[c.Target].Type == neural.INPUT:
+			return fmt.Errorf("Genome %d connection %d leads into sensor node %d", g.ID, k, c.Target)
+		}
+		key := connKey{c.Source, c.Target}
+		if m, ok := seen[key]; ok {
+			return fmt.Errorf("Genome %d connections %d and %d both join nodes %d and %d", g.ID, m, k,
+				c.Source, c.Target)
+		}
+		seen[key] = k
+	}
+	return nil
+}
+
+// Returns the nodes of the given type in the order the phenome sees them,
+// which is by position and then by marker
+func (g *Genome) nodesOfType(t neural.NodeType) (nodes []*NodeGene) {
+	for _, k := range g.Nodes.sortedMarkers() {
+		if ng := g.Nodes[k]; ng.Type == t {
+			nodes = append(nodes, ng)
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Y == nodes[j].Y {
+			return nodes[i].X < nodes[j].X
+		}
+		return nodes[i].Y < nodes[j].Y
+	})
+	return
+}
+
+// Returns a deep copy of the genome with the same ID
+func (g *Genome) Copy() *Genome {
+	return cloneGenome(g, g.ID)
+}
+
+// Returns a deep copy of the genome with the given ID
+func (g *Genome) CopyWithID(id int) *Genome {
+	return cloneGenome(g, id)
+}
+
+// Creates a deep copy of the genome. The genes are copied into one block for
+// the nodes and one for the connections rather than allocated one by one.
 func cloneGenome(source *Genome, id int) (clone *Genome) {
-	clone = &Genome{ID: id, Fitness: source.Fitness,
-		Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
+	clone = &Genome{ID: id, Profile: cloneProfile(source.Profile),
+		Nodes: make(map[int]*NodeGene, len(source.Nodes)), Conns: make(map[int]*ConnGene, len(source.Conns))}
+	if source.Fitness != nil {
+		clone.Fitness = append([]float64(nil), source.Fitness...)
+	}
+	nodes := make([]NodeGene, 0, len(source.Nodes))
 	for k, v := range source.Nodes {
-		clone.Nodes[k] = cloneNode(v)
+		nodes = append(nodes, *v)
+		clone.Nodes[k] = &nodes[len(nodes)-1]
 	}
+	conns := make([]ConnGene, 0, len(source.Conns))
 	for k, v := range source.Conns {
-		clone.Conns[k] = cloneConn(v)
+		conns = append(conns, *v)
+		clone.Conns[k] = &conns[len(conns)-1]
 	}
 	return clone
 }
 
-// Creates the initial genome to seed the population
+// Creates the initial genome to seed the population. Its genes are blessed
+// like any innovation so that runs sharing a history number them alike.
 func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err error) {
 
 	// Shortcuts to settings values
@@ -213,6 +463,9 @@ func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err er
 
 	// Create the new genome with a negative (i.e., invalid) ID
 	genome = &Genome{ID: -1, Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
+	if settings.SelfAdaptive {
+		genome.Profile = unitProfile()
+	}
 
 	// Construct the nodes
 	// Create the bias and input nodes
@@ -223,13 +476,21 @@ func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err er
 		step = 1.0 / float64(biasCount+inputCount-1)
 	}
 	for i := 0; i < biasCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.BIAS, X: step * float64(i), Y: 0}
+		ng = &NodeGene{Type: neural.BIAS, X: step * float64(i), Y: 0}
+		ng.Marker = inno.blessNodeGene(nodeKey{ng.X, ng.Y})
 		genome.Nodes[ng.Marker] = ng
 	}
 
 	// Create the input nodes
 	for i := 0; i < inputCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.INPUT, X: step * float64(i+biasCount), Y: 0}
+		ng = &NodeGene{Type: neural.INPUT, X: step * float64(i+biasCount), Y: 0}
+		ng.Marker = inno.blessNodeGene(nodeKey{ng.X, ng.Y})
+		if i < len(settings.InputNames) {
+			ng.Name = settings.InputNames[i]
+		}
+		if i < len(settings.InputModules) {
+			ng.Module = settings.InputModules[i]
+		}
 		genome.Nodes[ng.Marker] = ng
 	}
 
@@ -239,15 +500,25 @@ func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err er
 		step = 1.0 / float64(outputCount-1)
 	}
 	for i := 0; i < outputCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.OUTPUT, X: step * float64(i), Y: 1.0}
+		ng = &NodeGene{Type: neural.OUTPUT, X: step * float64(i), Y: 1.0, Response: 1.0}
+		ng.Marker = inno.blessNodeGene(nodeKey{ng.X, ng.Y})
+		if i < len(settings.OutputNames) {
+			ng.Name = settings.OutputNames[i]
+		}
+		if i < len(settings.OutputModules) {
+			ng.Module = settings.OutputModules[i]
+		}
 		genome.Nodes[ng.Marker] = ng
 	}
 
 	// Create the connections
-	for _, in := range genome.Nodes {
-		for _, out := range genome.Nodes {
+	markers := genome.Nodes.sortedMarkers()
+	for _, i := range markers {
+		in := genome.Nodes[i]
+		for _, j := range markers {
+			out := genome.Nodes[j]
 			if out.Type == neural.OUTPUT && (in.Type == neural.BIAS || in.Type == neural.INPUT) {
-				cg := &ConnGene{Marker: inno.nextMarker(),
+				cg := &ConnGene{Marker: inno.blessConnGene(connKey{in.Marker, out.Marker}),
 					Enabled: true, Weight: 0, Source: in.Marker,
 					Target: out.Marker}
 				genome.Conns[cg.Marker] = cg
@@ -0,0 +1,178 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGenomeValidate(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		spoil func(g *Genome)
+		want  string
+	}{
+		{"valid", func(g *Genome) {}, ""},
+		{"node marker", func(g *Genome) { g.Nodes[9] = g.Nodes[5] }, "node 5 filed under marker 9"},
+		{"conn marker", func(g *Genome) { g.Conns[9] = g.Conns[2] }, "connection 2 filed under marker 9"},
+		{"source", func(g *Genome) { delete(g.Nodes, 2) }, "missing source node 2"},
+		{"target", func(g *Genome) { delete(g.Nodes, 5) }, "missing target node 5"},
+		{"sensor", func(g *Genome) { g.Conns[2].Target = 3 }, "leads into sensor node 3"},
+		{"duplicate", func(g *Genome) {
+			g.Conns[4] = &ConnGene{Marker: 4, Source: 2, Target: 5, Weight: 1}
+		}, "connections 2 and 4 both join nodes 2 and 5"},
+	} {
+		g := testGenome(1, 0, testConn{1, 2, 4, 1}, testConn{2, 2, 5, 1}, testConn{3, 5, 4, 1})
+		c.spoil(g)
+		err := g.Validate()
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%s: error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+// Rolls populations on for many generations, mostly adding connections or,
+// with del, also deleting nodes, and checks that no genome gains a duplicate
+// connection or a self connection the settings forbid
+func TestConnectionInvariants(t *testing.T) {
+	for _, c := range []struct{ recurrent, self, del bool }{{false, false, false}, {true, false, false},
+		{true, true, false}, {false, false, true}, {true, false, true}, {true, true, true}} {
+		s := testSettings()
+		s.MutateAddConnection, s.MutateAddNode = 0.9, 0.1
+		if c.del {
+			s.MutateAddConnection, s.MutateAddNode, s.MutateDelNode = 0.5, 0.3, 0.6
+		}
+		s.AllowRecurrent, s.AllowSelfConnections = c.recurrent, c.self
+		pop, inno := evaluatedPopulation(t, s)
+		selfs := 0
+		for gen := 0; gen < 40; gen++ {
+			pop = rollTest(t, s, inno, pop, 1)
+			for _, o := range pop.Organisms() {
+				if err := o.Validate(); err != nil {
+					t.Fatalf("%+v generation %d: %v", c, gen, err)
+				}
+				for _, cg := range o.Conns {
+					if cg.Source == cg.Target {
+						selfs += 1
+						if !c.self {
+							t.Fatalf("%+v generation %d: organism %d has self connection %d", c, gen, o.ID,
+								cg.Marker)
+						}
+					}
+				}
+			}
+		}
+		inno.close()
+		if c.self && selfs == 0 {
+			t.Errorf("%+v: no self connections were added", c)
+		}
+	}
+}
+
+// Mutates lineages thousands of times in feedforward mode, and then evolves
+// a population, crossover included, checking no genome gains a cycle
+func TestFeedforwardMutations(t *testing.T) {
+	s := testSettings()
+	s.MutateAddConnection, s.MutateAddNode, s.MutateEnabled = 0.9, 0.3, 0.5
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.rand().seed(1)
+	inno := newInnovation(nil)
+	defer inno.close()
+	ig, err := initialGenome(s, inno)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for lineage := 0; lineage < 20; lineage++ {
+		org := &Organism{Genome: cloneGenome(ig, inno.nextID())}
+		for i := 0; i < 200; i++ {
+			mutate(s, inno, org, &rollCounters{})
+			if c := org.Cycle(); c != nil {
+				t.Fatalf("Lineage %d mutation %d made cycle %v in %v", lineage, i, c, org.Genome)
+			}
+		}
+	}
+
+	pop, inno2 := evaluatedPopulation(t, s)
+	defer inno2.close()
+	for gen := 0; gen < 30; gen++ {
+		pop = rollTest(t, s, inno2, pop, 1)
+		for _, o := range pop.Organisms() {
+			if c := o.Cycle(); c != nil {
+				t.Fatalf("Generation %d organism %d has cycle %v", pop.Generation, o.ID, c)
+			}
+		}
+	}
+}
+
+// Deleting a node whose replacing connection already exists keeps a single
+// connection between the pair
+func TestDelNodeRewiring(t *testing.T) {
+	s := testSettings()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.rand().seed(1)
+	inno := newInnovationAt(100, 100)
+	defer inno.close()
+	for _, c := range []struct {
+		name  string
+		conns []testConn
+		want  []connKey
+	}{
+		{"one incoming", []testConn{{10, 2, 5, 1}, {11, 5, 4, 1}, {12, 2, 4, 1}}, []connKey{{2, 4}}},
+		{"one outgoing", []testConn{{10, 2, 5, 1}, {11, 3, 5, 1}, {12, 5, 4, 1}, {13, 3, 4, 1}},
+			[]connKey{{2, 4}, {3, 4}}},
+		{"self connection", []testConn{{10, 5, 5, 1}, {11, 5, 4, 1}}, nil},
+	} {
+		org := &Organism{Genome: testGenome(1, 0, c.conns...)}
+		for i := 0; i < 1000 && org.Nodes[5] != nil; i++ {
+			mutateDelNode(s, inno, org)
+		}
+		if org.Nodes[5] != nil {
+			t.Fatalf("%s: node 5 was never deleted", c.name)
+		}
+		if err := org.Validate(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		var got []connKey
+		for _, k := range org.Conns.sortedMarkers() {
+			got = append(got, connKey{org.Conns[k].Source, org.Conns[k].Target})
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].Source < got[j].Source })
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: connections %v, want %v", c.name, got, c.want)
+		}
+	}
+}
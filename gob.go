@@ -0,0 +1,137 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Encoded form of a species. The example is stored as the index of the
+// organism it is, keeping the aliasing, or in full if it is not a member.
+type gobSpecies struct {
+	ID           int
+	Orgs         OrganismSlice
+	Age          int
+	CreatedAt    int
+	BestFitness  float64
+	BestFitAge   int
+	ExampleIndex int // Index of the example in Orgs. -1 = none or not a member
+	Example      *Organism
+	Offspring    int
+	Layer        int
+	Meta         Meta
+	Trail        []FitnessPoint
+	TrailHead    int
+	Threshold    float64
+}
+
+// Encodes the species for encoding/gob
+func (s *Species) GobEncode() ([]byte, error) {
+	gs := gobSpecies{ID: s.ID, Orgs: s.Orgs, Age: s.Age, CreatedAt: s.CreatedAt, BestFitness: s.BestFitness,
+		BestFitAge: s.BestFitAge, ExampleIndex: -1, Offspring: s.Offspring, Layer: s.Layer, Meta: s.Meta,
+		Trail: s.Trail, TrailHead: s.TrailHead, Threshold: s.Threshold}
+	for i, o := range s.Orgs {
+		if o == s.Example {
+			gs.ExampleIndex = i
+			break
+		}
+	}
+	if gs.ExampleIndex < 0 {
+		gs.Example = s.Example
+	}
+	return gobBytes(gs)
+}
+
+// Decodes the species from encoding/gob, working out again the current
+// fitness which is not stored
+func (s *Species) GobDecode(b []byte) (err error) {
+	var gs gobSpecies
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&gs); err != nil {
+		return
+	}
+	*s = Species{ID: gs.ID, Orgs: gs.Orgs, Age: gs.Age, CreatedAt: gs.CreatedAt, BestFitness: gs.BestFitness,
+		BestFitAge: gs.BestFitAge, Example: gs.Example, Offspring: gs.Offspring, Layer: gs.Layer, Meta: gs.Meta,
+		Trail: gs.Trail, TrailHead: gs.TrailHead, Threshold: gs.Threshold}
+	if gs.ExampleIndex >= 0 && gs.ExampleIndex < len(s.Orgs) {
+		s.Example = s.Orgs[gs.ExampleIndex]
+	}
+	s.currFitness, _ = s.Orgs.MeanFitness()
+	return
+}
+
+// Encoded form of an organism, leaving out the phenome which is decoded
+// afresh
+type gobOrganism struct {
+	Genome      *Genome
+	Meta        Meta
+	Behavior    []float64
+	Cases       []float64
+	Age         int
+	Evaluations int
+	Birth       int
+	Estimated   bool
+	Parents     []int
+	Ancestry    [][]int
+	SelFit      float64
+}
+
+// Encodes the organism for encoding/gob
+func (org *Organism) GobEncode() ([]byte, error) {
+	return gobBytes(gobOrganism{Genome: org.Genome, Meta: org.Meta, Behavior: org.Behavior, Cases: org.Cases,
+		Age: org.Age, Evaluations: org.Evaluations, Birth: org.Birth, Estimated: org.Estimated, Parents: org.Parents,
+		Ancestry: org.Ancestry, SelFit: org.selFit})
+}
+
+// Decodes the organism from encoding/gob
+func (org *Organism) GobDecode(b []byte) (err error) {
+	var g gobOrganism
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&g); err != nil {
+		return
+	}
+	*org = Organism{Genome: g.Genome, Meta: g.Meta, Behavior: g.Behavior, Cases: g.Cases, Age: g.Age,
+		Evaluations: g.Evaluations, Birth: g.Birth, Estimated: g.Estimated, Parents: g.Parents, Ancestry: g.Ancestry, selFit: g.SelFit}
+	return
+}
+
+// Encodes the settings for encoding/gob as JSON, which leaves out the runtime
+// extensions gob cannot encode
+func (s *Settings) GobEncode() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Decodes the settings from encoding/gob
+func (s *Settings) GobDecode(b []byte) error {
+	return json.Unmarshal(b, s)
+}
+
+func gobBytes(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
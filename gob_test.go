@@ -0,0 +1,82 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+)
+
+// Rolls the population on a few generations from a fixed seed, returning
+// it as JSON
+func rerollJSON(t *testing.T, s *Settings, pop *Population) []byte {
+	t.Helper()
+	s.rand().seed(99)
+	inno := newInnovation(pop)
+	defer inno.close()
+	pop = rollTest(t, s, inno, pop, 5)
+	b, err := json.Marshal(pop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	s := testSettings()
+	s.CompatThreshold = 1
+	pop, inno := evaluatedPopulation(t, s)
+	pop = rollTest(t, s, inno, pop, 10)
+	inno.close()
+	if len(pop.Species) < 2 {
+		t.Fatalf("Only %d species", len(pop.Species))
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(pop); err != nil {
+		t.Fatal(err)
+	}
+	restored := new(Population)
+	if err := gob.NewDecoder(&buf).Decode(restored); err != nil {
+		t.Fatal(err)
+	}
+	if d := pop.Difference(restored, 0); d != "" {
+		t.Fatalf("Restored population differs at %s", d)
+	}
+	for i, sp := range restored.Species {
+		if pop.Species[i].Orgs.contains(pop.Species[i].Example) && !sp.Orgs.contains(sp.Example) {
+			t.Errorf("Species %d's example is no longer one of its organisms", sp.ID)
+		}
+	}
+
+	// Rolling on takes the same course either way
+	if !bytes.Equal(rerollJSON(t, s, restored), rerollJSON(t, s, pop)) {
+		t.Error("Rolling the restored population took a different course")
+	}
+}
@@ -0,0 +1,105 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// Golden runs guard the evolution pipeline against unintended changes to its
+// dynamics. Each profile trains a population from a fixed seed with the
+// weight evaluator and compares a digest of the result with its golden file
+// in testdata. A change which alters evolution on purpose is expected to
+// change the digests; regenerate them with
+//
+//	go test -run TestGolden -update
+//
+// and commit them along with the change.
+var goldenProfiles = []struct {
+	name        string
+	generations int
+	settings    func() *Settings
+}{
+	{"small", 15, func() *Settings {
+		s := SettingsForXOR()
+		s.PopulationSize = 30
+		s.Seed = 1
+		return s
+	}},
+	{"large", 10, func() *Settings {
+		s := SettingsForXOR()
+		s.PopulationSize = 300
+		s.Seed = 2
+		return s
+	}},
+	{"recurrent", 15, func() *Settings {
+		s := SettingsForXOR()
+		s.PopulationSize = 100
+		s.AllowRecurrent = true
+		s.MutateAddConnection = 0.3
+		s.MutateAddNode = 0.05
+		s.Seed = 3
+		return s
+	}},
+}
+
+// Digest of the population a golden run finishes with
+type goldenDigest struct {
+	Generation   int
+	BestFitness  float64
+	SpeciesSizes []int    // Sizes of the species in population order
+	Hashes       []string // Hashes of the genomes in ascending order
+}
+
+func TestGolden(t *testing.T) {
+	for _, p := range goldenProfiles {
+		t.Run(p.name, func(t *testing.T) {
+			s := p.settings()
+			best, pop := trainTest(t, s, p.generations)
+			d := goldenDigest{Generation: pop.Generation, BestFitness: best.Fitness[0]}
+			for _, sp := range pop.Species {
+				d.SpeciesSizes = append(d.SpeciesSizes, len(sp.Orgs))
+			}
+			var hashes []uint64
+			for _, o := range pop.Organisms() {
+				hashes = append(hashes, o.Hash())
+			}
+			sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+			for _, h := range hashes {
+				d.Hashes = append(d.Hashes, fmt.Sprintf("%016x", h))
+			}
+			b, err := json.MarshalIndent(d, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "golden-"+p.name+".json", append(b, '\n'))
+		})
+	}
+}
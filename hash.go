@@ -0,0 +1,140 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Returns a hash of the parts of the genome which shape its network: the
+// markers, types and responses of the nodes and the markers, ends, weights and states of
+// the connections. The ID, positions, names, flags and mutation profile are
+// left out, so genomes which would decode to the same network hash alike.
+func (g *Genome) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	put := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	for _, k := range g.Nodes.sortedMarkers() {
+		ng := g.Nodes[k]
+		put(uint64(ng.Marker))
+		put(uint64(ng.Type))
+		put(math.Float64bits(ng.EffectiveResponse()))
+	}
+	put(math.MaxUint64) // Separates the nodes from the connections
+	for _, k := range g.Conns.sortedMarkers() {
+		cg := g.Conns[k]
+		put(uint64(cg.Marker))
+		put(uint64(cg.Source))
+		put(uint64(cg.Target))
+		put(math.Float64bits(cg.Weight))
+		if cg.Enabled {
+			put(1)
+		} else {
+			put(0)
+		}
+	}
+	return h.Sum64()
+}
+
+// Returns the number of organisms in the population whose genome hashes the
+// same as that of an organism before it
+func (pop *Population) Duplicates() (n int) {
+	seen := make(map[uint64]bool)
+	for _, o := range pop.Organisms() {
+		h := o.Hash()
+		if seen[h] {
+			n += 1
+		}
+		seen[h] = true
+	}
+	return
+}
+
+// Returns the mean genome distance between pairs of organisms in the
+// population. The mean is exact if there are no more than k pairs and is
+// otherwise estimated from k pairs drawn at random. The draws do not disturb
+// the run's random numbers.
+func (pop *Population) Diversity(settings *Settings, k int) float64 {
+	orgs := pop.Organisms()
+	n := len(orgs)
+	if n < 2 {
+		return 0
+	}
+	sum := float64(0)
+	if pairs := n * (n - 1) / 2; k <= 0 || pairs <= k {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				sum += distance(settings, orgs[i], orgs[j])
+			}
+		}
+		return sum / float64(pairs)
+	}
+	r := newRng(int64(pop.Generation))
+	for p := 0; p < k; p++ {
+		i := r.Int(n)
+		j := r.Int(n - 1)
+		if j >= i {
+			j += 1
+		}
+		sum += distance(settings, orgs[i], orgs[j])
+	}
+	return sum / float64(k)
+}
+
+// Retries of the mutation before a duplicate child is accepted
+const duplicateRetries = 3
+
+// Returns the hashes of the population's organisms
+func (pop *Population) hashes() map[uint64]bool {
+	orgs := pop.Organisms()
+	seen := make(map[uint64]bool, len(orgs))
+	for _, o := range orgs {
+		seen[o.Hash()] = true
+	}
+	return seen
+}
+
+// With RejectDuplicates, mutates the child again while it duplicates a
+// member of the previous generation or an earlier child, up to a few times,
+// and then notes it among the children
+func rejectDuplicate(settings *Settings, inno *innovation, child *Organism, parents, children map[uint64]bool,
+	counters *rollCounters) {
+	if !settings.RejectDuplicates {
+		return
+	}
+	h := child.Hash()
+	for i := 0; i < duplicateRetries && (parents[h] || children[h]); i++ {
+		mutate(settings, inno, child, counters)
+		h = child.Hash()
+	}
+	children[h] = true
+}
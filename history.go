@@ -0,0 +1,77 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"sort"
+)
+
+// Statistics collector retaining the size of every species in every
+// generation, for charting how the species rise and die out. Species are
+// keyed by ID, which is never reissued within a run, and a species which has
+// died keeps a size of 0 in the generations which follow. The zero value is
+// ready to use.
+type SpeciesHistory struct {
+	Generations []int         // Generation of each row
+	Sizes       map[int][]int // Species sizes by ID, one entry for each generation
+}
+
+func (h *SpeciesHistory) Collect(stats *Stats) error {
+	if h.Sizes == nil {
+		h.Sizes = make(map[int][]int)
+	}
+	row := len(h.Generations)
+	h.Generations = append(h.Generations, stats.Generation)
+
+	// Add the sizes, starting newcomers with zeros for the earlier
+	// generations
+	for _, ss := range stats.Species {
+		sizes, ok := h.Sizes[ss.ID]
+		if !ok {
+			sizes = make([]int, row, row+1)
+		}
+		h.Sizes[ss.ID] = append(sizes, ss.Size)
+	}
+
+	// Pad out the species which have died
+	for id, sizes := range h.Sizes {
+		if len(sizes) == row {
+			h.Sizes[id] = append(sizes, 0)
+		}
+	}
+	return nil
+}
+
+// Returns the IDs of every species seen, in ascending order
+func (h *SpeciesHistory) IDs() []int {
+	ids := make([]int, 0, len(h.Sizes))
+	for id := range h.Sizes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
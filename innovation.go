@@ -26,6 +26,10 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 package neat
 
+import (
+	"sync/atomic"
+)
+
 type nodeKey struct {
 	X, Y float64 // Position of the node in the network
 }
@@ -64,6 +68,18 @@ type innovation struct {
 
 	reqN chan nodeRequest
 	reqC chan connRequest
+
+	lastID, lastMarker int64 // Latest of each handed out, for recording the sequences
+	persistent         bool  // Keep the history across generations
+
+	journal *journal // Provisional innovations of a species bred in parallel
+}
+
+// Creates the innovation history for driving the generations directly, as
+// with Reproduce and SpeciateInto, continuing the IDs and markers used in the
+// population if one is given. Close it once the run is done.
+func NewInnovation(pop *Population) *innovation {
+	return newInnovation(pop)
 }
 
 func newInnovation(pop *Population) *innovation {
@@ -104,18 +120,50 @@ func newInnovation(pop *Population) *innovation {
 			}
 		}
 	}
-	go inno.startIDs(id + 1)
-	go inno.startMarkers(marker + 1)
+	inno.start(id+1, marker+1)
+
+	// Return the innovation
+	return inno
 
-	// Create and start the marker requests
+}
+
+// Creates an innovation history whose sequences continue from the given ID
+// and marker
+func newInnovationAt(id, marker int) *innovation {
+	inno := &innovation{
+		running: true,
+		ids:     make(chan int, 8),
+		markers: make(chan int, 8),
+		nodes:   make(map[nodeKey]int),
+		conns:   make(map[connKey]int)}
+	inno.start(id, marker)
+	return inno
+}
+
+// Starts the sequences and the marker requests
+func (inno *innovation) start(id, marker int) {
+	inno.lastID, inno.lastMarker = int64(id-1), int64(marker-1)
+	go inno.startIDs(id)
+	go inno.startMarkers(marker)
 	inno.reqN = make(chan nodeRequest)
 	inno.reqC = make(chan connRequest)
 	go inno.runNodes()
 	go inno.runConns()
+}
 
-	// Return the innovation
-	return inno
+// Returns the next ID and marker the sequences will hand out
+func (inno *innovation) next() (id, marker int) {
+	return int(atomic.LoadInt64(&inno.lastID)) + 1, int(atomic.LoadInt64(&inno.lastMarker)) + 1
+}
 
+// Notes the value as handed out by a sequence
+func noteLatest(latest *int64, v int) {
+	for {
+		old := atomic.LoadInt64(latest)
+		if int64(v) <= old || atomic.CompareAndSwapInt64(latest, old, int64(v)) {
+			return
+		}
+	}
 }
 
 func (inno *innovation) close() {
@@ -126,6 +174,11 @@ func (inno *innovation) close() {
 	close(inno.reqN)
 }
 
+// Stops the sequences of the innovation history
+func (inno *innovation) Close() {
+	inno.close()
+}
+
 func (inno *innovation) startIDs(start int) {
 	defer func() { recover() }()
 	for i := start; inno.running; i++ {
@@ -141,14 +194,25 @@ func (inno *innovation) startMarkers(start int) {
 }
 
 func (inno *innovation) nextID() int {
-	return <-inno.ids
+	if inno.journal != nil {
+		return inno.journal.nextID()
+	}
+	id := <-inno.ids
+	noteLatest(&inno.lastID, id)
+	return id
 }
 
 func (inno *innovation) nextMarker() int {
-	return <-inno.markers
+	m := <-inno.markers
+	noteLatest(&inno.lastMarker, m)
+	return m
 }
 
+// Clears the history of innovations, unless it is kept for the whole run
 func (inno *innovation) reset() {
+	if inno.persistent {
+		return
+	}
 	inno.nodes = make(map[nodeKey]int)
 	inno.conns = make(map[connKey]int)
 }
@@ -158,7 +222,7 @@ func (inno *innovation) runNodes() {
 		req := <-inno.reqN
 		m, ok := inno.nodes[req.key]
 		if !ok {
-			m = <-inno.markers
+			m = inno.nextMarker()
 			inno.nodes[req.key] = m
 		}
 		req.ret <- m
@@ -170,7 +234,7 @@ func (inno *innovation) runConns() {
 		req := <-inno.reqC
 		m, ok := inno.conns[req.key]
 		if !ok {
-			m = <-inno.markers
+			m = inno.nextMarker()
 			inno.conns[req.key] = m
 		}
 		req.ret <- m
@@ -178,12 +242,18 @@ func (inno *innovation) runConns() {
 }
 
 func (inno *innovation) blessNodeGene(key nodeKey) int {
+	if inno.journal != nil {
+		return inno.journal.blessNodeGene(key)
+	}
 	result := make(chan int)
 	inno.reqN <- nodeRequest{key, result}
 	return <-result
 }
 
 func (inno *innovation) blessConnGene(key connKey) int {
+	if inno.journal != nil {
+		return inno.journal.blessConnGene(key)
+	}
 	result := make(chan int)
 	inno.reqC <- connRequest{key, result}
 	return <-result
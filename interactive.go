@@ -0,0 +1,109 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"errors"
+)
+
+// StepGeneration advances an interactive run, such as one in which a person
+// rates the organisms, by a single generation. Given a nil population it
+// creates the initial one, seeding the random numbers from the settings;
+// given an evaluated population it rolls it to the next generation. Either
+// way assign is then called with the new organisms and must not return until
+// each has been given a fitness. The organisms have not been decoded, so the
+// caller decodes those it needs to show.
+//
+// A population whose organisms have not all been given a fitness, such as one
+// archived part way through a session, is not rolled; assign is given its
+// organisms again. An interactive run may therefore be checkpointed with any
+// Archiver between steps and resumed later with the restored population and
+// an innovation tracker from NewInnovation. The exception is deferred
+// speciation, under which the children are assigned before they belong to a
+// population. PreEval is not supported.
+func StepGeneration(pop *Population, settings *Settings, inno *innovation, assign func(orgs OrganismSlice)) (next *Population, err error) {
+	if err = settings.Validate(); err != nil {
+		return
+	}
+	if settings.PreEval != nil {
+		err = errors.New("StepGeneration does not support PreEval")
+		return
+	}
+
+	// Create, resume or roll the population
+	switch {
+	case pop == nil:
+		if settings.Seed != 0 {
+			settings.rand().seed(settings.Seed)
+		}
+		if next, err = initialPopulation(settings, inno); err != nil {
+			return
+		}
+	case !pop.evaluated():
+		next = pop
+	case settings.deferSpeciation():
+		var children OrganismSlice
+		children, next, err = Reproduce(settings, inno, pop)
+		if err != nil {
+			return
+		}
+		assign(children)
+		for _, o := range children {
+			if err = checkFitness(settings, o); err != nil {
+				return
+			}
+		}
+		SpeciateInto(settings, inno, next, children)
+		return
+	default:
+		if next, err = rollPop(settings, inno, pop); err != nil {
+			return
+		}
+	}
+
+	// Have the organisms rated
+	orgs := next.Organisms()
+	assign(orgs)
+	for _, o := range orgs {
+		if err = checkFitness(settings, o); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Has every organism in the population been given a fitness?
+func (pop *Population) evaluated() bool {
+	for _, s := range pop.Species {
+		for _, o := range s.Orgs {
+			if len(o.Fitness) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+// Records the child's parents and, to the depth the incest check looks,
+// its earlier ancestors. The second parent is nil for mutation-only
+// offspring. The child is as old as its older parent.
+func setLineage(settings *Settings, child, p1, p2 *Organism) {
+	parents := []*Organism{p1}
+	if p2 != nil && p2 != p1 {
+		parents = append(parents, p2)
+	}
+	child.Parents = make([]int, len(parents))
+	child.Birth = p1.Birth
+	for i, p := range parents {
+		child.Parents[i] = p.ID
+		if p.Birth < child.Birth {
+			child.Birth = p.Birth
+		}
+	}
+	if settings.IncestDepth <= 1 {
+		return
+	}
+
+	// Each generation of ancestors is drawn from the one before it in the
+	// parents' own lineage
+	child.Ancestry = make([][]int, 0, settings.IncestDepth-1)
+	for g := 0; g < settings.IncestDepth-1; g++ {
+		var ids []int
+		seen := make(map[int]bool)
+		for _, p := range parents {
+			var prev []int
+			if g == 0 {
+				prev = p.Parents
+			} else if g-1 < len(p.Ancestry) {
+				prev = p.Ancestry[g-1]
+			}
+			for _, id := range prev {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		child.Ancestry = append(child.Ancestry, ids)
+	}
+}
+
+// Returns the IDs of the organism and its ancestors within the given number
+// of generations
+func (org *Organism) kin(depth int) map[int]bool {
+	kin := map[int]bool{org.ID: true}
+	if depth >= 1 {
+		for _, id := range org.Parents {
+			kin[id] = true
+		}
+	}
+	for g := 0; g < depth-1 && g < len(org.Ancestry); g++ {
+		for _, id := range org.Ancestry[g] {
+			kin[id] = true
+		}
+	}
+	return kin
+}
+
+// Returns true if the settings refuse to mate the pair, because they are too
+// alike or one is the other's ancestor or they share an ancestor within
+// IncestDepth generations
+func (s *Settings) incestuous(p1, p2 *Organism) bool {
+	if s.MinMateDistance > 0 && distance(s, p1, p2) < s.MinMateDistance {
+		return true
+	}
+	if s.IncestDepth <= 0 {
+		return false
+	}
+	k1 := p1.kin(s.IncestDepth)
+	for id := range p2.kin(s.IncestDepth) {
+		if k1[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Selects a mate for the first parent from the pool, making several
+// attempts when the settings may refuse the pair and returning nil if none
+// is acceptable or the selector fails
+func findMate(settings *Settings, sel Selector, pool OrganismSlice, p1 *Organism) *Organism {
+	attempts := 1
+	if settings.MinMateDistance > 0 || settings.IncestDepth > 0 {
+		attempts = mateAttempts
+	}
+	for i := 0; i < attempts; i++ {
+		p2 := sel.Select(pool)
+		if p2 == nil {
+			return nil
+		}
+		if !settings.incestuous(p1, p2) {
+			return p2
+		}
+	}
+	return nil
+}
+
+// Selections of a mate before reproduction falls back to mutation only
+const mateAttempts = 5
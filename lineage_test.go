@@ -0,0 +1,100 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import "testing"
+
+// Selector handing out the organisms in turn, then nil
+type listSelector struct{ orgs []*Organism }
+
+func (s *listSelector) Select(OrganismSlice) (o *Organism) {
+	if len(s.orgs) > 0 {
+		o, s.orgs = s.orgs[0], s.orgs[1:]
+	}
+	return
+}
+
+func TestIncestuous(t *testing.T) {
+	org := func(id int, parents []int, ancestry ...[]int) *Organism {
+		o := &Organism{Genome: testGenome(id, 0, testConn{1, 2, 4, 1})}
+		o.Parents, o.Ancestry = parents, ancestry
+		return o
+	}
+	p1 := org(10, []int{5, 6}, []int{1, 2})
+	for _, c := range []struct {
+		name     string
+		p2       *Organism
+		depth    int
+		distance float64
+		want     bool
+	}{
+		{"unrestricted", org(11, []int{5, 7}), 0, 0, false},
+		{"strangers", org(11, []int{7, 8}, []int{3, 4}), 3, 0, false},
+		{"siblings", org(11, []int{5, 7}), 1, 0, true},
+		{"parent", org(5, []int{1}), 1, 0, true},
+		{"cousins", org(11, []int{7, 8}, []int{2, 3}), 2, 0, true},
+		{"distant cousins", org(11, []int{7, 8}, []int{2, 3}), 1, 0, false},
+		{"alike", org(11, []int{7, 8}), 0, 0.5, true},
+		{"different", func() *Organism {
+			o := org(11, []int{7, 8})
+			o.Conns[1].Weight = 3
+			return o
+		}(), 0, 0.5, false},
+	} {
+		s := SettingsForXOR()
+		s.IncestDepth, s.MinMateDistance = c.depth, c.distance
+		if got := s.incestuous(p1, c.p2); got != c.want {
+			t.Errorf("%s: incestuous is %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFindMate(t *testing.T) {
+	s := SettingsForXOR()
+	s.IncestDepth = 1
+	p1 := &Organism{Genome: testGenome(10, 0)}
+	p1.Parents = []int{5}
+	sibling := &Organism{Genome: testGenome(11, 0)}
+	sibling.Parents = []int{5}
+	stranger := &Organism{Genome: testGenome(12, 0)}
+	stranger.Parents = []int{6}
+
+	if m := findMate(s, &listSelector{[]*Organism{sibling, sibling, stranger}}, nil, p1); m != stranger {
+		t.Errorf("Found mate %v, want the stranger after passing over the siblings", m)
+	}
+	if m := findMate(s, &listSelector{[]*Organism{sibling, sibling}}, nil, p1); m != nil {
+		t.Errorf("Found mate %v when the selector ran dry", m)
+	}
+	siblings := make([]*Organism, mateAttempts+1)
+	for i := range siblings {
+		siblings[i] = sibling
+	}
+	siblings[mateAttempts] = stranger
+	if m := findMate(s, &listSelector{siblings}, nil, p1); m != nil {
+		t.Errorf("Found mate %v after %d refusals", m, mateAttempts)
+	}
+}
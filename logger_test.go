@@ -0,0 +1,95 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"sync"
+	"testing"
+)
+
+// Logger remembering the events it receives
+type recordingLogger struct {
+	sync.Mutex
+	events map[string][]map[string]interface{}
+}
+
+func (l *recordingLogger) record(msg string, kv []interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	if l.events == nil {
+		l.events = make(map[string][]map[string]interface{})
+	}
+	m := make(map[string]interface{}, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		m[kv[i].(string)] = kv[i+1]
+	}
+	l.events[msg] = append(l.events[msg], m)
+}
+
+func (l *recordingLogger) Debug(msg string, kv ...interface{}) { l.record(msg, kv) }
+func (l *recordingLogger) Info(msg string, kv ...interface{})  { l.record(msg, kv) }
+func (l *recordingLogger) Warn(msg string, kv ...interface{})  { l.record(msg, kv) }
+
+func TestLoggerEvents(t *testing.T) {
+	settings := func(l Logger) *Settings {
+		s := testSettings()
+		s.AgeToStagnation, s.CompatThreshold = 3, 1
+		s.Logger = l
+		return s
+	}
+	log := &recordingLogger{}
+	_, logged := trainTest(t, settings(log), 15)
+
+	for _, c := range []struct {
+		msg  string
+		keys []string
+	}{
+		{"generation complete", []string{"generation", "best", "mean", "species", "elapsed"}},
+		{"new species created", []string{"species", "organism", "distance"}},
+		{"species culled for stagnation", []string{"species", "age", "stagnation", "size"}},
+	} {
+		events := log.events[c.msg]
+		if len(events) == 0 {
+			t.Errorf("No %q event", c.msg)
+			continue
+		}
+		for _, k := range c.keys {
+			if _, ok := events[0][k]; !ok {
+				t.Errorf("%q event has no %q: %v", c.msg, k, events[0])
+			}
+		}
+	}
+	if n := len(log.events["generation complete"]); n != 15 {
+		t.Errorf("%d generation complete events, want 15", n)
+	}
+
+	// Without a logger the run is silent, and no different
+	_, quiet := trainTest(t, settings(nil), 15)
+	if d := quiet.Difference(logged, 0); d != "" {
+		t.Errorf("Logging changed the run at %s", d)
+	}
+}
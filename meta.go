@@ -0,0 +1,65 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/json"
+)
+
+// Meta holds auxiliary data that experiments attach to organisms and species,
+// such as behavior descriptors or win/loss records. Values which cannot be
+// marshalled are left out when the owner is serialized to JSON.
+type Meta map[string]interface{}
+
+func (m Meta) MarshalJSON() (bytes []byte, err error) {
+
+	// Marshal each value on its own, skipping the ones that fail
+	sk := make(map[string]json.RawMessage, len(m))
+	for k, v := range m {
+		b, e2 := json.Marshal(v)
+		if e2 != nil {
+			continue
+		}
+		sk[k] = b
+	}
+
+	// Marshal the remaining values
+	bytes, err = json.Marshal(sk)
+	return
+}
+
+// Returns a copy of the map. The values themselves are shared.
+func (m Meta) Copy() (clone Meta) {
+	if m == nil {
+		return
+	}
+	clone = make(Meta, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return
+}
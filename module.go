@@ -0,0 +1,90 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+// Modules group the nodes of a genome, such as the outputs driving one part
+// of a robot, so that the structural mutations can keep the groups apart.
+// The input and output nodes are tagged by Settings.InputModules and
+// OutputModules. Untagged nodes, including the bias nodes, are shared by
+// every module.
+
+// Are any modules declared?
+func (s *Settings) modular() bool {
+	return len(s.InputModules) > 0 || len(s.OutputModules) > 0
+}
+
+// Do the nodes belong to the same module?
+func sameModule(ng1, ng2 *NodeGene) bool {
+	return ng1.Module == "" || ng2.Module == "" || ng1.Module == ng2.Module
+}
+
+// Returns the module of a node splitting a connection, which is that of its
+// target if it has one and that of its source otherwise
+func splitModule(src, tgt *NodeGene) string {
+	if tgt.Module != "" {
+		return tgt.Module
+	}
+	return src.Module
+}
+
+// Does the connection join nodes of different modules?
+func (g *Genome) interModule(cg *ConnGene) bool {
+	src, ok1 := g.Nodes[cg.Source]
+	tgt, ok2 := g.Nodes[cg.Target]
+	return ok1 && ok2 && !sameModule(src, tgt)
+}
+
+// Returns the number of enabled connections joining nodes of different
+// modules
+func (g *Genome) InterModuleConns() (n int) {
+	for _, cg := range g.Conns {
+		if cg.Enabled && g.interModule(cg) {
+			n += 1
+		}
+	}
+	return
+}
+
+// Returns the weight of a disjoint or excess gene in the genome distance,
+// which is ModuleCoefficient for connections between modules and 1 otherwise
+func (s *Settings) mismatchWeight(g *Genome, cg *ConnGene) float64 {
+	if s.ModuleCoefficient > 0 && g.interModule(cg) {
+		return s.ModuleCoefficient
+	}
+	return 1
+}
+
+// Returns the markers of the nodes in the same module as the node
+func (g *Genome) moduleMarkers(markers []int, ng *NodeGene) []int {
+	in := make([]int, 0, len(markers))
+	for _, k := range markers {
+		if sameModule(g.Nodes[k], ng) {
+			in = append(in, k)
+		}
+	}
+	return in
+}
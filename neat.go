@@ -27,8 +27,12 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package neat
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
+	"time"
 )
 
 type Decoder interface {
@@ -43,116 +47,539 @@ type PopEval interface {
 	Evaluate(pop *Population, orgEval OrgEval) (err error)
 }
 
+// ContextOrgEval is implemented by organism evaluators which are able to
+// abandon a long evaluation when the context is cancelled
+type ContextOrgEval interface {
+	EvaluateContext(ctx context.Context, org *Organism) (err error)
+}
+
+// ContextPopEval is implemented by population evaluators which stop handing
+// out work, and pass the context on to the organism evaluator, when the
+// context is cancelled
+type ContextPopEval interface {
+	EvaluateContext(ctx context.Context, pop *Population, orgEval OrgEval) (err error)
+}
+
+// BatchEval is implemented by organism evaluators which score many organisms
+// at once. The population evaluators hand such an evaluator the whole
+// population, or one chunk for each worker, instead of one organism at a
+// time. EvaluateBatch returns the fitness of each organism by index.
+type BatchEval interface {
+	EvaluateBatch(orgs []*Organism) (fitness [][]float64, err error)
+}
+
+// Evaluates the organisms as a single batch and gives each its fitness. It
+// is an error for the evaluator to return a different number of results.
+func EvaluateBatch(be BatchEval, orgs []*Organism) (err error) {
+	if len(orgs) == 0 {
+		return
+	}
+	fitness, err := be.EvaluateBatch(orgs)
+	if err != nil {
+		return
+	}
+	if len(fitness) != len(orgs) {
+		return fmt.Errorf("Batch evaluator returned %d results for %d organisms", len(fitness), len(orgs))
+	}
+	for i, o := range orgs {
+		o.Fitness = fitness[i]
+	}
+	return
+}
+
+// Returns an organism evaluator for the batch evaluator, for passing to Train
+// and the population evaluators which then evaluate in batches
+func NewBatchOrgEval(be BatchEval) OrgEval {
+	return batchOrgEval{be}
+}
+
+type batchOrgEval struct {
+	BatchEval
+}
+
+// Evaluates a single organism as a batch of one
+func (b batchOrgEval) Evaluate(org *Organism) error {
+	return EvaluateBatch(b.BatchEval, []*Organism{org})
+}
+
+// Iterate runs the experiment for n generations, panicking on any error. It
+// is retained for existing experiments; new code should use Train or
+// TrainContext.
 func Iterate(settings *Settings, n int, dcode Decoder, popEval PopEval, orgEval OrgEval, arch Archiver, rep Reporter) {
+	_, _, err := Train(settings, n, dcode, popEval, orgEval, arch, rep)
+	if err != nil {
+		panic(err)
+	}
+}
 
-	var err error
+// Train runs the experiment for n generations and returns the best organism
+// found along with the final population
+func Train(settings *Settings, n int, dcode Decoder, popEval PopEval, orgEval OrgEval, arch Archiver, rep Reporter) (best *Organism, pop *Population, err error) {
+	return TrainContext(context.Background(), settings, n, dcode, popEval, orgEval, arch, rep)
+}
+
+// TrainContext runs the experiment for n generations or until the context is
+// done. Cancellation is checked between generations and handed to the
+// population evaluator if it implements ContextPopEval. When the context is
+// done the best organism found so far and the current, possibly partially
+// evaluated, population are returned along with ctx.Err(). With
+// Settings.ALPSLayers the population is split into age layers.
+func TrainContext(ctx context.Context, settings *Settings, n int, dcode Decoder, popEval PopEval, orgEval OrgEval, arch Archiver, rep Reporter) (best *Organism, population *Population, err error) {
+	if settings.ALPSLayers > 0 {
+		return trainALPS(ctx, settings, n, dcode, popEval, orgEval, arch, rep)
+	}
 
 	// Phase search parameters
-	var pth float64 // Pruning threshold
-	var cmplx bool  // Switch between complexifying (true) and simplifying (false)
+	var pth float64                                       // Pruning threshold
+	var mpc float64                                       // Lowest mean population complexity seen while pruning
+	var nochg int                                         // Generations in which pruning did not lower the complexity
+	var cmplx bool                                        // Switch between complexifying (true) and simplifying (false)
 	var addNode, delNode, addConn, delConn, cross float64 // original values
 	addNode = settings.MutateAddNode
 	delNode = settings.MutateDelNode
 	addConn = settings.MutateAddConnection
 	delConn = settings.MutateDelConnection
 	cross = settings.Crossover
-	cmplx = true	// Start with complexifying
+	cmplx = true // Start with complexifying
+	defer settings.saveRates()()
+	defer func() {
+		settings.MutateAddNode = addNode
+		settings.MutateDelNode = delNode
+		settings.MutateAddConnection = addConn
+		settings.MutateDelConnection = delConn
+		settings.Crossover = cross
+	}()
+
+	// Check the settings before starting and note them as given
+	if err = settings.Validate(); err != nil {
+		return
+	}
+	settings.base = settings.snapshot()
+
+	// Seed the random numbers for a repeatable run
+	if settings.Seed != 0 {
+		settings.rand().seed(settings.Seed)
+	}
 
 	// Restore the population
-	var population *Population
-	if arch != nil {
-		population, err = arch.Restore()
-		if err != nil {
-			fmt.Println("Restore failed:", err) // Will begin a new population
-		} else {
-			pth = population.MPC() + settings.PruneThreshold
-		}
+	if population, err = restorePopulation(settings, arch); err != nil {
+		return
+	} else if population != nil {
+		pth = population.MPC() + settings.PruneThreshold
 	}
 
 	// Create the innovation tracker
-	inno := newInnovation(population)
-	defer inno.close()
+	inno := settings.Innovations
+	if inno == nil {
+		inno = newInnovation(population)
+		defer inno.close()
+	} else {
+		inno.persistent = true
+		inno.skipPast(population)
+	}
 
 	//Iterate
+	total := 0 // Evaluations made in this run
 	for i := 0; i < n; i++ {
 
+		// Stop between generations if the context is done
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		start := time.Now()
+		evals, evaluated := 0, false
+		var prev *Population // Population before the roll
+
+		// Resolve the scheduled rates for the generation to be bred
+		gen := 1
+		if population != nil {
+			gen = population.Generation + 1
+		}
+		settings.applySchedules(gen, total)
+
 		// Ensure the current population
 		if population == nil {
 			population, err = initialPopulation(settings, inno)
+			if err != nil {
+				return
+			}
 			pth = population.MPC() + settings.PruneThreshold
 		} else {
 
-			// Determine if the search should switch to decomplexifying
-			if cmplx {}
-			mpc := population.MPC()
-			cmplx = (settings.PruneThreshold > 0) && (mpc < pth)
+			// Determine if the search should switch between complexifying
+			// and simplifying
+			m := population.MPC()
+			if cmplx {
+				if settings.PruneThreshold > 0 && m >= pth {
+					cmplx = false
+					mpc = m
+					nochg = 0
+					settings.log().Info("search switched to simplifying", "generation", population.Generation, "mpc", m)
+				}
 			} else {
-				m = population.MPC()
+				if m < mpc {
+					mpc = m
+					nochg = 0
+				} else {
+					nochg += 1
+				}
 				if nochg > settings.PruneFloor {
 					cmplx = true
+					pth = m + settings.PruneThreshold
+					settings.log().Info("search switched to complexifying", "generation", population.Generation, "mpc", m)
 				}
-				
 			}
 			if cmplx {
-				settings.MutateAddNode = addNode
-				settings.MutateAddConnection = addConn
+				settings.MutateAddNode = settings.scheduled("MutateAddNode", addNode, gen, total)
+				settings.MutateAddConnection = settings.scheduled("MutateAddConnection", addConn, gen, total)
 				settings.MutateDelNode = 0
 				settings.MutateDelConnection = 0
-				settings.Crossover = cross
+				settings.Crossover = settings.scheduled("Crossover", cross, gen, total)
 			} else {
 				settings.MutateAddNode = 0
 				settings.MutateAddConnection = 0
-				settings.MutateDelNode = delNode
-				settings.MutateDelConnection = delConn
+				settings.MutateDelNode = settings.scheduled("MutateDelNode", delNode, gen, total)
+				settings.MutateDelConnection = settings.scheduled("MutateDelConnection", delConn, gen, total)
 				settings.Crossover = 0
 			}
+
+			// Record the state the roll begins from
+			if settings.Record != nil {
+				if err = record(settings, inno, population); err != nil {
+					return
+				}
+			}
+
 			// Roll to the next generation
-			population, err = rollPop(settings, inno, population)
-		}
-		if err != nil {
-			panic(err)
-		}
-
-		// Ensure every organism is decoded
-		var w sync.WaitGroup
-		orgs := population.Species.Organisms(settings)
-		for _, o := range orgs {
-			if o.Phenome == nil {
-				w.Add(1)
-				go func(o *Organism) {
-					o.Phenome, err = dcode.Decode(o.Genome)
-					if err != nil {
-						// Do what exactly?
-					}
-					w.Done()
-				}(o)
+			prev = population
+			var next *Population
+			if settings.deferSpeciation() {
+
+				// Evaluate the children before speciating them
+				var children OrganismSlice
+				children, next, err = Reproduce(settings, inno, population)
+				if err != nil {
+					return
+				}
+				err = decode(dcode, children)
+				if err != nil {
+					return
+				}
+				evals = len(children)
+				brood := &Population{Generation: next.Generation, Species: SpeciesSlice{{Orgs: children}}}
+				err = evaluateCounted(ctx, settings, popEval, brood, orgEval, priorFitness(settings, children))
+				if err != nil {
+					return
+				}
+				SpeciateInto(settings, inno, next, children)
+				evaluated = true
+			} else {
+				next, err = rollPop(settings, inno, population)
+				if err != nil {
+					return
+				}
 			}
+			population = next
 		}
-		w.Wait()
 
-		// Evaluate each organism
-		err = popEval.Evaluate(population, orgEval)
-		if err != nil {
-			panic(err)
-		}
+		// Evaluate each organism unless the children were evaluated already
+		if !evaluated {
+			err = decode(dcode, population.Species.Organisms(settings))
+			if err != nil {
+				return
+			}
+			prior := priorFitness(settings, population.Organisms())
+			if settings.PreEval != nil {
 
-		// Archive the population
-		if arch != nil && (i == n-1 ||
-			(settings.ArchiveFrequency == 0 || i%settings.ArchiveFrequency == 0)) {
-			err = arch.Archive(population)
+				// Pre-evaluate and cull, evaluating only the survivors
+				err = evaluate(ctx, popEval, population, settings.PreEval)
+				if err != nil {
+					return
+				}
+				if err = population.cullProvisional(settings); err != nil {
+					return
+				}
+			}
+			if settings.EvaluationBudget > 0 {
+				evals, err = evaluateBudgeted(ctx, settings, popEval, prev, population, orgEval, prior)
+			} else {
+				evals = len(population.Organisms())
+				err = evaluateCounted(ctx, settings, popEval, population, orgEval, prior)
+			}
 			if err != nil {
-				panic(err)
+				return
+			}
+		}
+		for _, o := range population.Organisms() {
+			if err = checkFitness(settings, o); err != nil {
+				return
 			}
 		}
 
-		// Report the population
-		if rep != nil && (i == n-1 || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
-			err = rep.Report(population)
-			if err != nil {
-				panic(err)
+		total += evals
+		if best, err = endGeneration(settings, population, best, time.Since(start), evals, i, n, arch, rep); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Restores the archived population, if there is an archive, returning nil
+// when there is none to restore and a new population should begin. An error
+// is returned only if the settings differ from those archived in a way the
+// population cannot resume under.
+func restorePopulation(settings *Settings, arch Archiver) (*Population, error) {
+	if arch == nil {
+		return nil, nil
+	}
+	pop, err := arch.Restore()
+	if err != nil {
+		if settings.Logger != nil {
+			settings.Logger.Warn("restore failed, starting a new population", "error", err)
+		} else {
+			fmt.Println("Restore failed:", err) // Will begin a new population
+		}
+		return nil, nil
+	}
+	if err = checkDrift(settings, pop); err != nil {
+		return nil, err
+	}
+	return pop, nil
+}
+
+// Finishes the ith of n generations once the population is evaluated:
+// noting the best organism so far, collecting its statistics and archiving
+// and reporting the population when they are due
+func endGeneration(settings *Settings, population *Population, best *Organism, elapsed time.Duration, evals, i, n int,
+	arch Archiver, rep Reporter) (*Organism, error) {
+
+	// Note the best organism so far, before a collector or hook may stop
+	// the run
+	if c := population.Champion(); c != nil && (best == nil || c.Fitness[0] > best.Fitness[0]) {
+		best = c
+	}
+
+	// Collect the statistics of this generation
+	stats := newStats(population, elapsed, evals)
+	stats.Rates = settings.scheduledRates()
+	for _, c := range settings.Collectors {
+		if err := c.Collect(stats); err != nil {
+			return best, err
+		}
+	}
+	if settings.Hooks.OnGenerationEnd != nil {
+		if err := settings.Hooks.OnGenerationEnd(population, stats); err != nil {
+			return best, err
+		}
+	}
+	settings.log().Info("generation complete", "generation", stats.Generation, "best", stats.BestFitness,
+		"mean", stats.MeanFitness, "species", stats.SpeciesCount, "elapsed", stats.Elapsed)
+
+	// Archive the population along with the settings the run was given,
+	// rather than the rates the schedules and phased search have moved
+	if arch != nil && (i == n-1 ||
+		(settings.ArchiveFrequency == 0 || i%settings.ArchiveFrequency == 0)) {
+		base := settings.base
+		if base == nil {
+			base = settings
+		}
+		population.Settings = base.snapshot()
+		if err := arch.Archive(population); err != nil {
+			return best, err
+		}
+	}
+
+	// Report the population
+	if rep != nil && (i == n-1 || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
+		if err := rep.Report(population); err != nil {
+			return best, err
+		}
+	}
+	return best, nil
+}
+
+// Decodes, concurrently, each organism which does not yet have a phenome
+func decode(dcode Decoder, orgs OrganismSlice) (err error) {
+	var w sync.WaitGroup
+	var m sync.Mutex
+	for _, o := range orgs {
+		if o.Phenome == nil {
+			w.Add(1)
+			go func(o *Organism) {
+				defer w.Done()
+				p, e2 := dcode.Decode(o.Genome)
+				m.Lock()
+				defer m.Unlock()
+				if e2 != nil {
+					if err == nil {
+						err = fmt.Errorf("decoding genome %d: %v", o.ID, e2)
+					}
+					return
+				}
+				o.Phenome = p
+			}(o)
+		}
+	}
+	w.Wait()
+	return
+}
+
+// Returns the fitness the organisms already evaluated had before this
+// generation's evaluation, if their fitness is to be averaged
+func priorFitness(settings *Settings, orgs OrganismSlice) (prior map[*Organism][]float64) {
+	if !settings.AverageFitness {
+		return
+	}
+	prior = make(map[*Organism][]float64)
+	for _, o := range orgs {
+		if o.Evaluations > 0 && len(o.Fitness) > 0 {
+			prior[o] = append([]float64(nil), o.Fitness...)
+		}
+	}
+	return
+}
+
+// Evaluates the population and counts the evaluations of each organism.
+// With Settings.EvaluationRepeats each organism is evaluated that many times,
+// in rounds handed to the population evaluator, and given the mean fitness
+// with the standard deviation of its first objective in its Meta as
+// "fitness_stddev". With Settings.Racing an organism drops out of the rounds
+// once its confidence interval lies clear of the survival cutoff. The new
+// fitness of an organism with a prior fitness is averaged into it.
+func evaluateCounted(ctx context.Context, settings *Settings, popEval PopEval, pop *Population, orgEval OrgEval,
+	prior map[*Organism][]float64) (err error) {
+
+	orgs := pop.Organisms()
+	repeats := settings.EvaluationRepeats
+	if repeats < 1 {
+		repeats = 1
+	}
+
+	// Evaluate in rounds
+	samples := make([][][]float64, len(orgs))
+	active := orgs
+	for r := 0; r < repeats && len(active) > 0; r++ {
+		round := &Population{Generation: pop.Generation, Species: SpeciesSlice{{Orgs: active}}}
+		if r == 0 {
+			round = pop
+		}
+		if err = evaluate(ctx, popEval, round, orgEval); err != nil {
+			return
+		}
+		racing := make(map[*Organism]bool, len(active))
+		for _, o := range active {
+			racing[o] = true
+		}
+		for i, o := range orgs {
+			if racing[o] && len(o.Fitness) > 0 {
+				samples[i] = append(samples[i], append([]float64(nil), o.Fitness...))
+			}
+		}
+		if settings.Racing && r > 0 {
+			active = race(settings, orgs, samples)
+		}
+	}
+
+	// Settle the fitness of each organism, leaving any without a fitness to
+	// be caught by checkFitness
+	for i, o := range orgs {
+		k := len(samples[i])
+		if k == 0 {
+			continue
+		}
+		mean, sd := sampleStats(samples[i])
+		o.Fitness = mean
+		o.Evaluations += k
+		if k > 1 {
+			if o.Meta == nil {
+				o.Meta = make(Meta)
+			}
+			o.Meta["fitness_stddev"] = sd
+		}
+		if p, ok := prior[o]; ok && len(p) == len(o.Fitness) {
+			for j := range p {
+				o.Fitness[j] = p[j] + (o.Fitness[j]-p[j])*float64(k)/float64(o.Evaluations)
+			}
+		}
+	}
+	return
+}
+
+// Returns the mean of the samples in each objective and the standard
+// deviation of the first
+func sampleStats(samples [][]float64) (mean []float64, sd float64) {
+	n := float64(len(samples))
+	mean = make([]float64, len(samples[0]))
+	for _, s := range samples {
+		for j := range mean {
+			if j < len(s) {
+				mean[j] += s[j] / n
 			}
 		}
+	}
+	if len(samples) > 1 && len(mean) > 0 {
+		for _, s := range samples {
+			sd += (s[0] - mean[0]) * (s[0] - mean[0])
+		}
+		sd = math.Sqrt(sd / (n - 1))
+	}
+	return
+}
 
+// Returns the organisms whose place relative to the survival cutoff is still
+// in doubt. The cutoff is the mean fitness of the organism ranked at the
+// SurvivalPercent boundary; an organism is settled once the mean of its
+// first objective is more than RacingZ standard errors from it.
+func race(settings *Settings, orgs OrganismSlice, samples [][][]float64) (active OrganismSlice) {
+
+	// Find the cutoff
+	means := make([]float64, 0, len(orgs))
+	for i := range orgs {
+		if len(samples[i]) > 0 {
+			m, _ := sampleStats(samples[i])
+			means = append(means, m[0])
+		}
+	}
+	if len(means) == 0 {
+		return
+	}
+	sorted := append([]float64(nil), means...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	c := int(math.Ceil(settings.SurvivalPercent*float64(len(sorted)))) - 1
+	if c < 0 {
+		c = 0
 	}
+	cutoff := sorted[c]
 
+	// Keep racing those whose interval straddles it
+	z := settings.RacingZ
+	if z == 0 {
+		z = 1.96
+	}
+	for i, o := range orgs {
+		if len(samples[i]) == 0 {
+			continue
+		}
+		m, sd := sampleStats(samples[i])
+		se := sd / math.Sqrt(float64(len(samples[i])))
+		if math.Abs(m[0]-cutoff) <= z*se {
+			active = append(active, o)
+		}
+	}
+	return
+}
+
+// Evaluates the population, handing the context to the evaluator if it
+// supports one. A cancelled context takes precedence over evaluation errors.
+func evaluate(ctx context.Context, popEval PopEval, pop *Population, orgEval OrgEval) (err error) {
+	if cpe, ok := popEval.(ContextPopEval); ok {
+		err = cpe.EvaluateContext(ctx, pop, orgEval)
+	} else {
+		err = popEval.Evaluate(pop, orgEval)
+	}
+	if e2 := ctx.Err(); e2 != nil {
+		err = e2
+	}
+	return
 }
@@ -0,0 +1,212 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/boggo/neural"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Regenerate the golden files with
+//
+//	go test -run <test> -update
+//
+// when a change to the output is intended, and review the difference.
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// Compares the output with the golden file of the name in testdata, or
+// writes it there with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// Phenome which only holds its genome, the test evaluators scoring genomes
+// directly
+type genomePhenome struct {
+	genome *Genome
+}
+
+func (p genomePhenome) Analyze(inputs []float64) ([]float64, error) {
+	return []float64{0}, nil
+}
+
+type genomeDecoder struct{}
+
+func (genomeDecoder) Decode(g *Genome) (Phenome, error) {
+	return genomePhenome{g}, nil
+}
+
+// Evaluates the organisms one after the other
+type serialEval struct{}
+
+func (serialEval) Evaluate(pop *Population, orgEval OrgEval) error {
+	for _, o := range pop.Organisms() {
+		if err := orgEval.Evaluate(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scores each enabled connection by how near its weight is to a target fixed
+// by its marker, rewarding both structure and tuned weights
+type weightEval struct{}
+
+func (weightEval) Evaluate(org *Organism) error {
+	f := float64(0)
+	for _, m := range org.Conns.sortedMarkers() {
+		if c := org.Conns[m]; c.Enabled {
+			d := c.Weight - math.Sin(float64(m))
+			f += 1 / (1 + d*d)
+		}
+	}
+	org.Fitness = []float64{f}
+	return nil
+}
+
+// A connection of a test genome
+type testConn struct {
+	marker, source, target int
+	weight                 float64
+}
+
+// Builds a genome with a bias (1), two inputs (2 and 3), an output (4) and a
+// hidden node for each other node the connections name
+func testGenome(id int, fitness float64, conns ...testConn) *Genome {
+	g := &Genome{ID: id, Nodes: make(NodeGeneMap), Conns: make(ConnGeneMap), Fitness: []float64{fitness}}
+	g.Nodes[1] = &NodeGene{Marker: 1, Type: neural.BIAS, X: 0, Y: 0}
+	g.Nodes[2] = &NodeGene{Marker: 2, Type: neural.INPUT, X: 0.5, Y: 0}
+	g.Nodes[3] = &NodeGene{Marker: 3, Type: neural.INPUT, X: 1, Y: 0}
+	g.Nodes[4] = &NodeGene{Marker: 4, Type: neural.OUTPUT, X: 0.5, Y: 1, Response: 1}
+	for _, c := range conns {
+		for _, m := range []int{c.source, c.target} {
+			if _, ok := g.Nodes[m]; !ok {
+				g.Nodes[m] = &NodeGene{Marker: m, Type: neural.HIDDEN, X: float64(m) / 10, Y: 0.5, Response: 1}
+			}
+		}
+		g.Conns[c.marker] = &ConnGene{Marker: c.marker, Source: c.source, Target: c.target, Weight: c.weight,
+			Enabled: true}
+	}
+	return g
+}
+
+// Returns the markers of the genome's connections in order
+func connMarkers(g *Genome) []int {
+	return g.Conns.sortedMarkers()
+}
+
+// Returns small, seeded settings for the XOR problem
+func testSettings() *Settings {
+	s := SettingsForXOR()
+	s.PopulationSize = 50
+	s.MutateAddConnection = 0.2
+	s.MutateAddNode = 0.05
+	s.ArchiveFrequency = 0
+	s.Seed = 7
+	return s
+}
+
+// Trains a population for the generations with the weight evaluator
+func trainTest(t testing.TB, s *Settings, generations int) (*Organism, *Population) {
+	t.Helper()
+	best, pop, err := Train(s, generations, genomeDecoder{}, serialEval{}, weightEval{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Train: %v", err)
+	}
+	return best, pop
+}
+
+func TestTrainDeterministic(t *testing.T) {
+	var runs [2][]byte
+	for i := range runs {
+		_, pop := trainTest(t, testSettings(), 20)
+		b, err := json.Marshal(pop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs[i] = b
+	}
+	if !bytes.Equal(runs[0], runs[1]) {
+		t.Error("Runs with the same seed serialized differently")
+	}
+
+	// A different seed should take a different course
+	s := testSettings()
+	s.Seed = 8
+	_, pop := trainTest(t, s, 20)
+	if b, _ := json.Marshal(pop); bytes.Equal(b, runs[0]) {
+		t.Error("Runs with different seeds serialized identically")
+	}
+}
+
+// A hook stopping the run still sees the best organism returned
+func TestHookStopsRun(t *testing.T) {
+	stop := errors.New("Stop")
+	var champ *Organism
+	s := testSettings()
+	s.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		if pop.Generation == 5 {
+			champ = pop.Champion()
+			return stop
+		}
+		return nil
+	}
+	best, pop, err := Train(s, 20, genomeDecoder{}, serialEval{}, weightEval{}, nil, nil)
+	if err != stop {
+		t.Fatalf("Train gave error %v", err)
+	}
+	if pop.Generation != 5 {
+		t.Errorf("Run stopped in generation %d", pop.Generation)
+	}
+	if best == nil || best.Fitness[0] < champ.Fitness[0] {
+		t.Errorf("Best organism %v is not as fit as the last champion %v", best, champ)
+	}
+}
@@ -27,8 +27,10 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package neat
 
 import (
+	"fmt"
 	"github.com/boggo/neural"
 	"math"
+	"sort"
 )
 
 type Phenome interface {
@@ -38,6 +40,98 @@ type Phenome interface {
 type Organism struct {
 	*Genome
 	Phenome `json:"-"`
+	Meta    Meta    `json:",omitempty" xml:"-"` // Experiment data, see Settings.InheritMeta
+	selFit  float64 // Fitness used when selecting parents
+
+	// Behavior of the organism as described by the evaluator, used by
+	// behavioral speciation
+	Behavior []float64 `json:",omitempty"`
+
+	// Score of the organism on each of the evaluator's test cases, higher
+	// being better, used by lexicase selection. Fitness[0] remains the
+	// aggregate by which the organism is otherwise judged.
+	Cases []float64 `json:",omitempty"`
+
+	Age         int // Generations survived as an elite
+	Evaluations int // Times the organism has been evaluated
+	Birth       int `json:",omitempty"` // Generation its oldest genetic material was seeded in, giving its ALPS age
+
+	// The fitness is an estimate from the parents, the evaluation budget
+	// having fallen short. See Settings.EvaluationBudget.
+	Estimated bool `json:",omitempty"`
+
+	// Lineage of the organism: the IDs of its parents and, when the settings
+	// look for incest, of its earlier ancestors by generation
+	Parents  []int   `json:",omitempty"`
+	Ancestry [][]int `json:",omitempty"`
+}
+
+// Analyzes inputs given by node name and returns the outputs by node name.
+// Every named input must be given a value; the input and output nodes must
+// have been named through Settings.InputNames and Settings.OutputNames.
+func (org *Organism) AnalyzeNamed(inputs map[string]float64) (outputs map[string]float64, err error) {
+
+	// Order the inputs as the phenome expects them
+	ins := org.nodesOfType(neural.INPUT)
+	if len(inputs) != len(ins) {
+		err = fmt.Errorf("Organism %d has %d inputs but %d were given", org.ID, len(ins), len(inputs))
+		return
+	}
+	values := make([]float64, len(ins))
+	for i, ng := range ins {
+		v, ok := inputs[ng.Name]
+		if !ok || ng.Name == "" {
+			err = fmt.Errorf("No value given for input %q of organism %d", ng.Name, org.ID)
+			return
+		}
+		values[i] = v
+	}
+
+	// Analyze and name the outputs
+	var results []float64
+	results, err = org.Analyze(values)
+	if err != nil {
+		return
+	}
+	outs := org.nodesOfType(neural.OUTPUT)
+	if len(results) != len(outs) {
+		err = fmt.Errorf("Organism %d has %d outputs but its phenome produced %d", org.ID, len(outs), len(results))
+		return
+	}
+	outputs = make(map[string]float64, len(outs))
+	for i, ng := range outs {
+		if ng.Name == "" {
+			err = fmt.Errorf("Output %d of organism %d is not named", i, org.ID)
+			return
+		}
+		outputs[ng.Name] = results[i]
+	}
+	return
+}
+
+// Returns a deep copy of the organism with the same ID. The copy shares
+// nothing with the original; its phenome is left to be decoded afresh.
+func (org *Organism) Copy() *Organism {
+	return org.CopyWithID(org.ID)
+}
+
+// Returns a deep copy of the organism with the given ID
+func (org *Organism) CopyWithID(id int) *Organism {
+	clone := &Organism{Genome: cloneGenome(org.Genome, id), Meta: org.Meta.Copy(), selFit: org.selFit,
+		Age: org.Age, Evaluations: org.Evaluations, Birth: org.Birth, Estimated: org.Estimated}
+	if org.Behavior != nil {
+		clone.Behavior = append([]float64(nil), org.Behavior...)
+	}
+	if org.Cases != nil {
+		clone.Cases = append([]float64(nil), org.Cases...)
+	}
+	if org.Parents != nil {
+		clone.Parents = append([]int(nil), org.Parents...)
+	}
+	for _, ids := range org.Ancestry {
+		clone.Ancestry = append(clone.Ancestry, append([]int(nil), ids...))
+	}
+	return clone
 }
 
 func cloneOrg(source *Organism, id int) (clone *Organism) {
@@ -46,53 +140,163 @@ func cloneOrg(source *Organism, id int) (clone *Organism) {
 	return
 }
 
-func mutate(settings *Settings, inno *innovation, org *Organism) {
+// Gives the child a copy of the parent's metadata if the settings ask for it
+func inheritMeta(settings *Settings, child, parent *Organism) {
+	if settings.InheritMeta {
+		child.Meta = parent.Meta.Copy()
+	}
+}
+
+// Mutates the organism. Structural additions which would take the genome past
+// the size caps fall back to mutating the weights.
+// With self-adaptation the organism's mutation profile is perturbed first
+// and then scales the mutation settings.
+func mutate(settings *Settings, inno *innovation, org *Organism, counters *rollCounters) {
+	r := settings.rand()
+	defer clampWeights(settings, org)
+
+	if settings.SelfAdaptive {
+		adaptProfile(settings, org.Genome)
+	}
+	prof := settings.profile(org.Genome)
 
+	counters.mutation = mutatedWeights
 	switch {
-	case random.Next() < settings.MutateAddNode:
-		mutateAddNode(inno, org)
-	case random.Next() < settings.MutateAddConnection:
-		mutateAddConn(settings, inno, org)
+	case r.Next() < scaleProb(settings.MutateAddNode, prof.AddNode):
+		counters.mutation = mutatedAddNode
+		if settings.canGrow(org.Genome, 1, 2, counters) {
+			mutateAddNode(settings, inno, org)
+		} else {
+			mutateWeights(settings, org, prof)
+		}
+	case r.Next() < scaleProb(settings.MutateAddConnection, prof.AddConnection):
+		counters.mutation = mutatedAddConn
+		if settings.canGrow(org.Genome, 0, 1, counters) {
+			mutateAddConn(settings, inno, org)
+		} else {
+			mutateWeights(settings, org, prof)
+		}
+	case r.Next() < scaleProb(settings.MutateDelNode, prof.DelNode):
+		counters.mutation = mutatedDelNode
+		mutateDelNode(settings, inno, org)
+	case r.Next() < scaleProb(settings.MutateDelConnection, prof.DelConnection):
+		counters.mutation = mutatedDelConn
+		mutateDelConnection(settings, org)
 	default:
-		for _, cg := range org.Conns {
-			if random.Next() < settings.MutateWeight {
-				if random.Next() < settings.MutateWeightNew {
-					mutateWeightNew(cg)
-				} else {
-					mutateWeight(cg)
-				}
-			}
-			if random.Next() < settings.MutateEnabled {
-				mutateEnabled(cg)
+		mutateWeights(settings, org, prof)
+	}
+}
+
+// Perturbs, replaces and toggles the organism's connections
+func mutateWeights(settings *Settings, org *Organism, prof *MutationProfile) {
+	r := settings.rand()
+	ms := getMarkers()
+	defer putMarkers(ms)
+	*ms = org.Conns.appendSortedMarkers(*ms)
+	for _, k := range *ms {
+		cg := org.Conns[k]
+		if settings.frozen(cg.Frozen) {
+			continue
+		}
+		if r.Next() < scaleProb(settings.MutateWeight, prof.MutateWeight) {
+			if r.Next() < settings.MutateWeightNew {
+				mutateWeightNew(settings, org, cg)
+			} else {
+				mutateWeight(settings, cg, prof.WeightPower)
 			}
 		}
+		if r.Next() < settings.MutateEnabled {
+			mutateEnabled(settings, org, cg)
+		}
+	}
+	if settings.MutateResponse > 0 {
+		mutateResponses(settings, org)
 	}
 }
 
-func mutateAddNode(inno *innovation, org *Organism) {
+// Perturbs the response of each hidden and output node with probability
+// MutateResponse, keeping it between MinResponse and MaxResponse
+func mutateResponses(settings *Settings, org *Organism) {
+	r := settings.rand()
+	power := settings.ResponsePower
+	if power <= 0 {
+		power = 0.5
+	}
+	for _, k := range org.Nodes.sortedMarkers() {
+		ng := org.Nodes[k]
+		if ng.Type != neural.HIDDEN && ng.Type != neural.OUTPUT || settings.frozen(ng.Frozen) {
+			continue
+		}
+		if r.Next() < settings.MutateResponse {
+			ng.Response = clampResponse(settings, ng.EffectiveResponse()+r.Gaussian()*power)
+		}
+	}
+}
 
-	// Pick a connection to split
-	var old *ConnGene
-	i := random.Int(len(org.Conns))
-	j := 0
-	for _, v := range org.Conns {
-		if i == j {
-			old = v
-			break
+// Keeps the response within the settings' bounds
+func clampResponse(settings *Settings, resp float64) float64 {
+	lo, hi := settings.responseBounds()
+	switch {
+	case math.IsNaN(resp):
+		return 1
+	case resp < lo:
+		return lo
+	case resp > hi:
+		return hi
+	}
+	return resp
+}
+
+// Returns the mean absolute difference between the responses of the hidden
+// and output nodes found in both genomes
+func responseDifference(g1, g2 *Genome) float64 {
+	var n, d float64
+	for _, k := range g1.Nodes.sortedMarkers() {
+		ng1 := g1.Nodes[k]
+		if ng1.Type != neural.HIDDEN && ng1.Type != neural.OUTPUT {
+			continue
+		}
+		if ng2, ok := g2.Nodes[k]; ok {
+			n += 1
+			d += math.Abs(ng1.EffectiveResponse() - ng2.EffectiveResponse())
+		}
+	}
+	if n > 0 {
+		d = d / n
+	}
+	return d
+}
+
+func mutateAddNode(settings *Settings, inno *innovation, org *Organism) {
+
+	// Pick a connection to split, leaving disabled and frozen connections
+	// alone
+	markers := make([]int, 0, len(org.Conns))
+	for _, k := range org.Conns.sortedMarkers() {
+		if org.Conns[k].Enabled && !settings.frozen(org.Conns[k].Frozen) {
+			markers = append(markers, k)
 		}
-		j += 1
 	}
+	if len(markers) == 0 {
+		return
+	}
+	old := org.Conns[markers[settings.rand().Int(len(markers))]]
 
 	// Note the old source and target
 	src := org.Nodes[old.Source]
 	tgt := org.Nodes[old.Target]
 
 	// Create a new node
-	ng := &NodeGene{Type: neural.HIDDEN, X: (src.X + tgt.X) / 2.0, Y: (src.Y + tgt.Y) / 2.0}
+	ng := &NodeGene{Type: neural.HIDDEN, X: (src.X + tgt.X) / 2.0, Y: (src.Y + tgt.Y) / 2.0,
+		Module: splitModule(src, tgt), Response: 1.0}
 	ng.Marker = inno.blessNodeGene(nodeKey{ng.X, ng.Y})
+	if _, ok := org.Nodes[ng.Marker]; ok {
+		return // Another split already put a node here
+	}
 	org.Nodes[ng.Marker] = ng
 
-	// Create the new connections
+	// Create the new connections, the first passing the signal on unchanged
+	// and the second carrying the old weight
 	cg1 := &ConnGene{Source: src.Marker, Target: ng.Marker, Enabled: true, Weight: 1.0}
 	cg1.Marker = inno.blessConnGene(connKey{cg1.Source, cg1.Target})
 	org.Conns[cg1.Marker] = cg1
@@ -102,145 +306,307 @@ func mutateAddNode(inno *innovation, org *Organism) {
 
 	// Disable the old connection
 	old.Enabled = false
+	old.Split = true
 }
 
+// Adds a connection between two nodes not yet connected, trying a few pairs
+// before giving up. Connections run forward, from a lower node to a higher
+// one, unless the settings allow recurrent connections, and never from a
+// node to itself unless self connections are also allowed. Without recurrent
+// connections a pair is also passed over if the target already leads back
+// to the source, as nodes sharing a layer could otherwise close a cycle.
+// With modules declared, ModuleBias is the probability that the pair is
+// drawn from within one module.
 func mutateAddConn(settings *Settings, inno *innovation, org *Organism) {
-
-	// Pick 2 nodes to connect
-	var ng1, ng2 *NodeGene
-	a := random.Int(len(org.Nodes))
-	b := random.Int(len(org.Nodes)-settings.BiasCount-settings.InputCount) +
-		settings.BiasCount + settings.InputCount
-	j := 0
-	for _, v := range org.Nodes {
-		if a == j {
-			ng1 = v
+	r := settings.rand()
+	markers := org.Nodes.sortedMarkers()
+	intra := settings.modular() && r.Next() < settings.ModuleBias
+	for attempt := 0; attempt < addConnAttempts; attempt++ {
+
+		// Pick 2 nodes to connect
+		var ng1, ng2 *NodeGene
+		if intra {
+			ng2 = org.Nodes[markers[targetIndex(settings, org)]]
+			in := org.Genome.moduleMarkers(markers, ng2)
+			ng1 = org.Nodes[in[r.Int(len(in))]]
+		} else {
+			a := r.Int(len(org.Nodes))
+			ng1 = org.Nodes[markers[a]]
+			ng2 = org.Nodes[markers[targetIndex(settings, org)]]
 		}
-		if b == j {
-			ng2 = v
+		if ng1, ng2, ok := connectable(settings, ng1, ng2); ok && !org.Genome.connected(ng1.Marker, ng2.Marker) &&
+			(settings.AllowRecurrent || !org.Genome.reaches(ng2.Marker, ng1.Marker)) {
+
+			// Make the new connection
+			cg := &ConnGene{Source: ng1.Marker, Target: ng2.Marker, Enabled: true, Weight: r.Gaussian()}
+			cg.Marker = inno.blessConnGene(connKey{cg.Source, cg.Target})
+			org.Conns[cg.Marker] = cg
+			return
 		}
-		j++
 	}
+}
+
+// Attempts at finding a pair of nodes to connect before the mutation gives up
+const addConnAttempts = 5
 
-	// validate the nodes
+// Picks the index of a node, other than a bias or input node, to be the end
+// of a new connection
+func targetIndex(settings *Settings, org *Organism) int {
+	return settings.rand().Int(len(org.Nodes)-settings.BiasCount-settings.InputCount) +
+		settings.BiasCount + settings.InputCount
+}
+
+// Orders the pair of nodes as source and target, returning false if the
+// settings would not allow them to be connected
+func connectable(settings *Settings, ng1, ng2 *NodeGene) (src, tgt *NodeGene, ok bool) {
 	if ng1.Marker == ng2.Marker {
-		return // No connections to the same node
+		return ng1, ng2, settings.AllowRecurrent && settings.AllowSelfConnections &&
+			ng1.Type != neural.BIAS && ng1.Type != neural.INPUT
 	}
-	if ng1.Y > ng2.Y {
+	if !settings.AllowRecurrent && ng1.Y > ng2.Y {
 		ng1, ng2 = ng2, ng1
 	}
-	if ng1.Type == neural.OUTPUT {
-		return
+	if !settings.AllowRecurrent && ng1.Type == neural.OUTPUT {
+		return ng1, ng2, false
 	}
 	if ng2.Type == neural.BIAS || ng2.Type == neural.INPUT {
-		return
+		return ng1, ng2, false
 	}
+	return ng1, ng2, true
+}
 
-	// Look for an existing connection between these nodes
-	found := false
-	for _, c := range org.Conns {
-		if c.Source == ng1.Marker && c.Target == ng2.Marker {
-			found = true
-			break
-		}
+func mutateWeight(settings *Settings, cg *ConnGene, power float64) {
+	cg.Weight = clampWeight(settings, cg.Weight+settings.rand().Gaussian()*power)
+}
+
+// Bounds a weight to the range allowed by the settings. NaN, which cannot be
+// ordered, becomes 0.
+func clampWeight(settings *Settings, w float64) float64 {
+	max := settings.MaxWeight
+	if max <= 0 {
+		max = 30.0
 	}
-	if found {
-		return // we already have this connection
+	switch {
+	case math.IsNaN(w):
+		return 0
+	case w > max:
+		return max
+	case w < -max:
+		return -max
 	}
-
-	// Make the new connection
-	cg := &ConnGene{Source: ng1.Marker, Target: ng2.Marker, Enabled: true, Weight: random.Gaussian()}
-	cg.Marker = inno.blessConnGene(connKey{cg.Source, cg.Target})
-	org.Conns[cg.Marker] = cg
+	return w
 }
 
-func mutateWeight(cg *ConnGene) {
-	cg.Weight += random.Gaussian()
-	if cg.Weight > 30.0 {
-		cg.Weight = 30
-	}
-	if cg.Weight < -30.0 {
-		cg.Weight = -30
+// Bounds every connection weight of the organism, other than frozen ones
+func clampWeights(settings *Settings, org *Organism) {
+	for _, cg := range org.Conns {
+		if !settings.frozen(cg.Frozen) {
+			cg.Weight = clampWeight(settings, cg.Weight)
+		}
 	}
 }
 
-func mutateWeightNew(cg *ConnGene) {
-	cg.Weight = random.Gaussian()
+func mutateWeightNew(settings *Settings, org *Organism, cg *ConnGene) {
+	n := 0
+	if settings.weightNeedsFanIn() {
+		n = org.fanIn(cg.Target)
+	}
+	cg.Weight = settings.initialWeight(n)
 }
 
-func mutateEnabled(cg *ConnGene) {
+// Enables the connection unless it was disabled by being split and the
+// settings keep such connections off, or it would close a cycle in a
+// feedforward network
+func mutateEnabled(settings *Settings, org *Organism, cg *ConnGene) {
+	if cg.Split && !settings.ReenableSplit {
+		return
+	}
+	if !cg.Enabled && !settings.AllowRecurrent && org.Genome.reaches(cg.Target, cg.Source) {
+		return
+	}
 	cg.Enabled = true
 }
 
-func crossover(inno *innovation, p1, p2 *Organism) (child *Organism) {
+// Mates two organisms. Matching genes are inherited from either parent at
+// random or, for a MateAveragingProb share of matings, take the average of the
+// parents' weights. Disjoint and excess genes come from the fitter parent, as
+// decided by compareFitness. When neither parent is fitter they come from
+// both or, with MateEqualRandom, each is inherited with even odds.
+func crossover(settings *Settings, inno *innovation, p1, p2 *Organism) (child *Organism) {
+	r := settings.rand()
 
 	// Order parents by fitness
-	if p2.Fitness[0] > p1.Fitness[0] {
+	c := compareFitness(p1.Fitness, p2.Fitness)
+	if c < 0 {
 		p1, p2 = p2, p1
 	}
+	equal := c == 0
+	average := r.Next() < settings.MateAveragingProb
 
 	// Create the new child
-	genome := &Genome{ID: inno.nextID(), Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
+	genome := &Genome{ID: inno.nextID(), Nodes: make(map[int]*NodeGene, len(p1.Nodes)),
+		Conns:   make(map[int]*ConnGene, len(p1.Conns)),
+		Profile: mateProfiles(settings, p1.Genome, p2.Genome)}
 	child = &Organism{Genome: genome}
+	inheritMeta(settings, child, p1)
+	defer clampWeights(settings, child)
 
 	// Crossover the connection genes
-	for _, cg1 := range p1.Conns {
+	for _, k := range p1.Conns.sortedMarkers() {
+		cg1 := p1.Conns[k]
 		cg2, ok := p2.Conns[cg1.Marker]
 		if ok {
-			if random.Next() < 0.5 {
+			if settings.frozen(cg1.Frozen) || settings.frozen(cg2.Frozen) {
+				if settings.frozen(cg1.Frozen) {
+					child.Conns[cg1.Marker] = cloneConn(cg1) // Frozen genes are passed on unchanged
+				} else {
+					child.Conns[cg2.Marker] = cloneConn(cg2)
+				}
+			} else if average {
+				cg := cloneConn(cg1)
+				cg.Weight = (cg1.Weight + cg2.Weight) / 2.0
+				child.Conns[cg.Marker] = cg
+			} else if r.Next() < 0.5 {
 				child.Conns[cg1.Marker] = cloneConn(cg1)
 			} else {
 				child.Conns[cg2.Marker] = cloneConn(cg2)
 			}
-		} else {
+		} else if !equal || !settings.MateEqualRandom || r.Next() < 0.5 {
 			child.Conns[cg1.Marker] = cloneConn(cg1)
 		}
 	}
+	if equal {
+		for _, k := range p2.Conns.sortedMarkers() {
+			if _, ok := p1.Conns[k]; !ok && (!settings.MateEqualRandom || r.Next() < 0.5) {
+				child.Conns[k] = cloneConn(p2.Conns[k])
+			}
+		}
+	}
+	child.Genome.dedupeConns()
+
+	// Drop the genes which, inherited from different parents, would close a
+	// cycle, keeping the older of its links
+	if !settings.AllowRecurrent {
+		conns := child.Conns
+		child.Conns = make(ConnGeneMap, len(conns))
+		for _, k := range conns.sortedMarkers() {
+			if cg := conns[k]; !cg.Enabled || !child.Genome.reaches(cg.Target, cg.Source) {
+				child.Conns[k] = cg
+			}
+		}
+	}
+	setLineage(settings, child, p1, p2)
 
-	// Crossover the node genes
-	var ng1, ng2 *NodeGene
-	var ok bool
-	for _, cg1 := range child.Conns {
-		_, ok = child.Nodes[cg1.Source] // look first in child
-		if !ok {
-			ng1, ok = p1.Nodes[cg1.Source] // Grab from parent 1
-			if ok {
-				ng2, ok = p2.Nodes[cg1.Source] // Grab from parent 2
-				if ok {
-					if random.Next() < 0.5 {
-						child.Nodes[ng1.Marker] = cloneNode(ng1)
-					} else {
-						child.Nodes[ng2.Marker] = cloneNode(ng2)
-					}
-				} else {
-					child.Nodes[ng1.Marker] = cloneNode(ng1)
-				}
+	// Crossover the node genes, taking those of the fitter parent's sensors
+	// and outputs and those used by the child's connections
+	addNode := func(m int) {
+		if _, ok := child.Nodes[m]; ok {
+			return // already in the child
+		}
+		ng1, ok1 := p1.Nodes[m]
+		ng2, ok2 := p2.Nodes[m]
+		switch {
+		case ok1 && ok2:
+			if r.Next() < 0.5 {
+				child.Nodes[m] = cloneNode(ng1)
 			} else {
-				child.Nodes[ng2.Marker] = cloneNode(ng2)
+				child.Nodes[m] = cloneNode(ng2)
 			}
+		case ok1:
+			child.Nodes[m] = cloneNode(ng1)
+		case ok2:
+			child.Nodes[m] = cloneNode(ng2)
 		}
-		_, ok = child.Nodes[cg1.Target] // look first in child
-		if !ok {
-			ng1, ok = p1.Nodes[cg1.Target] // Grab from parent 1
-			if ok {
-				ng2, ok = p2.Nodes[cg1.Target] // Grab from parent 2
-				if ok {
-					if random.Next() < 0.5 {
-						child.Nodes[ng1.Marker] = cloneNode(ng1)
-					} else {
-						child.Nodes[ng2.Marker] = cloneNode(ng2)
-					}
-				} else {
-					child.Nodes[ng1.Marker] = cloneNode(ng1)
+	}
+	for _, k := range p1.Nodes.sortedMarkers() {
+		if p1.Nodes[k].Type != neural.HIDDEN {
+			addNode(k)
+		}
+	}
+	for _, k := range child.Conns.sortedMarkers() {
+		addNode(child.Conns[k].Source)
+		addNode(child.Conns[k].Target)
+	}
+	return
+}
+
+// Compares two fitness vectors, returning 1 if a is fitter, -1 if b is fitter
+// and 0 if neither is. Single-valued fitness is compared directly; with more
+// objectives one vector is fitter only if it Pareto-dominates the other, that
+// is it is no worse in any objective and better in at least one.
+func compareFitness(a, b []float64) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	better, worse := false, false
+	for i := 0; i < n; i++ {
+		switch {
+		case a[i] > b[i]:
+			better = true
+		case a[i] < b[i]:
+			worse = true
+		}
+	}
+	switch {
+	case better && !worse:
+		return 1
+	case worse && !better:
+		return -1
+	}
+	return 0
+}
+
+// Returns an error if the organism's fitness is missing, NaN or infinite.
+// When the settings allow it, invalid values are replaced instead.
+func checkFitness(settings *Settings, org *Organism) (err error) {
+	if len(org.Fitness) == 0 {
+		err = fmt.Errorf("Organism %d was not given a fitness by the evaluator", org.ID)
+		return
+	}
+	for i, f := range org.Fitness {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			if settings.ReplaceInvalidFitness {
+				org.Fitness[i] = settings.InvalidFitness
+				continue
+			}
+			err = fmt.Errorf("Organism %d was given an invalid fitness, Fitness[%d] is %v", org.ID, i, f)
+			return
+		}
+	}
+	if settings.Selection == "lexicase" {
+		if len(org.Cases) == 0 {
+			err = fmt.Errorf("Organism %d was not given case scores for lexicase selection", org.ID)
+			return
+		}
+		for i, f := range org.Cases {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				if settings.ReplaceInvalidFitness {
+					org.Cases[i] = settings.InvalidFitness
+					continue
 				}
-			} else {
-				child.Nodes[ng2.Marker] = cloneNode(ng2)
+				err = fmt.Errorf("Organism %d was given an invalid case score, Cases[%d] is %v", org.ID, i, f)
+				return
 			}
 		}
 	}
 	return
 }
 
+// Returns the distance used to speciate the organisms. With behavioral
+// speciation this is the Euclidean distance between their behaviors, falling
+// back to the genome distance when either has yet to be given a behavior.
+func compatDistance(settings *Settings, o1, o2 *Organism) float64 {
+	if settings.Speciation == "behavior" && len(o1.Behavior) > 0 && len(o1.Behavior) == len(o2.Behavior) {
+		var d float64
+		for i, b := range o1.Behavior {
+			d += (b - o2.Behavior[i]) * (b - o2.Behavior[i])
+		}
+		return math.Sqrt(d)
+	}
+	return distance(settings, o1, o2)
+}
+
 // Returns the compatibiliy distance between the two organisms
 func distance(settings *Settings, o1, o2 *Organism) float64 {
 
@@ -270,34 +636,96 @@ func distance(settings *Settings, o1, o2 *Organism) float64 {
 
 	// Make the comparison
 	var d, e, m, w float64
-	for _, cg1 := range o1.Conns {
+	ms := getMarkers()
+	defer putMarkers(ms)
+	*ms = o1.Conns.appendSortedMarkers(*ms)
+	for _, k := range *ms {
+		cg1 := o1.Conns[k]
 		cg2, ok := o2.Conns[cg1.Marker]
 		if ok {
 			m += 1 // This is a match
 			w += math.Abs(cg1.Weight - cg2.Weight)
 		} else {
 			if cg1.Marker > mm {
-				e += 1 // Excess
+				e += settings.mismatchWeight(o1.Genome, cg1) // Excess
 			} else {
-				d += 1 // disjoint
+				d += settings.mismatchWeight(o1.Genome, cg1) // disjoint
+			}
+		}
+	}
+	if settings.ModuleCoefficient > 0 { // Look for disjoints in o2
+		for _, cg2 := range o2.Conns {
+			if _, ok := o1.Conns[cg2.Marker]; !ok {
+				d += settings.mismatchWeight(o2.Genome, cg2)
 			}
 		}
+	} else {
+		d += float64(len(o2.Conns)) - m
 	}
-	d += float64(len(o2.Conns)) - m // Look for disjoints in o2
 
 	if m > 0 { // take the average weight difference
 		w = w / m
 	}
+	w = settings.WeightCoefficient * w
+	if settings.ResponseCoefficient > 0 {
+		w += settings.ResponseCoefficient * responseDifference(o1.Genome, o2.Genome)
+	}
 
-	return settings.ExcessCoefficient*e + settings.DisjointCoefficient*d +
-		settings.WeightCoefficient*w
+	return settings.ExcessCoefficient*e + settings.DisjointCoefficient*d + w
 }
 
 type OrganismSlice []*Organism
 
-func (os OrganismSlice) Len() int           { return len(os) }
-func (os OrganismSlice) Swap(i, j int)      { os[i], os[j] = os[j], os[i] }
-func (os OrganismSlice) Less(i, j int) bool { return os[i].Fitness[0] < os[j].Fitness[0] }
+func (os OrganismSlice) Len() int      { return len(os) }
+func (os OrganismSlice) Swap(i, j int) { os[i], os[j] = os[j], os[i] }
+func (os OrganismSlice) Less(i, j int) bool {
+	if os[i].Fitness[0] == os[j].Fitness[0] {
+		return os[i].ID > os[j].ID // Reversed, this favors the older organism
+	}
+	return os[i].Fitness[0] < os[j].Fitness[0]
+}
+
+func (os OrganismSlice) contains(org *Organism) bool {
+	for _, o := range os {
+		if o == org {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorts the organisms in place by their first fitness, descending or
+// ascending, and returns them. Equal fitness is ordered by ascending ID, so
+// the older organism comes first either way, and organisms without a fitness
+// come last.
+func (os OrganismSlice) SortByFitness(desc bool) OrganismSlice {
+	sort.SliceStable(os, func(i, j int) bool {
+		a, b := os[i], os[j]
+		switch {
+		case len(a.Fitness) == 0 || len(b.Fitness) == 0:
+			return len(b.Fitness) == 0 && len(a.Fitness) > 0
+		case a.Fitness[0] == b.Fitness[0]:
+			return a.ID < b.ID
+		case desc:
+			return a.Fitness[0] > b.Fitness[0]
+		}
+		return a.Fitness[0] < b.Fitness[0]
+	})
+	return os
+}
+
+// Returns the n fittest organisms, ordered as by SortByFitness, leaving the
+// slice itself untouched
+func (os OrganismSlice) TopN(n int) OrganismSlice {
+	top := append(OrganismSlice(nil), os...).SortByFitness(true)
+	if n < 0 {
+		n = 0
+	}
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
 
 func (os OrganismSlice) TotalFitness() float64 {
 	sum := float64(0)
@@ -307,6 +735,110 @@ func (os OrganismSlice) TotalFitness() float64 {
 	return sum
 }
 
+// The reductions below measure the first fitness of the organisms, skipping
+// those without a fitness or with a NaN one. They return false when no
+// organism is left to measure.
+
+// Returns the fitness of each organism measured
+func (os OrganismSlice) fitnesses() []float64 {
+	fits := make([]float64, 0, len(os))
+	for _, o := range os {
+		if len(o.Fitness) > 0 && !math.IsNaN(o.Fitness[0]) {
+			fits = append(fits, o.Fitness[0])
+		}
+	}
+	return fits
+}
+
+// Returns the mean fitness of the organisms
+func (os OrganismSlice) MeanFitness() (float64, bool) {
+	fits := os.fitnesses()
+	if len(fits) == 0 {
+		return 0, false
+	}
+	sum := float64(0)
+	for _, f := range fits {
+		sum += f
+	}
+	return sum / float64(len(fits)), true
+}
+
+// Returns the highest fitness of the organisms
+func (os OrganismSlice) MaxFitness() (float64, bool) {
+	if b := os.Best(); b != nil {
+		return b.Fitness[0], true
+	}
+	return 0, false
+}
+
+// Returns the lowest fitness of the organisms
+func (os OrganismSlice) MinFitness() (float64, bool) {
+	fits := os.fitnesses()
+	if len(fits) == 0 {
+		return 0, false
+	}
+	min := fits[0]
+	for _, f := range fits[1:] {
+		min = math.Min(min, f)
+	}
+	return min, true
+}
+
+// Returns the population standard deviation of the organisms' fitness
+func (os OrganismSlice) StddevFitness() (float64, bool) {
+	mean, ok := os.MeanFitness()
+	if !ok {
+		return 0, false
+	}
+	fits := os.fitnesses()
+	v := float64(0)
+	for _, f := range fits {
+		v += (f - mean) * (f - mean)
+	}
+	return math.Sqrt(v / float64(len(fits))), true
+}
+
+// Returns the pth percentile, 0 to 100, of the organisms' fitness,
+// interpolating linearly between the closest ranks. The 50th is the median.
+func (os OrganismSlice) PercentileFitness(p float64) (float64, bool) {
+	fits := os.fitnesses()
+	if len(fits) == 0 || math.IsNaN(p) || p < 0 || p > 100 {
+		return 0, false
+	}
+	sort.Float64s(fits)
+	r := p / 100 * float64(len(fits)-1)
+	i := int(r)
+	if i == len(fits)-1 {
+		return fits[i], true
+	}
+	return fits[i] + (r-float64(i))*(fits[i+1]-fits[i]), true
+}
+
+// Returns the index of the organism with the highest fitness, the older (the
+// lower ID) of those equally fit, or -1 if there is none
+func (os OrganismSlice) BestIndex() int {
+	best := -1
+	for i, o := range os {
+		if len(o.Fitness) == 0 || math.IsNaN(o.Fitness[0]) {
+			continue
+		}
+		if best < 0 || o.Fitness[0] > os[best].Fitness[0] ||
+			(o.Fitness[0] == os[best].Fitness[0] && o.ID < os[best].ID) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Returns the organism with the highest fitness, as chosen by BestIndex, or
+// nil if there is none
+func (os OrganismSlice) Best() *Organism {
+	if i := os.BestIndex(); i >= 0 {
+		return os[i]
+	}
+	return nil
+}
+
 // Removes a node gene
 // From http://sharpneat.sourceforge.net/phasedsearch.html
 // Neuron deletion is slightly more complex. The deletion algorithm attempts to replace neurons with connections to maintain any circuits a neuron may have participated in, in further generations those connections themselves will be open to deletion. This approach provides NEAT with the ability to delete whole structures, not just connections.
@@ -314,33 +846,32 @@ func (os OrganismSlice) TotalFitness() float64 {
 //Because we replace connected neurons with connections we must be careful which neurons we delete. Any neuron with only incoming or only outgoing connections is at a dead-end of a circuit and can therefore be safely deleted with all of it's connections. However, a neuron with multiple incoming and multiple outgoing connections will require a large number of connections to substitute for the loss of the neuron - we must fully connect all of the original neuron's source neurons with its target neurons, this could be done but may actually be detrimental since the functionality represented by the neuron is now distributed over a number of connections, and this cannot easily be reversed. Because of this, such neurons are omitted from the process of selecting neurons for deletion.
 //
 // Neurons with only one incoming or one outgoing connection can be replaced with however many connections were on the other side of the neuron, therefore these are candidates for deletion.
-
-func mutateDelNode(settings *Settings, org *Organism) {
+//
+// A replacing connection takes the marker of the innovation joining its new
+// ends, or is dropped as rewire describes.
+func mutateDelNode(settings *Settings, inno *innovation, org *Organism) {
 
 	// Pick a node to delete
-	var n *NodeGene
-	i := random.Int(len(org.Nodes))
-	for _, v := range org.Nodes {
-		n = v
-		if i == 0 {
-			break
-		}
-		i -= 1
-	}
-	if n.Type != neural.HIDDEN {
+	markers := org.Nodes.sortedMarkers()
+	n := org.Nodes[markers[settings.rand().Int(len(markers))]]
+	if n.Type != neural.HIDDEN || settings.frozen(n.Frozen) {
 		return
-	} // Only remove hidden nodes
+	} // Only remove hidden nodes which are not frozen
 
 	// Node the incoming and outgoing connections
 	incoming := make([]*ConnGene, 0, 10)
 	outgoing := make([]*ConnGene, 0, 10)
-	for _, c := range org.Conns {
+	for _, k := range org.Conns.sortedMarkers() {
+		c := org.Conns[k]
 		if c.Source == n.Marker {
 			outgoing = append(outgoing, c)
 		}
 		if c.Target == n.Marker {
 			incoming = append(incoming, c)
 		}
+		if (c.Source == n.Marker || c.Target == n.Marker) && settings.frozen(c.Frozen) {
+			return // Removing the node would change a frozen connection
+		}
 	}
 
 	// The node is cut off (no incoming or no outgoing connections)
@@ -362,7 +893,7 @@ func mutateDelNode(settings *Settings, org *Organism) {
 		// Replace the node in the outgoing connections
 		a := incoming[0]
 		for _, c := range outgoing {
-			c.Source = a.Source
+			rewire(inno, org, c, a.Source, c.Target, n.Marker)
 		}
 
 		// Delete the incoming connection and node
@@ -374,7 +905,7 @@ func mutateDelNode(settings *Settings, org *Organism) {
 		// Replace the node in the incoming connections
 		a := outgoing[0]
 		for _, c := range incoming {
-			c.Target = a.Target
+			rewire(inno, org, c, c.Source, a.Target, n.Marker)
 		}
 
 		// Delete the incoming connection and node
@@ -383,31 +914,41 @@ func mutateDelNode(settings *Settings, org *Organism) {
 	}
 }
 
+// Moves the connection off the removed node to join the source and target,
+// or drops it if they are the same node, already joined or would still
+// include the removed node, as through its self connection
+func rewire(inno *innovation, org *Organism, c *ConnGene, source, target, removed int) {
+	delete(org.Conns, c.Marker)
+	if source == target || source == removed || target == removed || org.Genome.connected(source, target) {
+		return
+	}
+	c.Source, c.Target = source, target
+	c.Marker = inno.blessConnGene(connKey{source, target})
+	org.Conns[c.Marker] = c
+}
+
 // Removes a connection gene
 // From http://sharpneat.sourceforge.net/phasedsearch.html
 // Connection deletion is very simply the deletion of a randomly selected connection, all connections are considered to be available for deletion. When a connection is deleted the neurons that were at each end of the connection are tested to check if they are no longer connected to by other connections, if this is the case then the stranded neuron is also deleted. Note that a more thorough cleanup routine could be invoked at this point that cleans up any dead-end structures that could not possibly be functional, but this can become complex and so we leave NEAT to eliminate such structures naturally.
-//
 func mutateDelConnection(settings *Settings, org *Organism) {
 
-	// Pick a connection to remove
-	if len(org.Conns) == 0 {
-		return
-	}
-	var c *ConnGene
-	i := random.Int(len(org.Conns))
-	for _, v := range org.Conns {
-		c = v
-		if i == 0 {
-			break
+	// Pick a connection to remove, leaving frozen connections alone
+	markers := make([]int, 0, len(org.Conns))
+	for _, k := range org.Conns.sortedMarkers() {
+		if !settings.frozen(org.Conns[k].Frozen) {
+			markers = append(markers, k)
 		}
-		i -= 1
 	}
+	if len(markers) == 0 {
+		return
+	}
+	c := org.Conns[markers[settings.rand().Int(len(markers))]]
 
 	// Node the nodes connected
 	src := org.Nodes[c.Source]
 	tgt := org.Nodes[c.Target]
-	sok := src.Type == neural.HIDDEN // source ok to delete as well
-	tok := tgt.Type == neural.HIDDEN // target ok to delete as well
+	sok := src.Type == neural.HIDDEN && !settings.frozen(src.Frozen) // source ok to delete as well
+	tok := tgt.Type == neural.HIDDEN && !settings.frozen(tgt.Frozen) // target ok to delete as well
 	for k, v := range org.Conns {
 		if k != c.Marker {
 			sok = sok && !(v.Source == src.Marker || v.Target == src.Marker)
@@ -0,0 +1,553 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"github.com/boggo/neural"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCheckFitness(t *testing.T) {
+	cases := []struct {
+		name    string
+		fitness []float64
+		replace bool
+		want    []float64 // nil = an error
+	}{
+		{"valid", []float64{1, 2}, false, []float64{1, 2}},
+		{"missing", nil, false, nil},
+		{"nan", []float64{math.NaN()}, false, nil},
+		{"inf", []float64{1, math.Inf(-1)}, false, nil},
+		{"nan replaced", []float64{math.NaN()}, true, []float64{-1}},
+		{"inf replaced", []float64{1, math.Inf(1)}, true, []float64{1, -1}},
+	}
+	for _, c := range cases {
+		s := &Settings{ReplaceInvalidFitness: c.replace, InvalidFitness: -1}
+		org := &Organism{Genome: &Genome{ID: 3, Fitness: c.fitness}}
+		err := checkFitness(s, org)
+		switch {
+		case c.want == nil && err == nil:
+			t.Errorf("%s: no error", c.name)
+		case c.want == nil && !strings.Contains(err.Error(), "Organism 3"):
+			t.Errorf("%s: error %q does not name the organism", c.name, err)
+		case c.want != nil && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.want != nil && floatsDifference(org.Fitness, c.want, 0) != "":
+			t.Errorf("%s: fitness %v, want %v", c.name, org.Fitness, c.want)
+		}
+	}
+}
+
+// Gives one organism of the third generation a NaN fitness
+type nanEval struct {
+	calls int
+}
+
+func (e *nanEval) Evaluate(org *Organism) error {
+	e.calls++
+	weightEval{}.Evaluate(org)
+	if e.calls == 125 {
+		org.Fitness[0] = math.NaN()
+	}
+	return nil
+}
+
+func TestTrainInvalidFitness(t *testing.T) {
+	s := testSettings()
+	_, pop, err := Train(s, 5, genomeDecoder{}, serialEval{}, &nanEval{}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid fitness") {
+		t.Fatalf("Train returned %v, want an invalid fitness error", err)
+	}
+	if pop != nil && pop.Generation > 3 {
+		t.Errorf("Population rolled on to generation %d after the invalid fitness", pop.Generation)
+	}
+
+	// Replaced, the run goes on without a NaN in sight
+	s = testSettings()
+	s.ReplaceInvalidFitness = true
+	best, pop, err := Train(s, 5, genomeDecoder{}, serialEval{}, &nanEval{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsNaN(best.Fitness[0]) {
+		t.Error("Best organism has a NaN fitness")
+	}
+	for _, o := range pop.Organisms() {
+		if len(o.Fitness) > 0 && math.IsNaN(o.Fitness[0]) {
+			t.Errorf("Organism %d has a NaN fitness", o.ID)
+		}
+	}
+}
+
+func TestWeightsBounded(t *testing.T) {
+	s := testSettings()
+	s.MaxWeight = 2
+	s.MutateWeight = 1
+	_, pop := trainTest(t, s, 15)
+	for _, o := range pop.Organisms() {
+		for _, m := range o.Conns.sortedMarkers() {
+			if w := o.Conns[m].Weight; math.Abs(w) > 2 || math.IsNaN(w) {
+				t.Fatalf("Organism %d has weight %v beyond MaxWeight", o.ID, w)
+			}
+		}
+	}
+}
+
+// Parents for the crossover tests. They share connections 1 and 2; 3 is
+// disjoint in the first and 5 and 6 are excess in the second.
+func crossoverParents(f1, f2 float64) (p1, p2 *Organism) {
+	p1 = &Organism{Genome: testGenome(1, f1,
+		testConn{1, 2, 4, 1}, testConn{2, 3, 4, 2}, testConn{3, 1, 4, 3})}
+	p2 = &Organism{Genome: testGenome(2, f2,
+		testConn{1, 2, 4, -1}, testConn{2, 3, 4, 4}, testConn{5, 2, 5, 5}, testConn{6, 5, 4, 6})}
+	return
+}
+
+// Mates the parents many times and returns the children
+func mateOften(s *Settings, p1, p2 *Organism) []*Organism {
+	inno := newInnovationAt(10, 10)
+	defer inno.close()
+	s.rand().seed(1)
+	children := make([]*Organism, 200)
+	for i := range children {
+		children[i] = crossover(s, inno, p1, p2)
+	}
+	return children
+}
+
+func TestCrossoverAveraging(t *testing.T) {
+	p1, p2 := crossoverParents(2, 1)
+	for _, child := range mateOften(&Settings{MateAveragingProb: 1}, p1, p2) {
+		if got := fmt.Sprint(connMarkers(child.Genome)); got != "[1 2 3]" {
+			t.Fatalf("Child has connections %s, want those of the fitter parent, [1 2 3]", got)
+		}
+		if w1, w2 := child.Conns[1].Weight, child.Conns[2].Weight; w1 != 0 || w2 != 3 {
+			t.Fatalf("Matching weights are %v and %v, want the averages 0 and 3", w1, w2)
+		}
+		if w := child.Conns[3].Weight; w != 3 {
+			t.Fatalf("Disjoint weight is %v, want the fitter parent's 3", w)
+		}
+	}
+}
+
+func TestCrossoverPicking(t *testing.T) {
+	p1, p2 := crossoverParents(2, 1)
+	seen := make(map[float64]bool)
+	for _, child := range mateOften(&Settings{}, p1, p2) {
+		if got := fmt.Sprint(connMarkers(child.Genome)); got != "[1 2 3]" {
+			t.Fatalf("Child has connections %s, want those of the fitter parent, [1 2 3]", got)
+		}
+		w := child.Conns[1].Weight
+		if w != 1 && w != -1 {
+			t.Fatalf("Matching weight is %v, want either parent's, 1 or -1", w)
+		}
+		seen[w] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("Matching gene was only ever inherited from one parent: %v", seen)
+	}
+}
+
+func TestCrossoverFrozen(t *testing.T) {
+	p1, p2 := crossoverParents(2, 1)
+	p2.Conns[1].Frozen = true
+	for _, avg := range []float64{0, 1} {
+		for _, child := range mateOften(&Settings{MateAveragingProb: avg}, p1, p2) {
+			if cg := child.Conns[1]; cg.Weight != -1 || !cg.Frozen {
+				t.Fatalf("MateAveragingProb %v: frozen gene inherited as %+v, want it unchanged", avg, *cg)
+			}
+		}
+	}
+}
+
+func TestCrossoverInheritance(t *testing.T) {
+	cases := []struct {
+		name   string
+		f1, f2 []float64
+		want   string // Connections of every child
+	}{
+		{"first fitter", []float64{2}, []float64{1}, "[1 2 3]"},
+		{"second fitter", []float64{1}, []float64{2}, "[1 2 5 6]"},
+		{"equal", []float64{1}, []float64{1}, "[1 2 3 5 6]"},
+		{"second dominates", []float64{1, 2}, []float64{2, 2}, "[1 2 5 6]"},
+		{"neither dominates", []float64{2, 1}, []float64{1, 2}, "[1 2 3 5 6]"},
+	}
+	for _, c := range cases {
+		p1, p2 := crossoverParents(0, 0)
+		p1.Fitness, p2.Fitness = c.f1, c.f2
+		for _, child := range mateOften(&Settings{}, p1, p2) {
+			if got := fmt.Sprint(connMarkers(child.Genome)); got != c.want {
+				t.Errorf("%s: child has connections %s, want %s", c.name, got, c.want)
+				break
+			}
+			if err := child.Validate(); err != nil {
+				t.Errorf("%s: %v", c.name, err)
+				break
+			}
+		}
+	}
+}
+
+func TestCrossoverEqualRandom(t *testing.T) {
+	p1, p2 := crossoverParents(1, 1)
+	counts := make(map[int]int)
+	children := mateOften(&Settings{MateEqualRandom: true}, p1, p2)
+	for _, child := range children {
+		for _, m := range connMarkers(child.Genome) {
+			counts[m]++
+		}
+	}
+	for _, m := range []int{1, 2} {
+		if counts[m] != len(children) {
+			t.Errorf("Matching connection %d was inherited by %d of %d children", m, counts[m], len(children))
+		}
+	}
+	for _, m := range []int{3, 5, 6} {
+		if n := counts[m]; n < len(children)/4 || n > 3*len(children)/4 {
+			t.Errorf("Connection %d was inherited by %d of %d children, want about half", m, n, len(children))
+		}
+	}
+}
+
+func TestCrossoverFeedforward(t *testing.T) {
+
+	// Tied parents each feedforward, joining hidden nodes 5 and 6 in
+	// opposite directions with their disjoint genes 3 and 7
+	p1 := &Organism{Genome: testGenome(1, 1,
+		testConn{1, 2, 5, 1}, testConn{2, 5, 4, 1}, testConn{3, 5, 6, 1}, testConn{4, 6, 4, 1})}
+	p2 := &Organism{Genome: testGenome(2, 1,
+		testConn{1, 2, 5, 1}, testConn{2, 5, 4, 1}, testConn{7, 6, 5, 1}, testConn{8, 3, 6, 1})}
+	for _, child := range mateOften(&Settings{}, p1, p2) {
+		if got := fmt.Sprint(connMarkers(child.Genome)); got != "[1 2 3 4 8]" {
+			t.Fatalf("Child has connections %s, want the later link of the cycle dropped, [1 2 3 4 8]", got)
+		}
+	}
+	for _, child := range mateOften(&Settings{AllowRecurrent: true}, p1, p2) {
+		if got := fmt.Sprint(connMarkers(child.Genome)); got != "[1 2 3 4 7 8]" {
+			t.Fatalf("With recurrence allowed, child has connections %s, want [1 2 3 4 7 8]", got)
+		}
+	}
+
+	// A matching gene enabled in the less fit parent only
+	p1 = &Organism{Genome: testGenome(1, 2,
+		testConn{1, 2, 5, 1}, testConn{3, 5, 6, 1}, testConn{4, 6, 4, 1}, testConn{7, 6, 5, 1})}
+	p1.Conns[3].Enabled = false
+	p2 = &Organism{Genome: testGenome(2, 1, testConn{1, 2, 5, 1}, testConn{3, 5, 6, 1})}
+	for _, child := range mateOften(&Settings{}, p1, p2) {
+		if cycle := child.Cycle(); cycle != nil {
+			t.Fatalf("Child has the cycle %v", cycle)
+		}
+	}
+}
+
+// Changes every part of the organism which a deep copy must not share
+func scribble(org *Organism) {
+	for _, cg := range org.Conns {
+		cg.Weight += 100
+		cg.Enabled = !cg.Enabled
+	}
+	for _, ng := range org.Nodes {
+		ng.Response += 1
+		ng.Name = "changed"
+	}
+	org.Conns[99] = &ConnGene{Marker: 99, Source: 1, Target: 4}
+	delete(org.Nodes, 2)
+	org.Fitness[0] = -100
+	org.Behavior[0] = -100
+	org.Meta["note"] = "changed"
+	org.Parents[0] = -1
+	org.Ancestry[0][0] = -1
+}
+
+func TestOrganismCopy(t *testing.T) {
+	newOrg := func() *Organism {
+		org := &Organism{Genome: testGenome(5, 3, testConn{1, 2, 4, 1}, testConn{2, 2, 5, 2}, testConn{3, 5, 4, 3}),
+			Behavior: []float64{1, 2}, Meta: Meta{"note": "original"}, Parents: []int{1, 2},
+			Ancestry: [][]int{{1, 2}, {0}}, Evaluations: 2}
+		org.Profile = unitProfile()
+		return org
+	}
+	for _, c := range []struct {
+		name string
+		copy func(*Organism) *Organism
+		id   int
+	}{
+		{"Copy", (*Organism).Copy, 5},
+		{"CopyWithID", func(o *Organism) *Organism { return o.CopyWithID(8) }, 8},
+		{"Genome.Copy", func(o *Organism) *Organism {
+			return &Organism{Genome: o.Genome.Copy(), Behavior: []float64{0, 0}, Meta: Meta{},
+				Parents: []int{0}, Ancestry: [][]int{{0}}}
+		}, 5},
+		{"Genome.CopyWithID", func(o *Organism) *Organism {
+			return &Organism{Genome: o.Genome.CopyWithID(9), Behavior: []float64{0, 0}, Meta: Meta{},
+				Parents: []int{0}, Ancestry: [][]int{{0}}}
+		}, 9},
+	} {
+		orig, want := newOrg(), newOrg()
+		cp := c.copy(orig)
+		if cp.ID != c.id {
+			t.Errorf("%s: copy has ID %d, want %d", c.name, cp.ID, c.id)
+		}
+		if d := cp.Genome.difference(orig.Genome, 0); d != "" && c.id == orig.ID {
+			t.Errorf("%s: copy differs at %s", c.name, d)
+		}
+		scribble(cp)
+		cp.Profile.AddNode = 7
+		if d := orig.difference(want, 0); d != "" {
+			t.Errorf("%s: changing the copy changed the original at %s", c.name, d)
+		}
+		if orig.Meta["note"] != "original" || orig.Parents[0] != 1 || orig.Ancestry[0][0] != 1 ||
+			orig.Profile.AddNode != 1 {
+			t.Errorf("%s: changing the copy changed the original's lineage, profile or metadata", c.name)
+		}
+	}
+}
+
+func TestExampleCopy(t *testing.T) {
+	o1 := &Organism{Genome: testGenome(1, 2, testConn{1, 2, 4, 1}), Behavior: []float64{0}, Meta: Meta{},
+		Parents: []int{0}, Ancestry: [][]int{{0}}}
+	o2 := &Organism{Genome: testGenome(2, 1, testConn{1, 2, 4, 2})}
+	s := &Species{ID: 1, Orgs: OrganismSlice{o1, o2}, Example: o1}
+
+	// Copying the example leaves it, and the member it aliases, alone
+	cp := s.Example.Copy()
+	scribble(cp)
+	if s.Example != s.Orgs[0] {
+		t.Error("Example no longer aliases its member")
+	}
+	if w := s.Example.Conns[1].Weight; w != 1 || len(s.Example.Conns) != 1 {
+		t.Errorf("Changing the copy of the example changed it: weight %v, %d connections", w, len(s.Example.Conns))
+	}
+}
+
+// Activates a feedforward genome, taking each node past the sensors in order
+// of position, with linear hidden nodes and logistic outputs
+func activate(g *Genome, inputs []float64) []float64 {
+	nodes := make([]*NodeGene, 0, len(g.Nodes))
+	for _, ng := range g.Nodes {
+		nodes = append(nodes, ng)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Y != nodes[j].Y {
+			return nodes[i].Y < nodes[j].Y
+		}
+		return nodes[i].Marker < nodes[j].Marker
+	})
+	values := make(map[int]float64, len(nodes))
+	var outputs []float64
+	for _, ng := range nodes {
+		switch ng.Type {
+		case neural.BIAS:
+			values[ng.Marker] = 1
+			continue
+		case neural.INPUT:
+			values[ng.Marker], inputs = inputs[0], inputs[1:]
+			continue
+		}
+		var sum float64
+		for _, k := range g.Conns.sortedMarkers() {
+			if cg := g.Conns[k]; cg.Enabled && cg.Target == ng.Marker {
+				sum += values[cg.Source] * cg.Weight
+			}
+		}
+		sum *= ng.EffectiveResponse()
+		if ng.Type == neural.OUTPUT {
+			sum = 1 / (1 + math.Exp(-sum))
+			outputs = append(outputs, sum)
+		}
+		values[ng.Marker] = sum
+	}
+	return outputs
+}
+
+func TestAddNodePreservesOutput(t *testing.T) {
+	s := &Settings{}
+	r := rand.New(rand.NewSource(1))
+	parent := &Organism{Genome: testGenome(1, 0, testConn{1, 2, 4, 1.5}, testConn{2, 3, 4, -0.7},
+		testConn{3, 1, 4, 0.3}, testConn{4, 2, 5, 0.8}, testConn{5, 5, 4, 1.2})}
+	parent.Conns[6] = &ConnGene{Marker: 6, Source: 3, Target: 5, Weight: 2}
+	inno := newInnovationAt(10, 10)
+	defer inno.close()
+	s.rand().seed(1)
+	split := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		child := parent.Copy()
+		mutateAddNode(s, inno, child)
+		for _, k := range connMarkers(child.Genome) {
+			if cg := child.Conns[k]; !cg.Enabled && cg.Split {
+				split[k] = true
+				if k == 6 {
+					t.Fatalf("Split the disabled connection 6")
+				}
+			}
+		}
+		if len(child.Nodes) != len(parent.Nodes)+1 || len(child.Conns) != len(parent.Conns)+2 {
+			t.Fatalf("Child has %d nodes and %d connections", len(child.Nodes), len(child.Conns))
+		}
+		for j := 0; j < 10; j++ {
+			in := []float64{2*r.Float64() - 1, 2*r.Float64() - 1}
+			want, got := activate(parent.Genome, in), activate(child.Genome, in)
+			if math.Abs(want[0]-got[0]) > 1e-12 {
+				t.Fatalf("Splitting changed the output for %v from %v to %v", in, want, got)
+			}
+		}
+	}
+	if len(split) != 5 {
+		t.Errorf("Split connections %v, want each of the 5 enabled ones", split)
+	}
+
+	// Split connections stay disabled unless the settings allow otherwise
+	for _, reenable := range []bool{false, true} {
+		s.ReenableSplit = reenable
+		child := parent.Copy()
+		cg := child.Conns[1]
+		cg.Enabled, cg.Split = false, true
+		mutateEnabled(s, child, cg)
+		if cg.Enabled != reenable {
+			t.Errorf("With ReenableSplit %v the split connection's Enabled is %v", reenable, cg.Enabled)
+		}
+	}
+}
+
+func TestOrganismSliceStats(t *testing.T) {
+	org := func(id int, fitness ...float64) *Organism {
+		return &Organism{Genome: &Genome{ID: id, Fitness: fitness}}
+	}
+	type stats struct {
+		ok                 bool
+		mean, max, min, sd float64
+		p0, p25, p50, p100 float64
+		best               int
+	}
+	measure := func(os OrganismSlice) (s stats) {
+		s.mean, s.ok = os.MeanFitness()
+		s.max, _ = os.MaxFitness()
+		s.min, _ = os.MinFitness()
+		s.sd, _ = os.StddevFitness()
+		s.p0, _ = os.PercentileFitness(0)
+		s.p25, _ = os.PercentileFitness(25)
+		s.p50, _ = os.PercentileFitness(50)
+		s.p100, _ = os.PercentileFitness(100)
+		s.best = os.BestIndex()
+		return
+	}
+	for _, c := range []struct {
+		name string
+		orgs OrganismSlice
+		want stats
+	}{
+		{"empty", nil, stats{best: -1}},
+		{"unmeasured", OrganismSlice{org(1), org(2, math.NaN())}, stats{best: -1}},
+		{"single", OrganismSlice{org(1, 4)}, stats{true, 4, 4, 4, 0, 4, 4, 4, 4, 0}},
+		{"several", OrganismSlice{org(5, 3), org(1), org(7, 4), org(2, math.NaN()), org(3, 1), org(4, 2)},
+			stats{true, 2.5, 4, 1, math.Sqrt(1.25), 1, 1.75, 2.5, 4, 2}},
+		{"tied", OrganismSlice{org(7, 4), org(3, 4), org(5, 1)},
+			stats{true, 3, 4, 1, math.Sqrt(2), 1, 2.5, 4, 4, 1}},
+	} {
+		got := measure(c.orgs)
+		if math.Abs(got.sd-c.want.sd) < 1e-12 {
+			got.sd = c.want.sd
+		}
+		if got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	os := OrganismSlice{org(5, 3), org(1), org(7, 4), org(3, 4), org(4, 2)}
+	if _, ok := os.PercentileFitness(101); ok {
+		t.Error("Percentile 101 was measured")
+	}
+	if b := os.Best(); b.ID != 3 {
+		t.Errorf("Best is organism %d, want the older of the fittest, 3", b.ID)
+	}
+	if (OrganismSlice{}).Best() != nil {
+		t.Error("Empty slice has a best organism")
+	}
+	ids := func(os OrganismSlice) (ids []int) {
+		for _, o := range os {
+			ids = append(ids, o.ID)
+		}
+		return
+	}
+	if got := fmt.Sprint(ids(os.TopN(3))); got != "[3 7 5]" {
+		t.Errorf("Top 3 are %s, want [3 7 5]", got)
+	}
+	if got := fmt.Sprint(ids(os)); got != "[5 1 7 3 4]" {
+		t.Errorf("TopN reordered the slice to %s", got)
+	}
+	if got := fmt.Sprint(ids(os.SortByFitness(false))); got != "[4 5 3 7 1]" {
+		t.Errorf("Ascending order is %s, want [4 5 3 7 1]", got)
+	}
+}
+
+func TestResponseBounds(t *testing.T) {
+	for _, c := range []struct {
+		min, max float64
+		lo, hi   float64
+	}{
+		{0, 0, 0.1, 5},
+		{0.5, 3, 0.5, 3},
+		{0, 50, 0.1, 50},
+	} {
+		s := SettingsForXOR()
+		s.MaxWeight = 2 // Responses are not weights
+		s.MutateResponse, s.ResponsePower = 1, 100
+		s.MinResponse, s.MaxResponse = c.min, c.max
+		if err := s.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		s.rand().seed(1)
+		org := &Organism{Genome: testGenome(1, 0, testConn{1, 2, 5, 1}, testConn{2, 5, 4, 1})}
+		atLo, atHi := false, false
+		for i := 0; i < 100; i++ {
+			mutateResponses(s, org)
+			for _, k := range []int{4, 5} {
+				r := org.Nodes[k].Response
+				if r < c.lo || r > c.hi {
+					t.Fatalf("Bounds %v and %v gave response %v", c.min, c.max, r)
+				}
+				atLo, atHi = atLo || r == c.lo, atHi || r == c.hi
+			}
+		}
+		if !atLo || !atHi {
+			t.Errorf("Bounds %v and %v: responses never reached %v and %v", c.min, c.max, c.lo, c.hi)
+		}
+	}
+
+	for _, b := range [][2]float64{{-1, 0}, {3, 2}, {6, 0}} {
+		s := SettingsForXOR()
+		s.MinResponse, s.MaxResponse = b[0], b[1]
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate accepted MinResponse %v and MaxResponse %v", b[0], b[1])
+		}
+	}
+}
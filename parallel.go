@@ -0,0 +1,179 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"sync"
+)
+
+// Breeds the species concurrently on up to Settings.ReproductionWorkers
+// goroutines. Each species breeds with its own copy of the settings, a random
+// number generator seeded from the shared one and a journal of the
+// innovations it makes. The journals are then committed in species order, so
+// the markers and IDs handed out, like the offspring themselves, do not
+// depend on how the goroutines were scheduled. The error of the first species
+// to fail, in species order, is returned.
+func breedParallel(settings *Settings, inno *innovation, generation int, living SpeciesSlice, pool OrganismSlice,
+	parents map[uint64]bool, broods []speciesBrood) error {
+
+	// Draw the seeds in order before starting
+	seeds := make([]int64, len(living))
+	for j := range seeds {
+		seeds[j] = settings.rand().Int63()
+	}
+
+	// Breed the species
+	journals := make([]*journal, len(living))
+	errs := make([]error, len(living))
+	var w sync.WaitGroup
+	sem := make(chan struct{}, settings.ReproductionWorkers)
+	for j, s := range living {
+		w.Add(1)
+		sem <- struct{}{}
+		go func(j int, s *Species) {
+			defer func() {
+				<-sem
+				w.Done()
+			}()
+			local := *settings
+			local.rng = newRng(seeds[j])
+			sel, _ := newSelector(&local, generation) // Already checked by Reproduce
+			journals[j] = newJournal()
+			broods[j].orgs, errs[j] = breed(&local, &innovation{journal: journals[j]}, sel, s, pool, parents,
+				broods[j].cnt, &broods[j].counters)
+		}(j, s)
+	}
+	w.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Replace the provisional markers and IDs
+	for j := range living {
+		journals[j].commit(inno, broods[j].orgs)
+	}
+	return nil
+}
+
+// Provisional markers start here, well above any real marker, so that new
+// genes still sort after the existing ones
+const journalBase = 1 << 30
+
+// Kind of an innovation in the journal
+type journalEntry struct {
+	node bool    // Is this a node rather than a connection?
+	nkey nodeKey // Key of a node
+	ckey connKey // Key of a connection, possibly between provisional nodes
+}
+
+// Records the innovations made while breeding a species, handing out
+// provisional markers for the real ones to be substituted later
+type journal struct {
+	nodes   map[nodeKey]int
+	conns   map[connKey]int
+	entries []journalEntry // In the order they were made
+}
+
+func newJournal() *journal {
+	return &journal{nodes: make(map[nodeKey]int), conns: make(map[connKey]int)}
+}
+
+// Returns a placeholder ID. The real ones are given out on committing.
+func (j *journal) nextID() int {
+	return 0
+}
+
+func (j *journal) blessNodeGene(key nodeKey) int {
+	m, ok := j.nodes[key]
+	if !ok {
+		m = journalBase + len(j.entries)
+		j.nodes[key] = m
+		j.entries = append(j.entries, journalEntry{node: true, nkey: key})
+	}
+	return m
+}
+
+func (j *journal) blessConnGene(key connKey) int {
+	m, ok := j.conns[key]
+	if !ok {
+		m = journalBase + len(j.entries)
+		j.conns[key] = m
+		j.entries = append(j.entries, journalEntry{ckey: key})
+	}
+	return m
+}
+
+// Blesses the journal's innovations with the real innovation history, in the
+// order they were made, and relabels the brood's genes and IDs to match
+func (j *journal) commit(inno *innovation, brood OrganismSlice) {
+	remap := make(map[int]int, len(j.entries))
+	actual := func(m int) int {
+		if m >= journalBase {
+			return remap[m]
+		}
+		return m
+	}
+	for i, e := range j.entries {
+		if e.node {
+			remap[journalBase+i] = inno.blessNodeGene(e.nkey)
+		} else {
+			remap[journalBase+i] = inno.blessConnGene(connKey{actual(e.ckey.Source), actual(e.ckey.Target)})
+		}
+	}
+
+	for _, o := range brood {
+		o.ID = inno.nextID()
+		if len(remap) == 0 {
+			continue
+		}
+		var nodes []*NodeGene
+		for k, ng := range o.Nodes {
+			if k >= journalBase {
+				delete(o.Nodes, k)
+				ng.Marker = remap[k]
+				nodes = append(nodes, ng)
+			}
+		}
+		for _, ng := range nodes {
+			o.Nodes[ng.Marker] = ng
+		}
+		var conns []*ConnGene
+		for k, cg := range o.Conns {
+			cg.Source, cg.Target = actual(cg.Source), actual(cg.Target)
+			if k >= journalBase {
+				delete(o.Conns, k)
+				cg.Marker = remap[k]
+				conns = append(conns, cg)
+			}
+		}
+		for _, cg := range conns {
+			o.Conns[cg.Marker] = cg
+		}
+	}
+}
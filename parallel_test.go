@@ -0,0 +1,87 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// Breeding on one worker, which breeds the species one after another, and on
+// many must take the same course
+func TestParallelBreedingDeterministic(t *testing.T) {
+	var runs [][]byte
+	for _, workers := range []int{1, 4, 16} {
+		s := testSettings()
+		s.PopulationSize = 100
+		s.CompatThreshold = 1
+		s.ReproductionWorkers = workers
+		_, pop := trainTest(t, s, 20)
+		if len(pop.Species) < 2 {
+			t.Fatalf("Only %d species to breed in parallel", len(pop.Species))
+		}
+		b, err := json.Marshal(pop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, b)
+	}
+	for i := 1; i < len(runs); i++ {
+		if !bytes.Equal(runs[0], runs[i]) {
+			t.Errorf("Run %d serialized differently from the run on one worker", i)
+		}
+	}
+}
+
+// Measures breeding a large population on increasing numbers of workers
+func BenchmarkReproduce(b *testing.B) {
+	s := testSettings()
+	s.PopulationSize = 10000
+	s.CompatThreshold = 1
+	s.MutateAddConnection, s.MutateAddNode = 0.3, 0.1
+	pop, inno := evaluatedPopulation(b, s)
+	defer inno.close()
+	pop = rollTest(b, s, inno, pop, 3)
+	b.Logf("%d organisms in %d species", len(pop.Organisms()), len(pop.Species))
+	for _, workers := range []int{0, 1, 2, 4, 8} {
+		b.Run(fmt.Sprint(workers), func(b *testing.B) {
+			s.ReproductionWorkers = workers
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				curr := pop.Copy()
+				b.StartTimer()
+				if _, _, err := Reproduce(s, inno, curr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package phenome
+
+import (
+	"encoding/gob"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"math"
+	"sort"
+)
+
+func init() {
+	gob.Register(compiledPhenome{})
+}
+
+// Phenome which runs the network from flat arrays. The nodes are ordered by
+// position, as the NEAT decoder orders them, and each node past the sensors
+// is given the sum of its enabled incoming connections, taken in marker
+// order and scaled by the node's response, passed through the logistic
+// function or, for linear nodes, as it is. The response is folded into the
+// weights when compiling. A connection from a node
+// which comes later in the order, or from the node itself, carries that
+// node's value from the previous activation, so recurrent networks keep
+// their state between calls.
+type compiledPhenome struct {
+	values  []float64 // Value of each node in order
+	bias    []int     // Indices of the bias nodes
+	inputs  []int     // Indices of the input nodes
+	outputs []int     // Indices of the output nodes
+
+	// The nodes to compute, in order, with their incoming connections in
+	// srcs and weights from starts[i] to starts[i+1]
+	targets []int
+	linear  []bool
+	layers  []float64 // Vertical position of each node to compute, grouping them into layers
+	starts  []int
+	srcs    []int
+	weights []float64
+}
+
+// Compiles the genome into a phenome whose activation needs no map lookups
+// or allocations beyond the returned outputs
+func NewCompiled(genome *neat.Genome) (neat.Phenome, error) {
+	return NewCompiledWith(genome, neural.SIGMOID, neural.SIGMOID)
+}
+
+// Compiles the genome, giving the hidden and output nodes the activation
+// functions given, either neural.SIGMOID or neural.DIRECT for linear nodes
+func NewCompiledWith(genome *neat.Genome, hidden, output neural.FuncType) (neat.Phenome, error) {
+	for _, f := range []neural.FuncType{hidden, output} {
+		if f != neural.SIGMOID && f != neural.DIRECT {
+			return nil, fmt.Errorf("Compiled phenomes do not support activation function %v", f)
+		}
+	}
+
+	// Order the nodes by position
+	nodes := make([]*neat.NodeGene, 0, len(genome.Nodes))
+	for _, ng := range genome.Nodes {
+		nodes = append(nodes, ng)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Marker < b.Marker
+	})
+	index := make(map[int]int, len(nodes))
+	for i, ng := range nodes {
+		index[ng.Marker] = i
+	}
+
+	// Gather the incoming connections of each node in marker order
+	incoming := make(map[int][]*neat.ConnGene, len(nodes))
+	markers := make([]int, 0, len(genome.Conns))
+	for k := range genome.Conns {
+		markers = append(markers, k)
+	}
+	sort.Ints(markers)
+	for _, k := range markers {
+		cg := genome.Conns[k]
+		if !cg.Enabled {
+			continue
+		}
+		if _, ok := index[cg.Source]; !ok {
+			return nil, fmt.Errorf("Connection %d has a missing source node %d", cg.Marker, cg.Source)
+		}
+		if _, ok := index[cg.Target]; !ok {
+			return nil, fmt.Errorf("Connection %d has a missing target node %d", cg.Marker, cg.Target)
+		}
+		incoming[cg.Target] = append(incoming[cg.Target], cg)
+	}
+
+	// Flatten the network
+	p := &compiledPhenome{values: make([]float64, len(nodes))}
+	for i, ng := range nodes {
+		switch ng.Type {
+		case neural.BIAS:
+			p.bias = append(p.bias, i)
+			continue
+		case neural.INPUT:
+			p.inputs = append(p.inputs, i)
+			continue
+		case neural.OUTPUT:
+			p.outputs = append(p.outputs, i)
+		}
+		p.targets = append(p.targets, i)
+		p.layers = append(p.layers, ng.Y)
+		if ng.Type == neural.OUTPUT {
+			p.linear = append(p.linear, output == neural.DIRECT)
+		} else {
+			p.linear = append(p.linear, hidden == neural.DIRECT)
+		}
+		p.starts = append(p.starts, len(p.srcs))
+		for _, cg := range incoming[ng.Marker] {
+			p.srcs = append(p.srcs, index[cg.Source])
+			p.weights = append(p.weights, cg.Weight*ng.EffectiveResponse())
+		}
+	}
+	p.starts = append(p.starts, len(p.srcs))
+	return p, nil
+}
+
+// Analyzes the inputs, returning the outputs in a new slice
+func (p *compiledPhenome) Analyze(inputs []float64) (outputs []float64, err error) {
+	outputs = make([]float64, len(p.outputs))
+	err = p.AnalyzeInto(inputs, outputs)
+	return
+}
+
+// Analyzes the inputs, writing the outputs into the given slice, without
+// allocating
+func (p *compiledPhenome) AnalyzeInto(inputs, outputs []float64) error {
+	if len(inputs) != len(p.inputs) {
+		return fmt.Errorf("Network has %d inputs but %d were given", len(p.inputs), len(inputs))
+	}
+	if len(outputs) != len(p.outputs) {
+		return fmt.Errorf("Network has %d outputs but room for %d was given", len(p.outputs), len(outputs))
+	}
+
+	// Load the sensors
+	for _, i := range p.bias {
+		p.values[i] = 1
+	}
+	for j, i := range p.inputs {
+		p.values[i] = inputs[j]
+	}
+
+	// Compute the nodes in order
+	for t, i := range p.targets {
+		sum := float64(0)
+		for c := p.starts[t]; c < p.starts[t+1]; c++ {
+			sum += p.values[p.srcs[c]] * p.weights[c]
+		}
+		if p.linear[t] {
+			p.values[i] = sum
+		} else {
+			p.values[i] = 1 / (1 + math.Exp(-sum))
+		}
+	}
+
+	for j, i := range p.outputs {
+		outputs[j] = p.values[i]
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package phenome
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/boggo/neat"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Integer-friendly approximation of the logistic function used by a
+// quantized network
+type Approximation int
+
+const (
+	// PLAN, the piecewise linear approximation of Amin, Curtis and
+	// Hayes-Gill, which needs only shifts and adds
+	PLAN Approximation = iota
+
+	// Hard sigmoid, 0.25x + 0.5 clamped to [0, 1]
+	HardSigmoid
+)
+
+// Returns the approximated logistic function of x
+func (a Approximation) apply(x float64) float64 {
+	if a == HardSigmoid {
+		return math.Max(0, math.Min(1, 0.25*x+0.5))
+	}
+	ax := math.Abs(x)
+	var y float64
+	switch {
+	case ax >= 5:
+		y = 1
+	case ax >= 2.375:
+		y = 0.03125*ax + 0.84375
+	case ax >= 1:
+		y = 0.125*ax + 0.625
+	default:
+		y = 0.25*ax + 0.5
+	}
+	if x < 0 {
+		y = 1 - y
+	}
+	return y
+}
+
+// A compiled network whose weights are fixed-point integers of a given
+// width, for running on hardware without floating point. The nodes to
+// compute are grouped into layers by their position, and the weights into
+// each layer share a scale: the weight is Weights[c] / Scales[Layers[t]].
+type QuantizedNetwork struct {
+	Bits    int           // Width of the weights, 8 or 16
+	Approx  Approximation // Approximation of the logistic function
+	Nodes   int           // Number of nodes
+	Bias    []int         // Indices of the bias nodes
+	Inputs  []int         // Indices of the input nodes
+	Outputs []int         // Indices of the output nodes
+	Targets []int         // Indices of the nodes to compute, in order
+	Linear  []bool        // Is the node to compute linear rather than logistic?
+	Layers  []int         // Layer of each node to compute
+	Scales  []float64     // Scale of each layer's weights
+	Starts  []int         // Incoming connections of target t are Starts[t] to Starts[t+1]
+	Srcs    []int         // Source node of each connection
+	Weights []int32       // Quantized weight of each connection
+
+	source *compiledPhenome // The network quantized
+	values []float64
+}
+
+// Quantizes the weights of a compiled phenome, such as one from
+// decoder.NewCompiled, to signed integers of 8 or 16 bits. Each layer's
+// weights are scaled so that the largest fills the range.
+func Quantize(p neat.Phenome, bits int, approx Approximation) (q *QuantizedNetwork, err error) {
+	cp, ok := p.(*compiledPhenome)
+	if !ok {
+		return nil, errors.New("Only compiled phenomes can be quantized")
+	}
+	if bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("Weights can be quantized to 8 or 16 bits, not %d", bits)
+	}
+	if approx != PLAN && approx != HardSigmoid {
+		return nil, fmt.Errorf("Unknown approximation %d", approx)
+	}
+	q = &QuantizedNetwork{Bits: bits, Approx: approx, Nodes: len(cp.values), Bias: cp.bias, Inputs: cp.inputs,
+		Outputs: cp.outputs, Targets: cp.targets, Linear: cp.linear, Starts: cp.starts, Srcs: cp.srcs,
+		Layers: make([]int, len(cp.targets)), Weights: make([]int32, len(cp.weights)),
+		source: cp, values: make([]float64, len(cp.values))}
+
+	// Group the targets into layers and find the largest weight of each
+	var peaks []float64
+	for t := range cp.targets {
+		if t == 0 || cp.layers[t] != cp.layers[t-1] {
+			peaks = append(peaks, 0)
+		}
+		l := len(peaks) - 1
+		q.Layers[t] = l
+		for c := cp.starts[t]; c < cp.starts[t+1]; c++ {
+			peaks[l] = math.Max(peaks[l], math.Abs(cp.weights[c]))
+		}
+	}
+
+	// Scale and round the weights
+	top := float64(int(1)<<uint(bits-1) - 1)
+	q.Scales = make([]float64, len(peaks))
+	for l, m := range peaks {
+		q.Scales[l] = 1
+		if m > 0 {
+			q.Scales[l] = top / m
+		}
+	}
+	for t := range cp.targets {
+		s := q.Scales[q.Layers[t]]
+		for c := cp.starts[t]; c < cp.starts[t+1]; c++ {
+			q.Weights[c] = int32(math.Max(-top, math.Min(top, math.Round(cp.weights[c]*s))))
+		}
+	}
+	return
+}
+
+// Analyzes the inputs with the quantized weights and the approximated
+// logistic function, keeping the node values in floating point
+func (q *QuantizedNetwork) Analyze(inputs []float64) (outputs []float64, err error) {
+	if len(inputs) != len(q.Inputs) {
+		return nil, fmt.Errorf("Network has %d inputs but %d were given", len(q.Inputs), len(inputs))
+	}
+	for _, i := range q.Bias {
+		q.values[i] = 1
+	}
+	for j, i := range q.Inputs {
+		q.values[i] = inputs[j]
+	}
+	for t, i := range q.Targets {
+		sum := float64(0)
+		for c := q.Starts[t]; c < q.Starts[t+1]; c++ {
+			sum += q.values[q.Srcs[c]] * float64(q.Weights[c])
+		}
+		sum /= q.Scales[q.Layers[t]]
+		if q.Linear[t] {
+			q.values[i] = sum
+		} else {
+			q.values[i] = q.Approx.apply(sum)
+		}
+	}
+	outputs = make([]float64, len(q.Outputs))
+	for j, i := range q.Outputs {
+		outputs[j] = q.values[i]
+	}
+	return
+}
+
+// Returns the largest difference between an output of the quantized network
+// and that of the network it was quantized from over the calibration inputs,
+// which are presented in order to both
+func (q *QuantizedNetwork) MaxError(calibration [][]float64) (worst float64, err error) {
+	for _, in := range calibration {
+		var want, got []float64
+		if want, err = q.source.Analyze(in); err != nil {
+			return
+		}
+		if got, err = q.Analyze(in); err != nil {
+			return
+		}
+		for j := range want {
+			worst = math.Max(worst, math.Abs(want[j]-got[j]))
+		}
+	}
+	return
+}
+
+// Writes the network as a C header defining constant arrays prefixed with
+// the given name
+func (q *QuantizedNetwork) WriteCHeader(w io.Writer, name string) (err error) {
+	var b bytes.Buffer
+	guard := strings.ToUpper(name) + "_H"
+	fmt.Fprintf(&b, "/* Quantized NEAT network: %d nodes, %d connections, %d-bit weights */\n", q.Nodes,
+		len(q.Weights), q.Bits)
+	fmt.Fprintf(&b, "#ifndef %s\n#define %s\n\n#include <stdint.h>\n\n", guard, guard)
+	fmt.Fprintf(&b, "#define %s_NODES %d\n#define %s_INPUTS %d\n#define %s_OUTPUTS %d\n#define %s_TARGETS %d\n",
+		strings.ToUpper(name), q.Nodes, strings.ToUpper(name), len(q.Inputs), strings.ToUpper(name), len(q.Outputs),
+		strings.ToUpper(name), len(q.Targets))
+	fmt.Fprintf(&b, "#define %s_APPROX %d /* 0 = PLAN, 1 = hard sigmoid */\n\n", strings.ToUpper(name), q.Approx)
+	ints := func(ctype, field string, xs []int) {
+		if len(xs) == 0 {
+			fmt.Fprintf(&b, "static const %s %s_%s[1] = {0}; /* Empty */\n", ctype, name, field)
+			return
+		}
+		fmt.Fprintf(&b, "static const %s %s_%s[] = {", ctype, name, field)
+		for i, x := range xs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%d", x)
+		}
+		b.WriteString("};\n")
+	}
+	ints("uint16_t", "bias", q.Bias)
+	ints("uint16_t", "inputs", q.Inputs)
+	ints("uint16_t", "outputs", q.Outputs)
+	ints("uint16_t", "targets", q.Targets)
+	linear := make([]int, len(q.Linear))
+	for i, l := range q.Linear {
+		if l {
+			linear[i] = 1
+		}
+	}
+	ints("uint8_t", "linear", linear)
+	ints("uint16_t", "layers", q.Layers)
+	ints("uint16_t", "starts", q.Starts)
+	ints("uint16_t", "srcs", q.Srcs)
+	weights := make([]int, len(q.Weights))
+	for i, x := range q.Weights {
+		weights[i] = int(x)
+	}
+	ints(fmt.Sprintf("int%d_t", q.Bits), "weights", weights)
+	fmt.Fprintf(&b, "static const float %s_scales[] = {", name)
+	for i, s := range q.Scales {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		f := strconv.FormatFloat(s, 'g', 9, 32)
+		if !strings.ContainsAny(f, ".e") {
+			f += ".0"
+		}
+		b.WriteString(f + "f")
+	}
+	fmt.Fprintf(&b, "};\n\n#endif /* %s */\n", guard)
+	_, err = w.Write(b.Bytes())
+	return
+}
+
+// Returns the network as a compact little-endian blob: the magic "NEATQ",
+// the weight width, the approximation, then the node count and each array
+// prefixed by its length, as uint16 apart from the weights, at their width,
+// and the scales, as float32
+func (q *QuantizedNetwork) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("NEATQ")
+	b.WriteByte(byte(q.Bits))
+	b.WriteByte(byte(q.Approx))
+	u16 := func(x int) error {
+		if x < 0 || x > math.MaxUint16 {
+			return fmt.Errorf("Value %d does not fit the blob", x)
+		}
+		return binary.Write(&b, binary.LittleEndian, uint16(x))
+	}
+	if err := u16(q.Nodes); err != nil {
+		return nil, err
+	}
+	linear := make([]int, len(q.Linear))
+	for i, l := range q.Linear {
+		if l {
+			linear[i] = 1
+		}
+	}
+	for _, xs := range [][]int{q.Bias, q.Inputs, q.Outputs, q.Targets, linear, q.Layers, q.Starts, q.Srcs} {
+		if err := u16(len(xs)); err != nil {
+			return nil, err
+		}
+		for _, x := range xs {
+			if err := u16(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := u16(len(q.Weights)); err != nil {
+		return nil, err
+	}
+	for _, x := range q.Weights {
+		if q.Bits == 8 {
+			b.WriteByte(byte(int8(x)))
+		} else {
+			binary.Write(&b, binary.LittleEndian, int16(x))
+		}
+	}
+	if err := u16(len(q.Scales)); err != nil {
+		return nil, err
+	}
+	for _, s := range q.Scales {
+		binary.Write(&b, binary.LittleEndian, float32(s))
+	}
+	return b.Bytes(), nil
+}
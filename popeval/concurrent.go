@@ -27,7 +27,9 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package popeval
 
 import (
+	"context"
 	"github.com/boggo/neat"
+	"runtime"
 	"sync"
 )
 
@@ -38,18 +40,79 @@ func NewConcurrent() *concurrentPopEval {
 type concurrentPopEval struct{}
 
 func (p concurrentPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err error) {
+	return p.EvaluateContext(context.Background(), pop, orgEval)
+}
+
+// Evaluates each organism in its own goroutine. Organisms whose goroutine
+// starts after the context is done are skipped, and the context is passed to
+// the organism evaluator when it implements neat.ContextOrgEval. The first
+// error encountered is returned. An evaluator implementing neat.BatchEval is
+// instead given one chunk of the population for each CPU.
+func (p concurrentPopEval) EvaluateContext(ctx context.Context, pop *neat.Population, orgEval neat.OrgEval) (err error) {
 
 	orgs := pop.Organisms()
+	if be, ok := orgEval.(neat.BatchEval); ok {
+		return evaluateChunks(ctx, be, orgs, runtime.NumCPU())
+	}
+	coe, useCtx := orgEval.(neat.ContextOrgEval)
 
 	var w sync.WaitGroup
+	var m sync.Mutex
 	w.Add(len(orgs))
 	for _, o := range orgs {
 		go func(o *neat.Organism) {
-			err = orgEval.Evaluate(o)
-			w.Done()
+			defer w.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			var e2 error
+			if useCtx {
+				e2 = coe.EvaluateContext(ctx, o)
+			} else {
+				e2 = orgEval.Evaluate(o)
+			}
+			if e2 != nil {
+				m.Lock()
+				if err == nil {
+					err = e2
+				}
+				m.Unlock()
+			}
 		}(o)
 	}
 	w.Wait()
 
 	return
 }
+
+// Evaluates the organisms in n batches, each in its own goroutine. The first
+// error encountered is returned.
+func evaluateChunks(ctx context.Context, be neat.BatchEval, orgs []*neat.Organism, n int) (err error) {
+
+	size := (len(orgs) + n - 1) / n
+	var w sync.WaitGroup
+	var m sync.Mutex
+	for start := 0; start < len(orgs); start += size {
+		end := start + size
+		if end > len(orgs) {
+			end = len(orgs)
+		}
+		w.Add(1)
+		go func(chunk []*neat.Organism) {
+			defer w.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			if e2 := neat.EvaluateBatch(be, chunk); e2 != nil {
+				m.Lock()
+				if err == nil {
+					err = e2
+				}
+				m.Unlock()
+			}
+		}(orgs[start:end])
+	}
+	w.Wait()
+
+	return
+}
@@ -27,6 +27,7 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package popeval
 
 import (
+	"context"
 	"github.com/boggo/neat"
 )
 
@@ -37,6 +38,18 @@ func NewSerial() *serialPopEval {
 type serialPopEval struct{}
 
 func (p serialPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err error) {
+	return p.EvaluateContext(context.Background(), pop, orgEval)
+}
+
+// Evaluates the organisms one after the other, stopping as soon as the
+// context is done. The first error encountered is returned. An evaluator
+// implementing neat.BatchEval is given the whole population at once.
+func (p serialPopEval) EvaluateContext(ctx context.Context, pop *neat.Population, orgEval neat.OrgEval) (err error) {
+
+	if be, ok := orgEval.(neat.BatchEval); ok {
+		return neat.EvaluateBatch(be, pop.Organisms())
+	}
+	coe, useCtx := orgEval.(neat.ContextOrgEval)
 
 	// Iterate the species within the population
 	for _, s := range pop.Species {
@@ -44,10 +57,19 @@ func (p serialPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err
 		// Iterate the organisms within the species
 		for _, o := range s.Orgs {
 
+			// Stop if the context is done
+			if err = ctx.Err(); err != nil {
+				return
+			}
+
 			// Evaluate the organism
-			err = orgEval.Evaluate(o)
+			if useCtx {
+				err = coe.EvaluateContext(ctx, o)
+			} else {
+				err = orgEval.Evaluate(o)
+			}
 			if err != nil {
-				// do what exactly?
+				return
 			}
 		}
 	}
@@ -27,25 +27,100 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package neat
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"sort"
+
+	"github.com/boggo/neural"
+)
+
+// Errors a roll gives up with. They are wrapped with the details; test for
+// them with errors.Is.
+var (
+	ErrEmptyPopulation = errors.New("Population has no organisms")
+	ErrNoViableSpecies = errors.New("No species is viable")
+	ErrSelectionFailed = errors.New("Selection failed to pick a parent")
 )
 
 type Population struct {
 	Generation int          // Current generation
 	Species    SpeciesSlice // The species which make up the population
+	counters   rollCounters // Tallies kept while this population was created
+
+	// Organisms the next roll breeds, as set by Resize. 0 = Settings.PopulationSize
+	Size int `json:",omitempty" xml:",omitempty"`
+
+	// Settings in effect when the population was archived, checked against
+	// those it resumes with
+	Settings *Settings `json:",omitempty" xml:",omitempty"`
+}
+
+// Tallies kept while rolling a population to the next generation
+type rollCounters struct {
+	capHits  int            // Mutations and matings limited by the genome size caps
+	mutation structMutation // Structural mutation selected for the latest child
+	births   []birth        // How each child was made
+}
+
+// Returns the number of organisms the next roll breeds
+func (pop *Population) size(settings *Settings) int {
+	if pop.Size > 0 {
+		return pop.Size
+	}
+	return settings.PopulationSize
 }
 
 func (pop Population) String() string {
 	return fmt.Sprintf("Population: Generation is %d with %d Species", pop.Generation, len(pop.Species))
 }
 
+// Writes a table describing each species to w. With verbose, each species is
+// followed by one line per organism.
+func (pop *Population) Report(w io.Writer, verbose bool) (err error) {
+	p := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+
+	p("Generation %d, %d species, %d organisms\n", pop.Generation, len(pop.Species), len(pop.Organisms()))
+	p("%7s %5s %5s %5s %10s %10s %5s %6s %9s\n", "Species", "Born", "Age", "Size", "Best", "Best ever", "Stagn",
+		"Cmplx", "Offspring")
+	p("%7s %5s %5s %5s %10s %10s %5s %6s %9s\n", "-------", "-----", "-----", "-----", "----------", "----------",
+		"-----", "------", "---------")
+	for _, s := range pop.Species {
+		best := "-"
+		if c := s.champion(); c != nil {
+			best = fmt.Sprintf("%10.4f", c.Fitness[0])
+		}
+		cmplx := 0
+		if s.Example != nil {
+			cmplx = len(s.Example.Nodes) + len(s.Example.Conns)
+		}
+		p("%7d %5d %5d %5d %10s %10.4f %5d %6d %9d\n", s.ID, s.CreatedAt, s.Age, len(s.Orgs), best, s.BestFitness,
+			s.Stagnation(), cmplx, s.Offspring)
+
+		if verbose {
+			for _, o := range s.Orgs {
+				fit := "-"
+				if len(o.Fitness) > 0 {
+					fit = fmt.Sprintf("%10.4f", o.Fitness[0])
+				}
+				p("        organism %6d fitness %10s nodes %4d conns %4d\n", o.ID, fit, len(o.Nodes), len(o.Conns))
+			}
+		}
+	}
+	return
+}
+
 // Creates the initial population from the settings by cloning the initial genome
 func initialPopulation(settings *Settings, inno *innovation) (pop *Population, err error) {
 
 	// The initial population has only one species
 	pop = &Population{Generation: 1, Species: make([]*Species, 1, 10)}
-	pop.Species[0] = &Species{ID: inno.nextID()}
+	pop.Species[0] = &Species{ID: inno.nextID(), CreatedAt: pop.Generation}
 	pop.Species[0].Orgs = make([]*Organism, settings.PopulationSize)
 
 	// Fill the species with copies of the initial genome
@@ -54,163 +129,721 @@ func initialPopulation(settings *Settings, inno *innovation) (pop *Population, e
 		err = e2
 		return
 	}
-	for i := 0; i < settings.PopulationSize; i++ {
+	copy(pop.Species[0].Orgs, seedOrganisms(settings, inno, ig, settings.PopulationSize, pop.Generation))
+
+	return
+}
+
+// Returns n clones of the initial genome, each with its own randomly drawn
+// weights, born in the given generation
+func seedOrganisms(settings *Settings, inno *innovation, ig *Genome, n, generation int) OrganismSlice {
+	fanIn := make(map[int]int, len(ig.Nodes))
+	for _, cg := range ig.Conns {
+		fanIn[cg.Target] += 1
+	}
+	orgs := make(OrganismSlice, n)
+	for i := range orgs {
 		g := cloneGenome(ig, inno.nextID())
-		for _, cg := range g.Conns {
-			cg.Weight = random.Gaussian()
+		for _, k := range g.Conns.sortedMarkers() {
+			cg := g.Conns[k]
+			cg.Weight = clampWeight(settings, settings.initialWeight(fanIn[cg.Target]))
 		}
-		pop.Species[0].Orgs[i] = &Organism{Genome: g}
+		orgs[i] = &Organism{Genome: g, Birth: generation}
 	}
-
-	return
+	return orgs
 }
 
 // Rolls a population to the next generation
 func rollPop(settings *Settings, inno *innovation, population *Population) (nextPop *Population, err error) {
+	var children OrganismSlice
+	children, nextPop, err = Reproduce(settings, inno, population)
+	if err != nil {
+		return
+	}
+	SpeciateInto(settings, inno, nextPop, children)
+	return
+}
 
-	// Construct the next population
-	currPop := population
-	nextPop = &Population{Generation: currPop.Generation + 1,
-		Species: make([]*Species, 0, len(currPop.Species))}
+// Breeds the children of the next generation, the first of the two phases of
+// a roll, with Settings.Reproduction or, by default, the NEAT scheme. The
+// next population holds the surviving species, still without any organisms,
+// ready for the children to be speciated into by SpeciateInto. Between the
+// phases the children may be decoded and evaluated.
+func Reproduce(settings *Settings, inno *innovation, population *Population) (children OrganismSlice, nextPop *Population, err error) {
 
 	// Update the species fitness in the current population
+	for _, s := range population.Species {
+		for _, o := range s.Orgs {
+			if err = checkFitness(settings, o); err != nil {
+				return
+			}
+		}
+		s.calcFitness(settings)
+	}
+
+	// Carry the species forward and breed the children
+	nextPop = &Population{Generation: population.Generation + 1, Size: population.Size,
+		Species: make([]*Species, 0, len(population.Species))}
+	for _, s := range population.Species {
+		if len(s.Orgs) > 0 {
+			nextPop.Species = append(nextPop.Species, s.carry())
+		}
+	}
+	if !settings.PersistentInnovations {
+		inno.reset()
+	}
+	rc := &ReproductionContext{Settings: settings, Innovation: inno, Random: settings.rand(),
+		Current: population, Next: nextPop, Size: population.size(settings)}
+	var rep Reproduction = NEATReproduction{}
+	if settings.Reproduction != nil {
+		rep = settings.Reproduction
+	}
+	children, err = rep.Reproduce(rc)
+	return
+}
+
+// The NEAT scheme of reproduction. Species which have stagnated, other than
+// that holding the best organism, are culled and the others are cut to
+// their survivors. The offspring are shared among the species by their
+// adjusted fitness and each species keeps its elite and breeds the rest of
+// its share, the population being filled out with children of parents from
+// any species.
+type NEATReproduction struct{}
+
+func (NEATReproduction) Reproduce(rc *ReproductionContext) (children OrganismSlice, err error) {
+	settings, inno, currPop, nextPop, size := rc.Settings, rc.Innovation, rc.Current, rc.Next, rc.Size
+	nextPop.Species = nextPop.Species[:0]
+
+	// Find the best species
 	var bestSpecies *Species
-	//var bestOrg *Organism
 	var bestFit float64
 	for _, s := range currPop.Species {
-		s.calcFitness()
+		if f, ok := s.Orgs.MaxFitness(); ok && (bestSpecies == nil || f > bestFit) {
+			bestFit = f
+			bestSpecies = s
+		}
+	}
+	if bestSpecies == nil {
+		err = fmt.Errorf("Cannot roll generation %d: %w", currPop.Generation, ErrEmptyPopulation)
+		return
+	}
+	var parents map[uint64]bool // Genomes of this generation, for rejecting duplicates
+	if settings.RejectDuplicates {
+		parents = currPop.hashes()
+	}
+
+	// Pick the global elite, the best organisms of the whole population,
+	// noting how many each species holds
+	var globalElite OrganismSlice
+	if settings.GlobalEliteCount > 0 {
+		globalElite = currPop.Organisms()
+		sort.Stable(sort.Reverse(globalElite))
+		if len(globalElite) > settings.GlobalEliteCount {
+			globalElite = globalElite[:settings.GlobalEliteCount]
+		}
+	}
+	orgSpecies := make(map[*Organism]int, size)
+	for _, s := range currPop.Species {
 		for _, o := range s.Orgs {
-			if o.Fitness[0] > bestFit {
-				//bestOrg = o
-				bestFit = o.Fitness[0]
-				bestSpecies = s
+			orgSpecies[o] = s.ID
+		}
+	}
+	globalShare := make(map[int]int, len(globalElite))
+	for _, s := range currPop.Species {
+		for _, o := range globalElite {
+			if s.Orgs.contains(o) {
+				globalShare[s.ID] += 1
 			}
 		}
 	}
 
 	// Allow viable species to continue to live but cull their numbers
 	adjFit := float64(0)
-	popFit := float64(0)
 	var living SpeciesSlice
 	living = make([]*Species, 0, len(currPop.Species))
+	elites := make(map[int]int, len(currPop.Species))
 	for _, s := range currPop.Species {
-		if s.ID == bestSpecies.ID || s.Age-s.BestFitAge < settings.AgeToStagnation {
+		if len(s.Orgs) == 0 {
+			continue // Nothing left to breed from
+		}
+		if s.ID == bestSpecies.ID || !s.stagnant(settings) {
 			living = append(living, s)
 			adjFit += s.currFitness
-			sort.Sort(sort.Reverse(s.Orgs))
-			keep := int(settings.SurvivalPercent * float64(len(s.Orgs)))
-			if keep < settings.EliteCount {
-				keep = settings.EliteCount
-			}
-			if keep > len(s.Orgs) {
-				keep = len(s.Orgs)
+			sort.Stable(sort.Reverse(s.Orgs))
+			elites[s.ID] = settings.eliteCount(len(s.Orgs))
+			keep := len(s.Orgs) // Already culled by the pre-evaluation
+			if settings.PreEval == nil {
+				keep = settings.survivors(len(s.Orgs), elites[s.ID])
 			}
 			s.Orgs = s.Orgs[:keep]
-			popFit += s.Orgs.TotalFitness()
-			s.Example = s.Orgs[random.Int(keep)]
+			s.Example = s.Orgs[settings.rand().Int(keep)]
+			setSelectionFitness(settings, s.Orgs)
+		} else {
+			settings.log().Info("species culled for stagnation", "species", s.ID, "age", s.Age,
+				"stagnation", s.Age-s.BestFitAge, "size", len(s.Orgs))
 		}
 	}
 	//sort.Sort(sort.Reverse(living)) // Reverse sort by best fitness
+	if len(living) == 0 {
+		err = fmt.Errorf("Cannot roll generation %d: %w", currPop.Generation, ErrNoViableSpecies)
+		return
+	}
 	popOrgs := living.Organisms(settings)
 
 	// Create the next generation
-	inno.reset()
-	children := make([]*Organism, 0, settings.PopulationSize) // TODO: Make this a channel for concurrency support
-	for _, currS := range living {
+	var sel Selector
+	sel, err = newSelector(settings, currPop.Generation)
+	if err != nil {
+		return
+	}
+	children = make([]*Organism, 0, size) // TODO: Make this a channel for concurrency support
+	counters := &nextPop.counters
+	counters.births = make([]birth, 0, size)
+	for _, o := range globalElite {
+		o.Age += 1
+		children = append(children, o)
+		counters.born(orgSpecies[o], bornElite)
+	}
+	shares := apportion(settings, size, living, adjFit)
+	broods := make([]speciesBrood, len(living))
+	for j, currS := range living {
 
 		// Copy the species to the next generation
-		cnt := int(currS.currFitness / adjFit * float64(settings.PopulationSize))
-		nextS := &Species{ID: currS.ID, Orgs: make([]*Organism, 0, cnt), Age: currS.Age + 1,
-			BestFitness: currS.BestFitness, BestFitAge: currS.BestFitAge, Example: currS.Example}
+		cnt := shares[j]
+		if cnt <= 0 {
+			settings.log().Debug("species given no offspring", "species", currS.ID, "fitness", currS.currFitness)
+		}
+		nextS := currS.carry()
+		nextS.Orgs, nextS.Offspring = make([]*Organism, 0, cnt), cnt
 		nextPop.Species = append(nextPop.Species, nextS)
 
-		// Add the elite
-		for i := 0; i < settings.EliteCount && i < len(currS.Orgs); i++ {
-			children = append(children, currS.Orgs[i])
+		// Pick the elite, counting any of the global elite against the
+		// species' share
+		cnt -= globalShare[currS.ID]
+		for i := 0; i < elites[currS.ID] && i < len(currS.Orgs); i++ {
+			currS.Orgs[i].Age += 1
+			broods[j].elites = append(broods[j].elites, currS.Orgs[i])
 			cnt -= 1
 		}
+		if cnt < 0 {
+			cnt = 0 // The elite alone overfill the share
+		}
+		broods[j].cnt = cnt
+	}
 
-		// Create the offspring
-		orgFit := currS.Orgs.TotalFitness()
-		for i := 0; i < cnt; i++ {
+	// Create the offspring, adding each species' brood after its elite
+	if settings.ReproductionWorkers > 0 {
+		err = breedParallel(settings, inno, currPop.Generation, living, popOrgs, parents, broods)
+	} else {
+		for j, currS := range living {
+			broods[j].orgs, err = breed(settings, inno, sel, currS, popOrgs, parents, broods[j].cnt, &broods[j].counters)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	for j, currS := range living {
+		for _, o := range broods[j].elites {
+			children = append(children, o)
+			counters.born(currS.ID, bornElite)
+		}
+		children = append(children, broods[j].orgs...)
+		counters.merge(&broods[j].counters)
+	}
 
-			// Allow for innerspecies mating. This is done simply by skipping
-			// over this request for an offspring and letting the section
-			// below, "Ensure we have the right number of children", create
-			// the (potentionally) interspecies child
-			if random.Float64() < settings.InterspeciesMating {
-				continue
+	// Ensure we have the right number of children
+	if len(children) > size {
+		settings.log().Debug("children truncated to the population size", "children", len(children),
+			"size", size)
+		children = children[:size]
+		counters.births = counters.births[:size]
+	} else {
+		cnt := size - len(children)
+		if cnt > 0 {
+			settings.log().Debug("filling out the children with interspecies offspring", "count", cnt)
+		}
+		var seen map[uint64]bool
+		if settings.RejectDuplicates {
+			seen = make(map[uint64]bool, size)
+			for _, o := range children {
+				seen[o.Hash()] = true
+			}
+		}
+		for c := 0; c < cnt; c++ {
+			p1 := sel.Select(popOrgs)
+			if p1 == nil {
+				err = fmt.Errorf("Filling out generation %d: %w", nextPop.Generation, ErrSelectionFailed)
+				return
+			}
+			var p2 *Organism
+			if settings.crosses(settings.rand(), len(popOrgs)) {
+				p2 = findMate(settings, sel, popOrgs, p1)
 			}
+			child := offspring(settings, inno, p1, p2, counters)
+			rejectDuplicate(settings, inno, child, parents, seen, counters)
+			children = append(children, child)
+			counters.born(orgSpecies[p1], bornFiller)
+		}
+	}
+	return
+}
 
-			// Select parent 1
-			p1 := tournament(currS.Orgs, orgFit)
+// The elite and offspring of one species in the next generation
+type speciesBrood struct {
+	elites   OrganismSlice // Carried over unchanged
+	cnt      int           // Offspring to breed
+	orgs     OrganismSlice // The offspring
+	counters rollCounters  // Tallies kept while breeding
+}
 
-			// Mutate only
-			if len(currS.Orgs) == 1 || random.Next() > settings.Crossover {
-				child := cloneOrg(p1, inno.nextID())
-				mutate(settings, inno, child)
-				children = append(children, child)
-			} else {
+// Breeds up to cnt offspring of the species, taking some mates from the
+// pool of the whole population. With RejectDuplicates, offspring may not
+// duplicate the parents' generation, given by its hashes, or each other.
+func breed(settings *Settings, inno *innovation, sel Selector, s *Species, pool OrganismSlice,
+	parents map[uint64]bool, cnt int, counters *rollCounters) (brood OrganismSlice, err error) {
+	r := settings.rand()
+	brood = make(OrganismSlice, 0, cnt)
+	var seen map[uint64]bool
+	if settings.RejectDuplicates {
+		seen = make(map[uint64]bool, cnt)
+	}
+	for i := 0; i < cnt; i++ {
+
+		// Allow for innerspecies mating. This is done simply by skipping
+		// over this request for an offspring and letting the section
+		// below, "Ensure we have the right number of children", create
+		// the (potentionally) interspecies child
+		if r.Float64() < settings.InterspeciesMating {
+			continue
+		}
 
-				// Pick a mate
-				var p2 *Organism
-				if random.Next() < settings.InterspeciesMating {
-					p2 = tournament(popOrgs, popFit)
-				} else {
-					p2 = tournament(currS.Orgs, orgFit)
-				}
+		// Select parent 1
+		p1 := sel.Select(s.Orgs)
+		if p1 == nil {
+			err = fmt.Errorf("Breeding species %d: %w", s.ID, ErrSelectionFailed)
+			return
+		}
 
-				// Crossover and mutate
-				child := crossover(inno, p1, p2)
-				mutate(settings, inno, child)
-				children = append(children, child)
+		// Pick a mate unless mutating only
+		var p2 *Organism
+		inter := false
+		if settings.crosses(r, len(s.Orgs)) {
+			if r.Next() < settings.InterspeciesMating {
+				p2 = findMate(settings, sel, pool, p1)
+				inter = true
+			} else {
+				p2 = findMate(settings, sel, s.Orgs, p1)
 			}
 		}
+		child := offspring(settings, inno, p1, p2, counters)
+		rejectDuplicate(settings, inno, child, parents, seen, counters)
+		brood = append(brood, child)
+		counters.bred(s.ID, child, inter)
+	}
+	return
+}
 
-		// Ensure we have the right number of children
-		if len(children) > settings.PopulationSize {
-			children = children[:settings.PopulationSize]
-		} else {
-			cnt = settings.PopulationSize - len(children)
-			for c := 0; c < cnt; c++ {
-				p1 := tournament(popOrgs, popFit)
-				p2 := tournament(popOrgs, popFit)
-				child := crossover(inno, p1, p2)
-				mutate(settings, inno, child)
-				children = append(children, child)
+// Culls each species to its survivors as ranked by the pre-evaluation,
+// clearing their provisional fitness ready for the full evaluation
+func (pop *Population) cullProvisional(settings *Settings) (err error) {
+	for _, s := range pop.Species {
+		for _, o := range s.Orgs {
+			if err = checkFitness(settings, o); err != nil {
+				return
 			}
 		}
+		sort.Stable(sort.Reverse(s.Orgs))
+		keep := settings.survivors(len(s.Orgs), settings.eliteCount(len(s.Orgs)))
+		s.Orgs = s.Orgs[:keep]
+		for _, o := range s.Orgs {
+			o.Fitness = nil
+		}
+	}
+	return
+}
+
+// Shares the size offspring of the next generation among the living species in
+// proportion to their fitness, or equally when their total fitness is not
+// positive. Each species is guaranteed MinSpeciesSize offspring, the
+// shortfall being taken from the larger shares in proportion to how far they
+// exceed the minimum.
+func apportion(settings *Settings, size int, living SpeciesSlice, adjFit float64) (shares []int) {
+	shares = make([]int, len(living))
+	for i, s := range living {
+		if adjFit > 0 && !math.IsInf(adjFit, 0) {
+			shares[i] = int(s.currFitness / adjFit * float64(size))
+		} else {
+			shares[i] = size / len(living)
+		}
+	}
+	min := settings.MinSpeciesSize
+	if min <= 0 {
+		return
+	}
+
+	// Raise the small shares to the minimum
+	deficit, surplus := 0, 0
+	for i, n := range shares {
+		if n < min {
+			deficit += min - n
+			shares[i] = min
+		} else {
+			surplus += n - min
+		}
+	}
+	if deficit == 0 || surplus == 0 {
+		return
+	}
+	if deficit > surplus {
+		deficit = surplus
+	}
 
+	// Take the shortfall from the larger shares, proportionally and then one
+	// at a time for what rounding leaves
+	taken := 0
+	for i, n := range shares {
+		if n > min {
+			t := deficit * (n - min) / surplus
+			shares[i] -= t
+			taken += t
+		}
+	}
+	for i := 0; taken < deficit; i = (i + 1) % len(shares) {
+		if shares[i] > min {
+			shares[i] -= 1
+			taken += 1
+		}
 	}
+	return
+}
+
+// Speciates the children into the next population and prunes off the
+// species which are left empty, the second of the two phases of a roll
+func SpeciateInto(settings *Settings, inno *innovation, nextPop *Population, children OrganismSlice) {
 
 	// Speciate the children
 	speciate(settings, inno, nextPop, children)
+	if settings.SpeciesThresholds && !settings.DisableSpeciation {
+		adaptThresholds(settings, inno, nextPop)
+	}
 
 	// Prune off species which are empty
-	living = make([]*Species, 0, len(living))
+	living := make([]*Species, 0, len(nextPop.Species))
 	for _, s := range nextPop.Species {
 		if len(s.Orgs) > 0 {
 			living = append(living, s)
 		}
 	}
 	nextPop.Species = living
+}
 
-	// Replace the current population with the next one
+// Changes the size of the population from the next roll on, which breeds
+// newSize children whatever Settings.PopulationSize says. The size is held
+// in Population.Size and passed on to the following generations, so it is
+// archived with them. Shrinking also cuts each species now, in proportion to
+// its size, to its fittest organisms, so that the elites are kept. Growing
+// an evaluated population waits for the roll, but one with no organism yet
+// evaluated, such as the initial population, is filled out at once with
+// clones of its members given newly drawn weights and IDs from inno. With a
+// nil inno such a population too waits for the roll. Every species keeps at
+// least MinSpeciesSize organisms, or one, and a size too small to give each
+// that many is an error, as is shrinking a population partly evaluated.
+// Resize may be called between generations, as from the OnGenerationEnd
+// hook, but not under ALPS, whose layers each hold PopulationSize.
+func (pop *Population) Resize(newSize int, settings *Settings, inno *innovation) (err error) {
+	if newSize < 1 {
+		return fmt.Errorf("Cannot resize to %d organisms", newSize)
+	}
+	min := settings.MinSpeciesSize
+	if min < 1 {
+		min = 1
+	}
+	living := 0
+	for _, s := range pop.Species {
+		if len(s.Orgs) > 0 {
+			living += 1
+		}
+	}
+	if newSize < living*min {
+		return fmt.Errorf("Cannot resize to %d organisms, the %d species need at least %d", newSize,
+			living, living*min)
+	}
+	total := len(pop.Organisms())
+	defer func() {
+		if err == nil {
+			pop.Size = newSize
+			settings.log().Info("population resized", "generation", pop.Generation, "from", total, "to", newSize)
+		}
+	}()
+	if newSize >= total {
+		if inno != nil && total > 0 && !pop.anyEvaluated() {
+			pop.grow(settings, inno, newSize-total, total)
+		}
+		return
+	}
+	if !pop.evaluated() {
+		return errors.New("Cannot shrink a population which is not yet evaluated")
+	}
+
+	// Give each species its floor and the rest in proportion to what it has
+	// beyond that
+	shares := make([]int, len(pop.Species))
+	floor, room := 0, 0
+	for i, s := range pop.Species {
+		shares[i] = len(s.Orgs)
+		if shares[i] > min {
+			shares[i] = min
+		}
+		floor += shares[i]
+		room += len(s.Orgs) - shares[i]
+	}
+	rest, given := newSize-floor, 0
+	for i, s := range pop.Species {
+		if room > 0 {
+			n := rest * (len(s.Orgs) - shares[i]) / room
+			shares[i] += n
+			given += n
+		}
+	}
+	for i := 0; given < rest; i = (i + 1) % len(shares) {
+		if shares[i] < len(pop.Species[i].Orgs) {
+			shares[i] += 1
+			given += 1
+		}
+	}
+
+	// Keep the fittest of each species
+	for i, s := range pop.Species {
+		sort.Stable(sort.Reverse(s.Orgs))
+		s.Orgs = s.Orgs[:shares[i]]
+		if len(s.Orgs) > 0 && s.Example != nil && !s.Orgs.contains(s.Example) {
+			s.Example = s.Orgs[0]
+		}
+	}
+	return
+}
+
+// Has any organism in the population been given a fitness?
+func (pop *Population) anyEvaluated() bool {
+	for _, s := range pop.Species {
+		for _, o := range s.Orgs {
+			if len(o.Fitness) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Adds n clones of the population's total organisms, sharing them among the
+// species in proportion to their size
+func (pop *Population) grow(settings *Settings, inno *innovation, n, total int) {
+	shares := make([]int, len(pop.Species))
+	given := 0
+	for i, s := range pop.Species {
+		shares[i] = n * len(s.Orgs) / total
+		given += shares[i]
+	}
+	for i := 0; given < n; i = (i + 1) % len(shares) {
+		if len(pop.Species[i].Orgs) > 0 {
+			shares[i] += 1
+			given += 1
+		}
+	}
+	for i, s := range pop.Species {
+		members := len(s.Orgs)
+		for j := 0; j < shares[i]; j++ {
+			s.Orgs = append(s.Orgs, seedOrganisms(settings, inno, s.Orgs[j%members].Genome, 1, pop.Generation)...)
+		}
+	}
+}
+
+// Kills all but the keep best species, ranked by their best organism. The
+// species holding the champion always survives. The survivors' stagnation
+// counters are reset and, as only they remain, the next roll shares the whole
+// population among them. The innovation history is left alone, so that
+// survivors bred before the next roll's reset get the markers already issued
+// for their structures. Subscribers to the OnExtinction hook are told which
+// species survived and which were killed.
+func (pop *Population) Extinction(keep int, settings *Settings, inno *innovation) (err error) {
+
+	if keep < 1 {
+		err = errors.New("Extinction must keep at least one species")
+		return
+	}
+	if keep >= len(pop.Species) {
+		return // Nothing to kill
+	}
+
+	// Rank the species by their best organism with the champion's species first
+	champ := pop.Champion()
+	best := make(map[int]float64, len(pop.Species))
+	ranked := make([]*Species, len(pop.Species))
+	for i, s := range pop.Species {
+		ranked[i] = s
+		for _, o := range s.Orgs {
+			if len(o.Fitness) == 0 {
+				continue
+			}
+			if b, ok := best[s.ID]; !ok || o.Fitness[0] > b {
+				best[s.ID] = o.Fitness[0]
+			}
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if champ != nil && a.Orgs.contains(champ) != b.Orgs.contains(champ) {
+			return a.Orgs.contains(champ)
+		}
+		return best[a.ID] > best[b.ID]
+	})
+
+	// Kill the rest, keeping the survivors in their original order
+	alive := make(map[int]bool, keep)
+	for _, s := range ranked[:keep] {
+		alive[s.ID] = true
+	}
+	survivors := make([]*Species, 0, keep)
+	killed := make([]*Species, 0, len(pop.Species)-keep)
+	for _, s := range pop.Species {
+		if alive[s.ID] {
+			s.BestFitAge = s.Age // Restart the stagnation clock
+			survivors = append(survivors, s)
+		} else {
+			killed = append(killed, s)
+		}
+	}
+	pop.Species = survivors
+	for _, s := range killed {
+		settings.log().Info("species killed by extinction", "species", s.ID, "size", len(s.Orgs))
+	}
+
+	// Let the hooks know
+	if settings != nil && settings.Hooks.OnExtinction != nil {
+		settings.Hooks.OnExtinction(pop, survivors, killed)
+	}
+	return
+}
+
+// How BreedWith decides whether to cross the parents
+type BreedMode int
+
+const (
+	BreedBySettings   BreedMode = iota // Cross with probability Settings.Crossover, as a roll does
+	BreedCrossover                     // Always cross the parents
+	BreedMutationOnly                  // Mutate a copy of the first parent alone
+)
+
+// Breeds one child of the chosen parents by the path a roll takes, crossing
+// them with probability Settings.Crossover and mutating the child. The child
+// is given a new ID and its lineage is recorded. A nil second parent, or the
+// first again, gives a mutated copy of the first.
+func Breed(settings *Settings, inno *innovation, p1, p2 *Organism) (*Organism, error) {
+	return BreedWith(settings, inno, p1, p2, BreedBySettings)
+}
+
+// Breeds one child of the chosen parents as Breed does, the mode forcing or
+// forgoing the crossover. The parents must have the bias, input and output
+// nodes the settings describe.
+func BreedWith(settings *Settings, inno *innovation, p1, p2 *Organism, mode BreedMode) (child *Organism, err error) {
+	if err = settings.Validate(); err != nil {
+		return
+	}
+	if p1 == nil {
+		err = errors.New("Breed needs a first parent")
+		return
+	}
+	for _, p := range []*Organism{p1, p2} {
+		if p != nil {
+			if err = checkSensors(settings, p); err != nil {
+				return
+			}
+		}
+	}
+	mates := 1
+	if p2 != nil && p2 != p1 {
+		mates = 2
+	}
+	switch mode {
+	case BreedBySettings:
+		if !settings.crosses(settings.rand(), mates) {
+			p2 = nil
+		}
+	case BreedCrossover:
+	case BreedMutationOnly:
+		p2 = nil
+	default:
+		err = fmt.Errorf("Unknown BreedMode %d", mode)
+		return
+	}
+	child = offspring(settings, inno, p1, p2, &rollCounters{})
 	return
+}
 
+// Returns an error if the organism does not have the bias, input and output
+// nodes the settings describe
+func checkSensors(settings *Settings, org *Organism) error {
+	b, i, o := len(org.nodesOfType(neural.BIAS)), len(org.nodesOfType(neural.INPUT)), len(org.nodesOfType(neural.OUTPUT))
+	if b != settings.BiasCount || i != settings.InputCount || o != settings.OutputCount {
+		return fmt.Errorf("Organism %d has %d bias, %d input and %d output nodes but the settings call for %d, %d and %d",
+			org.ID, b, i, o, settings.BiasCount, settings.InputCount, settings.OutputCount)
+	}
+	return nil
 }
 
-func tournament(orgs []*Organism, totFit float64) (champ *Organism) {
-	tgt := random.Next() * totFit
+// Creates a mutated child of the parents, or of the first parent alone if
+// the second is nil or the first again
+func offspring(settings *Settings, inno *innovation, p1, p2 *Organism, counters *rollCounters) (child *Organism) {
+	if p2 == nil || p2 == p1 {
+		child = cloneOrg(p1, inno.nextID())
+		inheritMeta(settings, child, p1)
+		setLineage(settings, child, p1, nil)
+	} else {
+		child = mate(settings, inno, p1, p2, counters)
+	}
+	mutate(settings, inno, child, counters)
+	return
+}
+
+// Crosses the parents, retrying a few times if the child would exceed the
+// genome size caps. If it keeps doing so the fitter parent is cloned instead,
+// which always respects the caps as the parent did.
+func mate(settings *Settings, inno *innovation, p1, p2 *Organism, counters *rollCounters) (child *Organism) {
+	for i := 0; i < 3; i++ {
+		child = crossover(settings, inno, p1, p2)
+		if !settings.overCap(child.Genome) {
+			return
+		}
+		counters.capHits += 1
+	}
+	if compareFitness(p1.Fitness, p2.Fitness) < 0 {
+		p1 = p2
+	}
+	child = cloneOrg(p1, inno.nextID())
+	inheritMeta(settings, child, p1)
+	setLineage(settings, child, p1, nil)
+	return
+}
+
+// Spins the roulette wheel, returning nil if there is nothing to pick from
+// or the selection fitness is not a number
+func tournament(r *rng, orgs []*Organism, totFit float64) (champ *Organism) {
+	if len(orgs) == 0 || math.IsNaN(totFit) {
+		return
+	}
+	tgt := r.Next() * totFit
 	sum := float64(0)
 	for _, o := range orgs {
-		sum += o.Fitness[0]
+		sum += o.selFit
 		if sum >= tgt {
 			champ = o
 			return
 		}
 	}
-	return // Should be an error to get here
+	return orgs[len(orgs)-1] // Rounding left the sum just short of the target
 }
 
 func speciate(settings *Settings, inno *innovation, pop *Population, children OrganismSlice) {
@@ -218,28 +851,96 @@ func speciate(settings *Settings, inno *innovation, pop *Population, children Or
 	// Iterate the children
 	for _, child := range children {
 
+		// Keep everyone in one species if speciation is disabled
+		if settings.DisableSpeciation && len(pop.Species) > 0 {
+			pop.Species[0].Orgs = append(pop.Species[0].Orgs, child)
+			continue
+		}
+
 		// Iterate the species
 		found := false
+		nearest := math.Inf(1)
 		for _, s := range pop.Species {
-			d := distance(settings, child, s.Example)
-			if d < settings.CompatThreshold {
+			d := compatDistance(settings, child, s.Example)
+			if d < s.threshold(settings) {
 				s.Orgs = append(s.Orgs, child)
 				found = true
 				break
 			}
+			nearest = math.Min(nearest, d)
 		}
 
-		// No species found, add a new one
+		// No species found, add a new one. Its ID comes from the same rising
+		// sequence as the organisms' so a dead species' ID is never reissued.
 		if !found {
-			newS := &Species{ID: inno.nextID(), Orgs: make([]*Organism, 0, 10)}
+			newS := &Species{ID: inno.nextID(), Orgs: make([]*Organism, 0, 10), CreatedAt: pop.Generation}
+			if settings.SpeciesThresholds {
+				newS.Threshold = settings.CompatThreshold
+			}
 			pop.Species = append(pop.Species, newS)
 
 			newS.Orgs = append(newS.Orgs, child)
 			newS.Example = child
+			settings.log().Debug("new species created", "species", newS.ID, "organism", child.ID,
+				"distance", nearest)
 		}
 	}
 }
 
+// Adjusts the species' own thresholds to their sizes. An oversized species
+// has its threshold tightened and its members farther than that from its
+// example spun off into a new species, seeded by the farthest of them, which
+// begins at the tightened threshold. A species under half the maximum size
+// has its threshold relaxed.
+func adaptThresholds(settings *Settings, inno *innovation, pop *Population) {
+	step := settings.ThresholdStep
+	if step == 0 {
+		step = settings.CompatThreshold / 10
+	}
+	for _, s := range pop.Species {
+		t := s.threshold(settings)
+		switch {
+		case len(s.Orgs) > settings.MaxSpeciesSize:
+			s.Threshold = math.Max(t-step, settings.MinCompatThreshold)
+		case len(s.Orgs) < settings.MaxSpeciesSize/2:
+			s.Threshold = math.Min(t+step, settings.MaxCompatThreshold)
+			continue
+		default:
+			s.Threshold = t
+			continue
+		}
+
+		// Split off the members beyond the tightened threshold
+		var kept, split OrganismSlice
+		var seed *Organism
+		far := math.Inf(-1)
+		for _, o := range s.Orgs {
+			if o == s.Example {
+				kept = append(kept, o)
+				continue
+			}
+			d := compatDistance(settings, o, s.Example)
+			if d < s.Threshold {
+				kept = append(kept, o)
+				continue
+			}
+			split = append(split, o)
+			if d > far {
+				seed, far = o, d
+			}
+		}
+		if len(split) == 0 || len(kept) == 0 {
+			continue
+		}
+		s.Orgs = kept
+		newS := &Species{ID: inno.nextID(), Orgs: split, CreatedAt: pop.Generation, Example: seed,
+			Threshold: s.Threshold}
+		pop.Species = append(pop.Species, newS)
+		settings.log().Debug("species split", "species", s.ID, "new", newS.ID, "size", len(split),
+			"threshold", s.Threshold)
+	}
+}
+
 func (pop *Population) Organisms() OrganismSlice {
 	n := 0
 	for _, s := range pop.Species {
@@ -256,6 +957,29 @@ func (pop *Population) Organisms() OrganismSlice {
 	return orgs
 }
 
+// Returns true if the next roll will cull the species for stagnation. The
+// species holding the population's champion is always spared.
+func (pop *Population) WillCull(settings *Settings, s *Species) bool {
+	if champ := pop.Champion(); champ != nil && s.Orgs.contains(champ) {
+		return false
+	}
+	return s.WillStagnate(settings)
+}
+
+// Returns the organisms for which the predicate is true, in the order of
+// Organisms
+func (pop *Population) OrganismsWhere(pred func(*Organism) bool) OrganismSlice {
+	var orgs OrganismSlice
+	for _, s := range pop.Species {
+		for _, o := range s.Orgs {
+			if pred(o) {
+				orgs = append(orgs, o)
+			}
+		}
+	}
+	return orgs
+}
+
 // Returns the mean population complextiy
 // Defined at http://sharpneat.sourceforge.net/phasedsearch.html
 func (pop *Population) MPC() float64 {
@@ -265,9 +989,23 @@ func (pop *Population) MPC() float64 {
 	for _, s := range pop.Species {
 		for _, o := range s.Orgs {
 			tot += len(o.Nodes) + len(o.Conns)
+			cnt += 1
 		}
-		cnt += 1
+	}
+	if cnt == 0 {
+		return 0
 	}
 
 	return float64(tot) / float64(cnt)
 }
+
+// Returns the organism with the highest fitness. Organisms which have not
+// been evaluated are ignored. Returns nil if no organism has been evaluated.
+func (pop *Population) Champion() (champ *Organism) {
+	for _, s := range pop.Species {
+		if o := s.champion(); o != nil && (champ == nil || o.Fitness[0] > champ.Fitness[0]) {
+			champ = o
+		}
+	}
+	return
+}
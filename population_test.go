@@ -0,0 +1,313 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Returns an evaluated initial population and the innovation tracker behind
+// it, to be closed by the caller
+func evaluatedPopulation(t testing.TB, s *Settings) (*Population, *innovation) {
+	t.Helper()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.rand().seed(s.Seed)
+	inno := newInnovation(nil)
+	pop, err := initialPopulation(s, inno)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (serialEval{}).Evaluate(pop, weightEval{}); err != nil {
+		t.Fatal(err)
+	}
+	return pop, inno
+}
+
+// Rolls the population on for the generations, evaluating each
+func rollTest(t testing.TB, s *Settings, inno *innovation, pop *Population, generations int) *Population {
+	t.Helper()
+	for i := 0; i < generations; i++ {
+		next, err := rollPop(s, inno, pop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = (serialEval{}).Evaluate(next, weightEval{}); err != nil {
+			t.Fatal(err)
+		}
+		pop = next
+	}
+	return pop
+}
+
+// Returns a small population of two species built by hand
+func reportPopulation() *Population {
+	o1 := &Organism{Genome: testGenome(11, 2.5, testConn{1, 2, 4, 1}, testConn{2, 3, 4, 2})}
+	o2 := &Organism{Genome: testGenome(12, 1.25, testConn{1, 2, 4, 1})}
+	o3 := &Organism{Genome: testGenome(13, 0.5, testConn{1, 2, 5, 1}, testConn{3, 5, 4, 1})}
+	o4 := &Organism{Genome: testGenome(14, 0, testConn{1, 2, 4, 1})}
+	o4.Fitness = nil
+	return &Population{Generation: 7, Species: SpeciesSlice{
+		{ID: 3, CreatedAt: 1, Age: 6, BestFitness: 2.5, BestFitAge: 4, Offspring: 12, Orgs: OrganismSlice{o1, o2},
+			Example: o1},
+		{ID: 9, CreatedAt: 5, Age: 2, BestFitness: 0.5, BestFitAge: 2, Offspring: 3, Orgs: OrganismSlice{o3, o4},
+			Example: o3},
+	}}
+}
+
+func TestReport(t *testing.T) {
+	for _, c := range []struct {
+		golden  string
+		verbose bool
+	}{
+		{"report.golden", false},
+		{"report-verbose.golden", true},
+	} {
+		var buf bytes.Buffer
+		if err := reportPopulation().Report(&buf, c.verbose); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, c.golden, buf.Bytes())
+	}
+}
+
+func TestRollPhases(t *testing.T) {
+	s := testSettings()
+	pop, inno := evaluatedPopulation(t, s)
+	defer inno.close()
+	pop = rollTest(t, s, inno, pop, 3)
+
+	// Reproduce breeds the children and carries the species without them
+	children, next, err := Reproduce(s, inno, pop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != s.PopulationSize {
+		t.Errorf("Reproduce bred %d children, want %d", len(children), s.PopulationSize)
+	}
+	if next.Generation != pop.Generation+1 {
+		t.Errorf("Next population is generation %d, want %d", next.Generation, pop.Generation+1)
+	}
+	if n := len(next.Organisms()); n != 0 {
+		t.Errorf("Next population holds %d organisms before speciation", n)
+	}
+
+	// The children may be evaluated between the phases
+	brood := &Population{Species: SpeciesSlice{{Orgs: children}}}
+	if err = (serialEval{}).Evaluate(brood, weightEval{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// SpeciateInto places every child in exactly one species, leaving none empty
+	SpeciateInto(s, inno, next, children)
+	seen := make(map[*Organism]int)
+	for _, sp := range next.Species {
+		if len(sp.Orgs) == 0 {
+			t.Errorf("Species %d is empty", sp.ID)
+		}
+		for _, o := range sp.Orgs {
+			seen[o]++
+		}
+	}
+	for _, c := range children {
+		if seen[c] != 1 {
+			t.Errorf("Child %d is in %d species", c.ID, seen[c])
+		}
+	}
+	if len(seen) != len(children) {
+		t.Errorf("Species hold %d organisms, want the %d children", len(seen), len(children))
+	}
+
+	// Once evaluated the population can be reproduced in turn, but not before
+	if _, _, err = Reproduce(s, inno, next.Copy()); err != nil {
+		t.Errorf("Reproduce of an evaluated population: %v", err)
+	}
+	for _, o := range next.Organisms() {
+		o.Fitness = nil
+	}
+	if _, _, err = Reproduce(s, inno, next); err == nil {
+		t.Error("Reproduce of an unevaluated population returned no error")
+	}
+}
+
+func TestTrainDeferSpeciation(t *testing.T) {
+	s := testSettings()
+	s.DeferSpeciation = true
+	speciated := 0
+	s.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		for _, o := range pop.Organisms() {
+			if len(o.Fitness) == 0 {
+				t.Fatalf("Organism %d of generation %d was not evaluated", o.ID, pop.Generation)
+			}
+		}
+		speciated = len(pop.Species)
+		return nil
+	}
+	trainTest(t, s, 10)
+	if speciated < 2 {
+		t.Errorf("Deferred speciation left %d species", speciated)
+	}
+}
+
+// Measures rolling a grown, evaluated population on a generation
+func BenchmarkRollPop(b *testing.B) {
+	for _, size := range []int{150, 1000} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			s := testSettings()
+			s.PopulationSize = size
+			s.MutateAddConnection, s.MutateAddNode = 0.3, 0.1
+			pop, inno := evaluatedPopulation(b, s)
+			defer inno.close()
+			pop = rollTest(b, s, inno, pop, 10)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				curr := pop.Copy()
+				b.StartTimer()
+				if _, err := rollPop(s, inno, curr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMutationOnly(t *testing.T) {
+	s := testSettings()
+	s.Crossover = 0
+	s.InterspeciesMating = 0.5
+	pop, inno := evaluatedPopulation(t, s)
+	defer inno.close()
+	for gen := 0; gen < 15; gen++ {
+		pop = rollTest(t, s, inno, pop, 1)
+		for _, o := range pop.Organisms() {
+			if len(o.Parents) > 1 {
+				t.Fatalf("Generation %d organism %d has parents %v", pop.Generation, o.ID, o.Parents)
+			}
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	s := testSettings()
+	s.MinSpeciesSize = 2
+	pop, inno := evaluatedPopulation(t, s)
+	defer inno.close()
+	pop = rollTest(t, s, inno, pop, 10)
+	living := len(pop.Species)
+	if living < 2 {
+		t.Fatalf("Only %d species to resize", living)
+	}
+
+	// Shrinking cuts the species at once, keeping their best
+	best := make(map[int]*Organism, living)
+	for _, sp := range pop.Species {
+		for _, o := range sp.Orgs {
+			if b := best[sp.ID]; b == nil || o.Fitness[0] > b.Fitness[0] {
+				best[sp.ID] = o
+			}
+		}
+	}
+	if err := pop.Resize(2*living-1, s, inno); err == nil {
+		t.Errorf("Resized to %d organisms with %d species of at least 2", 2*living-1, living)
+	}
+	if err := pop.Resize(0, s, inno); err == nil {
+		t.Error("Resized to no organisms")
+	}
+	if err := pop.Resize(20, s, inno); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pop.Organisms()); n != 20 || pop.Size != 20 {
+		t.Fatalf("Shrunk to %d organisms and size %d, want 20", n, pop.Size)
+	}
+	for _, sp := range pop.Species {
+		if len(sp.Orgs) < 2 {
+			t.Errorf("Species %d shrunk to %d organisms", sp.ID, len(sp.Orgs))
+		}
+		if !sp.Orgs.contains(best[sp.ID]) {
+			t.Errorf("Species %d lost its best organism", sp.ID)
+		}
+	}
+	if s.PopulationSize != 50 {
+		t.Errorf("Resize changed PopulationSize to %d", s.PopulationSize)
+	}
+	if c := pop.Copy(); c.Size != 20 || !c.Equal(pop) {
+		t.Errorf("Copy has size %d", c.Size)
+	}
+
+	// The rolls breed the new size, and growing waits for them
+	pop = rollTest(t, s, inno, pop, 3)
+	if n := len(pop.Organisms()); n != 20 || pop.Size != 20 {
+		t.Fatalf("Rolled to %d organisms and size %d, want 20", n, pop.Size)
+	}
+	if err := pop.Resize(80, s, inno); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pop.Organisms()); n != 20 {
+		t.Errorf("Evaluated population grew to %d organisms before the roll", n)
+	}
+	pop = rollTest(t, s, inno, pop, 1)
+	if n := len(pop.Organisms()); n != 80 {
+		t.Errorf("Rolled to %d organisms, want 80", n)
+	}
+
+	// A population not yet evaluated grows at once, unless no tracker is
+	// given, and cannot be shrunk
+	initial, err := initialPopulation(s, inno)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = initial.Resize(70, s, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(initial.Organisms()); n != 50 {
+		t.Errorf("Grew without a tracker to %d organisms", n)
+	}
+	if err = initial.Resize(70, s, inno); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool)
+	for _, o := range initial.Organisms() {
+		if ids[o.ID] {
+			t.Errorf("Organism ID %d repeated", o.ID)
+		}
+		ids[o.ID] = true
+		if err = o.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(ids) != 70 {
+		t.Errorf("Initial population grew to %d organisms, want 70", len(ids))
+	}
+	if err = initial.Resize(30, s, inno); err == nil {
+		t.Error("Shrank a population not yet evaluated")
+	}
+}
@@ -0,0 +1,203 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+// Returns the parameters of the original NEAT paper (Stanley and
+// Miikkulainen, 2002) for a genome with one bias, one input and one output
+// node. Set the node counts for the problem at hand before training.
+func DefaultSettings() *Settings {
+	return &Settings{
+		PopulationSize: 150,
+		BiasCount:      1,
+		InputCount:     1,
+		OutputCount:    1,
+
+		ExcessCoefficient:   1.0,
+		DisjointCoefficient: 1.0,
+		WeightCoefficient:   0.4,
+		CompatThreshold:     3.0,
+
+		MutateWeight:        0.8,
+		MutateWeightNew:     0.1,
+		MutateEnabled:       0.01,
+		MutateAddConnection: 0.05,
+		MutateAddNode:       0.03,
+
+		Crossover:          0.75,
+		InterspeciesMating: 0.001,
+		AgeToStagnation:    15,
+		SurvivalPercent:    0.2,
+		EliteCount:         1,
+	}
+}
+
+// Returns settings for the XOR problem, with two inputs and one output,
+// which are those of experiments/xor
+func SettingsForXOR() *Settings {
+	s := DefaultSettings()
+	s.InputCount = 2
+	s.WeightCoefficient = 0.3
+	s.ArchiveFrequency = 10
+	return s
+}
+
+// Returns settings for control problems such as pole balancing, which are
+// those of experiments/singpole: a smaller population, a looser
+// compatibility threshold weighing the weights more heavily and more
+// connections added.
+func SettingsForControl(inputs, outputs int) *Settings {
+	s := DefaultSettings()
+	s.PopulationSize = 100
+	s.InputCount = inputs
+	s.OutputCount = outputs
+	s.WeightCoefficient = 2.0
+	s.CompatThreshold = 6.0
+	s.AgeToStagnation = 20
+	s.MutateWeight = 0.5
+	s.MutateAddConnection = 0.3
+	s.MutateAddNode = 0.01
+	s.ArchiveFrequency = 10
+	return s
+}
+
+// SettingsBuilder sets up settings one call at a time, starting from
+// DefaultSettings, as in
+//
+//	s, err := neat.NewSettings().InputCount(2).PopulationSize(300).Build()
+type SettingsBuilder struct {
+	s *Settings
+}
+
+// Returns a builder starting from DefaultSettings
+func NewSettings() *SettingsBuilder {
+	return &SettingsBuilder{s: DefaultSettings()}
+}
+
+// Returns a builder starting from a copy of the given settings, such as one
+// of the presets
+func BuildFrom(s *Settings) *SettingsBuilder {
+	c := *s
+	return &SettingsBuilder{s: &c}
+}
+
+// Validates and returns the settings
+func (b *SettingsBuilder) Build() (*Settings, error) {
+	c := *b.s
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Applies a function to the settings, for those with no method of their own
+func (b *SettingsBuilder) With(f func(s *Settings)) *SettingsBuilder {
+	f(b.s)
+	return b
+}
+
+func (b *SettingsBuilder) PopulationSize(n int) *SettingsBuilder {
+	b.s.PopulationSize = n
+	return b
+}
+
+func (b *SettingsBuilder) BiasCount(n int) *SettingsBuilder {
+	b.s.BiasCount = n
+	return b
+}
+
+func (b *SettingsBuilder) InputCount(n int) *SettingsBuilder {
+	b.s.InputCount = n
+	return b
+}
+
+func (b *SettingsBuilder) OutputCount(n int) *SettingsBuilder {
+	b.s.OutputCount = n
+	return b
+}
+
+// Sets the coefficients of the genome distance
+func (b *SettingsBuilder) Coefficients(excess, disjoint, weight float64) *SettingsBuilder {
+	b.s.ExcessCoefficient, b.s.DisjointCoefficient, b.s.WeightCoefficient = excess, disjoint, weight
+	return b
+}
+
+func (b *SettingsBuilder) CompatThreshold(t float64) *SettingsBuilder {
+	b.s.CompatThreshold = t
+	return b
+}
+
+// Sets the probabilities of mutating a weight and of replacing it when it is
+// mutated
+func (b *SettingsBuilder) MutateWeight(p, replace float64) *SettingsBuilder {
+	b.s.MutateWeight, b.s.MutateWeightNew = p, replace
+	return b
+}
+
+func (b *SettingsBuilder) MutateAddNode(p float64) *SettingsBuilder {
+	b.s.MutateAddNode = p
+	return b
+}
+
+func (b *SettingsBuilder) MutateAddConnection(p float64) *SettingsBuilder {
+	b.s.MutateAddConnection = p
+	return b
+}
+
+func (b *SettingsBuilder) Crossover(p float64) *SettingsBuilder {
+	b.s.Crossover = p
+	return b
+}
+
+func (b *SettingsBuilder) InterspeciesMating(p float64) *SettingsBuilder {
+	b.s.InterspeciesMating = p
+	return b
+}
+
+func (b *SettingsBuilder) AgeToStagnation(n int) *SettingsBuilder {
+	b.s.AgeToStagnation = n
+	return b
+}
+
+func (b *SettingsBuilder) SurvivalPercent(p float64) *SettingsBuilder {
+	b.s.SurvivalPercent = p
+	return b
+}
+
+func (b *SettingsBuilder) EliteCount(n int) *SettingsBuilder {
+	b.s.EliteCount = n
+	return b
+}
+
+func (b *SettingsBuilder) Selection(name string) *SettingsBuilder {
+	b.s.Selection = name
+	return b
+}
+
+func (b *SettingsBuilder) Seed(seed int64) *SettingsBuilder {
+	b.s.Seed = seed
+	return b
+}
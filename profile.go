@@ -0,0 +1,120 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+)
+
+// Bounds on the multipliers of a mutation profile, keeping a run of unlucky
+// perturbations from switching a mutation off or making it certain
+const (
+	minProfile = 0.05
+	maxProfile = 20.0
+)
+
+// Multipliers applied to the mutation settings for one genome. With
+// Settings.SelfAdaptive each genome carries a profile which is perturbed as
+// the genome is mutated and so evolves along with it.
+type MutationProfile struct {
+	WeightPower   float64 // Scale of the weight perturbation
+	MutateWeight  float64 // Probability of perturbing a weight
+	AddConnection float64 // Probability of adding a connection
+	AddNode       float64 // Probability of adding a node
+	DelConnection float64 // Probability of removing a connection
+	DelNode       float64 // Probability of removing a node
+}
+
+// Returns a profile which leaves the mutation settings unchanged
+func unitProfile() *MutationProfile {
+	return &MutationProfile{WeightPower: 1, MutateWeight: 1, AddConnection: 1, AddNode: 1,
+		DelConnection: 1, DelNode: 1}
+}
+
+func cloneProfile(source *MutationProfile) *MutationProfile {
+	if source == nil {
+		return nil
+	}
+	clone := *source
+	return &clone
+}
+
+// Returns the profile to mutate the genome with. Without self-adaptation, or
+// for genomes which do not yet carry a profile, this is the unit profile.
+func (s *Settings) profile(g *Genome) *MutationProfile {
+	if !s.SelfAdaptive || g.Profile == nil {
+		return unitProfile()
+	}
+	return g.Profile
+}
+
+// Scales a probability by a profile multiplier, keeping it no more than 1
+func scaleProb(p, m float64) float64 {
+	return math.Min(p*m, 1)
+}
+
+// Perturbs each multiplier of the genome's profile log-normally, creating
+// the profile first if the genome has none
+func adaptProfile(settings *Settings, g *Genome) {
+	if g.Profile == nil {
+		g.Profile = unitProfile()
+	}
+	tau := settings.SelfAdaptiveRate
+	if tau == 0 {
+		tau = 0.2
+	}
+	for _, m := range g.Profile.multipliers() {
+		*m = math.Max(minProfile, math.Min(maxProfile, *m*math.Exp(tau*settings.rand().Gaussian())))
+	}
+}
+
+// Averages the parents' profiles for their child. A parent without a profile
+// counts as the unit profile.
+func mateProfiles(settings *Settings, p1, p2 *Genome) *MutationProfile {
+	if !settings.SelfAdaptive || (p1.Profile == nil && p2.Profile == nil) {
+		return nil
+	}
+	a, b := p1.Profile, p2.Profile
+	if a == nil {
+		a = unitProfile()
+	}
+	if b == nil {
+		b = unitProfile()
+	}
+	child := unitProfile()
+	ma, mb := a.multipliers(), b.multipliers()
+	for i, m := range child.multipliers() {
+		*m = (*ma[i] + *mb[i]) / 2.0
+	}
+	return child
+}
+
+// Returns pointers to the multipliers in a fixed order
+func (mp *MutationProfile) multipliers() []*float64 {
+	return []*float64{&mp.WeightPower, &mp.MutateWeight, &mp.AddConnection, &mp.AddNode,
+		&mp.DelConnection, &mp.DelNode}
+}
@@ -0,0 +1,119 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// Returns the mean absolute log of the multipliers of the organisms'
+// profiles, 0 while they are all the unit profile
+func profileSpread(t *testing.T, orgs OrganismSlice) float64 {
+	t.Helper()
+	sum, n := float64(0), 0
+	for _, o := range orgs {
+		if o.Profile == nil {
+			continue
+		}
+		for _, m := range o.Profile.multipliers() {
+			if *m < minProfile || *m > maxProfile {
+				t.Fatalf("Organism %d has a multiplier of %v, beyond the bounds", o.ID, *m)
+			}
+			sum += math.Abs(math.Log(*m))
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func TestProfileDrift(t *testing.T) {
+	s := testSettings()
+	s.SelfAdaptive = true
+	var spread []float64
+	s.Hooks.OnGenerationEnd = func(pop *Population, stats *Stats) error {
+		spread = append(spread, profileSpread(t, pop.Organisms()))
+		return nil
+	}
+	_, pop := trainTest(t, s, 20)
+	last := spread[len(spread)-1]
+	if last == 0 || last <= spread[1] {
+		t.Errorf("Profiles did not drift: spread was %v in generation 2 and %v in generation 20", spread[1], last)
+	}
+	with := 0
+	for _, o := range pop.Organisms() {
+		if o.Profile != nil {
+			with++
+		}
+	}
+	if with < len(pop.Organisms())/2 {
+		t.Errorf("Only %d of %d organisms carry a profile", with, len(pop.Organisms()))
+	}
+
+	// Without self-adaptation no profile appears
+	_, pop = trainTest(t, testSettings(), 5)
+	for _, o := range pop.Organisms() {
+		if o.Profile != nil {
+			t.Fatalf("Organism %d carries a profile without SelfAdaptive", o.ID)
+		}
+	}
+}
+
+func TestProfileInheritance(t *testing.T) {
+	s := &Settings{SelfAdaptive: true}
+	p1, p2 := crossoverParents(2, 1)
+	p1.Profile = &MutationProfile{WeightPower: 2, MutateWeight: 1, AddConnection: 4, AddNode: 1, DelConnection: 1,
+		DelNode: 0.5}
+	child := mateOften(s, p1, p2)[0]
+	want := MutationProfile{WeightPower: 1.5, MutateWeight: 1, AddConnection: 2.5, AddNode: 1, DelConnection: 1,
+		DelNode: 0.75}
+	if child.Profile == nil || *child.Profile != want {
+		t.Errorf("Child has profile %+v, want the average %+v", child.Profile, want)
+	}
+
+	// Copies and serialization carry the profile
+	clone := p1.Copy()
+	clone.Profile.WeightPower = 3
+	if p1.Profile.WeightPower != 2 {
+		t.Error("Changing the copy's profile changed the original")
+	}
+	b, err := json.Marshal(p1.Genome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g Genome
+	if err = json.Unmarshal(b, &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.Profile == nil || *g.Profile != *p1.Profile {
+		t.Errorf("Profile %+v read back as %+v", p1.Profile, g.Profile)
+	}
+}
@@ -0,0 +1,222 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"github.com/boggo/neural"
+	"math"
+	"sort"
+)
+
+// What Genome.Prune removed
+type PruneReport struct {
+	Disabled int // Disabled connection genes
+	Dead     int // Hidden nodes with no enabled path to an output
+	Constant int // Hidden nodes no sensor reaches, folded into bias connections
+	Attached int // Enabled connection genes of the removed nodes
+}
+
+func (r PruneReport) String() string {
+	return fmt.Sprintf("Pruned %d disabled connections, %d dead and %d constant nodes and %d attached connections",
+		r.Disabled, r.Dead, r.Constant, r.Attached)
+}
+
+// Returns a copy of the genome trimmed for export, with sigmoid hidden nodes.
+// See PruneWith.
+func (g *Genome) Prune() (*Genome, PruneReport) {
+	return g.PruneWith(neural.SIGMOID)
+}
+
+// Returns a copy of the genome trimmed of structure which does not shape
+// its outputs, along with a count of what was removed. Disabled connections
+// go, as do hidden nodes with no enabled path to an output and their
+// connections. A hidden node which no sensor reaches gives the same value on
+// every activation; such nodes are removed too and their contribution added
+// to a bias connection of each node they fed, computing their values with
+// the hidden activation function, either neural.SIGMOID or neural.DIRECT.
+// They are kept if there is no bias node or any of them feeds a node
+// activated before it. Bias, input and output nodes are never removed.
+//
+// The decoded network gives the same outputs as that of the genome, but for
+// rounding in the sums which took folded contributions. A bias
+// connection made by the folding takes the marker of a connection it
+// replaced, so the pruned genome is meant for export rather than breeding.
+func (g *Genome) PruneWith(hidden neural.FuncType) (pruned *Genome, report PruneReport) {
+	pruned = cloneGenome(g, g.ID)
+
+	// Drop the disabled connections
+	for _, k := range pruned.Conns.sortedMarkers() {
+		if !pruned.Conns[k].Enabled {
+			delete(pruned.Conns, k)
+			report.Disabled += 1
+		}
+	}
+
+	// Find the nodes leading to an output and those reached from a sensor
+	var sensors, outputs []int
+	bias := -1
+	for _, k := range pruned.Nodes.sortedMarkers() {
+		switch pruned.Nodes[k].Type {
+		case neural.BIAS:
+			if bias < 0 {
+				bias = k
+			}
+			sensors = append(sensors, k)
+		case neural.INPUT:
+			sensors = append(sensors, k)
+		case neural.OUTPUT:
+			outputs = append(outputs, k)
+		}
+	}
+	toOutput := pruned.spread(outputs, true)
+	fromSensor := pruned.spread(sensors, false)
+
+	// Remove the dead nodes
+	for _, k := range pruned.Nodes.sortedMarkers() {
+		if pruned.Nodes[k].Type == neural.HIDDEN && !toOutput[k] {
+			report.Attached += pruned.removeNode(k)
+			report.Dead += 1
+		}
+	}
+
+	// Fold the constant nodes into the bias connections
+	var constant []*NodeGene
+	for _, ng := range pruned.Nodes {
+		if ng.Type == neural.HIDDEN && !fromSensor[ng.Marker] {
+			constant = append(constant, ng)
+		}
+	}
+	if len(constant) == 0 || bias < 0 {
+		return
+	}
+	sort.Slice(constant, func(i, j int) bool { return before(constant[i], constant[j]) })
+	isConstant := make(map[int]bool, len(constant))
+	for _, ng := range constant {
+		isConstant[ng.Marker] = true
+	}
+	for _, cg := range pruned.Conns {
+		if isConstant[cg.Source] && !before(pruned.Nodes[cg.Source], pruned.Nodes[cg.Target]) {
+			return // The first activation would see the value change
+		}
+	}
+	values := make(map[int]float64, len(constant))
+	for _, ng := range constant {
+		var sum float64
+		for _, k := range pruned.Conns.sortedMarkers() {
+			if cg := pruned.Conns[k]; cg.Target == ng.Marker {
+				sum += values[cg.Source] * cg.Weight
+			}
+		}
+		sum *= ng.EffectiveResponse()
+		if hidden == neural.DIRECT {
+			values[ng.Marker] = sum
+		} else {
+			values[ng.Marker] = 1 / (1 + math.Exp(-sum))
+		}
+	}
+	for _, k := range pruned.Conns.sortedMarkers() {
+		cg := pruned.Conns[k]
+		if !isConstant[cg.Source] || isConstant[cg.Target] {
+			continue
+		}
+		shift := values[cg.Source] * cg.Weight
+		if bc := pruned.conn(bias, cg.Target); bc != nil {
+			bc.Weight += shift
+			continue
+		}
+		pruned.Conns[k] = &ConnGene{Marker: k, Source: bias, Target: cg.Target, Weight: shift, Enabled: true}
+	}
+	for _, ng := range constant {
+		report.Attached += pruned.removeNode(ng.Marker)
+		report.Constant += 1
+	}
+	return
+}
+
+// Returns the nodes reached from those given along enabled connections,
+// following them backwards if reverse is set
+func (g *Genome) spread(from []int, reverse bool) map[int]bool {
+	seen := make(map[int]bool, len(g.Nodes))
+	stack := append([]int(nil), from...)
+	for _, k := range from {
+		seen[k] = true
+	}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, cg := range g.Conns {
+			if !cg.Enabled {
+				continue
+			}
+			a, b := cg.Source, cg.Target
+			if reverse {
+				a, b = b, a
+			}
+			if a == n && !seen[b] {
+				seen[b] = true
+				stack = append(stack, b)
+			}
+		}
+	}
+	return seen
+}
+
+// Returns the enabled connection from the source node to the target, if any
+func (g *Genome) conn(source, target int) *ConnGene {
+	for _, k := range g.Conns.sortedMarkers() {
+		if cg := g.Conns[k]; cg.Enabled && cg.Source == source && cg.Target == target {
+			return cg
+		}
+	}
+	return nil
+}
+
+// Removes the node and its connections, returning the number of connections
+// removed
+func (g *Genome) removeNode(marker int) (n int) {
+	for k, cg := range g.Conns {
+		if cg.Source == marker || cg.Target == marker {
+			delete(g.Conns, k)
+			n += 1
+		}
+	}
+	delete(g.Nodes, marker)
+	return
+}
+
+// Returns true if node a is activated before node b, ordering the nodes by
+// position as the decoders do
+func before(a, b *NodeGene) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	if a.X != b.X {
+		return a.X < b.X
+	}
+	return a.Marker < b.Marker
+}
@@ -34,6 +34,9 @@ import (
 
 type rng struct {
 	*rand.Rand
+	src  *countedSource
+	iset bool    // Is there a spare Gaussian deviate?
+	gset float64 // The spare Gaussian deviate
 }
 
 var (
@@ -41,7 +44,66 @@ var (
 )
 
 func init() {
-	random = rng{rand.New(rand.NewSource(time.Now().UnixNano()))}
+	random = *newRng(time.Now().UnixNano())
+}
+
+// Source which counts the values drawn from it, so that the state of a
+// generator may be recorded as its seed and the number of draws since
+type countedSource struct {
+	rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func (c *countedSource) Int63() int64 {
+	c.draws += 1
+	return c.Source64.Int63()
+}
+
+func (c *countedSource) Uint64() uint64 {
+	c.draws += 1
+	return c.Source64.Uint64()
+}
+
+func (c *countedSource) Seed(seed int64) {
+	c.seed, c.draws = seed, 0
+	c.Source64.Seed(seed)
+}
+
+// Reseeds the generator, discarding any spare Gaussian deviate, so that the
+// sequence which follows is the same for the same seed
+func (r *rng) seed(seed int64) {
+	r.Seed(seed)
+	r.iset = false
+}
+
+// State of a random number generator: its seed, the values drawn since and
+// any spare Gaussian deviate
+type RandomState struct {
+	Seed  int64
+	Draws uint64
+	Spare *float64 `json:",omitempty"`
+}
+
+func (r *rng) state() (st RandomState) {
+	st.Seed, st.Draws = r.src.seed, r.src.draws
+	if r.iset {
+		spare := r.gset
+		st.Spare = &spare
+	}
+	return
+}
+
+// Returns the generator to the recorded state by reseeding it and drawing
+// again as many values
+func (r *rng) restore(st RandomState) {
+	r.seed(st.Seed)
+	for r.src.draws < st.Draws {
+		r.src.Int63()
+	}
+	if st.Spare != nil {
+		r.iset, r.gset = true, *st.Spare
+	}
 }
 
 func (r *rng) Between(a, b float64) float64 {
@@ -59,14 +121,9 @@ func (r *rng) Int(n int) int {
 // Returns a normally distributed deviate with zero mean and unit variance.
 // From Numerical Recipes in C.
 // TODO: involve the mu and sigma parameters. current use mu=0 and sigma=1
-var (
-	iset bool
-	gset float64
-)
-
 func (r *rng) Gaussian() float64 {
 	var fac, rsq, v1, v2 float64
-	if iset == false {
+	if r.iset == false {
 		rsq = 0
 		for rsq >= 1.0 || rsq == 0.0 {
 			v1 = 2.0*r.Next() - 1.0
@@ -74,11 +131,17 @@ func (r *rng) Gaussian() float64 {
 			rsq = v1*v1 + v2*v2
 		}
 		fac = math.Sqrt(-2.0 * math.Log(rsq) / rsq)
-		gset = v1 * fac
-		iset = true
+		r.gset = v1 * fac
+		r.iset = true
 		return v2 * fac
 	} else {
-		iset = false
-		return gset
+		r.iset = false
+		return r.gset
 	}
 }
+
+// Returns a generator seeded with the given seed
+func newRng(seed int64) *rng {
+	src := &countedSource{Source64: rand.NewSource(seed).(rand.Source64), seed: seed}
+	return &rng{Rand: rand.New(src), src: src}
+}
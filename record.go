@@ -0,0 +1,140 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// State of a run just before one of its rolls, as written to
+// Settings.Record by Train as a line of JSON. With it Replay can set up
+// that roll again exactly, so that a failure may be stepped through.
+type ReplayRecord struct {
+	Generation int         // Generation of the population about to be rolled
+	Checksum   uint64      // Of the population, see Population.Checksum
+	Random     RandomState // State of the shared random number generator
+	NextID     int         // Next ID the innovation history will hand out
+	NextMarker int         // Next marker the innovation history will hand out
+	Rates      ReplayRates // Rates in effect under the phased search
+	Population *Population
+
+	// Innovation history, recorded when it is kept across generations, by
+	// Settings.PersistentInnovations or a shared Settings.Innovations
+	Innovations *innovationRegistry `json:",omitempty"`
+}
+
+// The settings which Train's phased search changes from generation to
+// generation
+type ReplayRates struct {
+	MutateAddNode, MutateAddConnection, MutateDelNode, MutateDelConnection, Crossover float64
+}
+
+// Returns a checksum of the population's JSON encoding, which covers every
+// part of it that is archived
+func (pop *Population) Checksum() (uint64, error) {
+	b, err := json.Marshal(pop)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64(), nil
+}
+
+// Writes the state of the run before this roll of the population to
+// Settings.Record
+func record(settings *Settings, inno *innovation, pop *Population) (err error) {
+	rec := ReplayRecord{Generation: pop.Generation, Random: settings.rand().state(), Population: pop,
+		Rates: ReplayRates{settings.MutateAddNode, settings.MutateAddConnection, settings.MutateDelNode,
+			settings.MutateDelConnection, settings.Crossover}}
+	rec.NextID, rec.NextMarker = inno.next()
+	if inno.persistent || settings.PersistentInnovations {
+		rec.Innovations = inno.registry()
+	}
+	if rec.Checksum, err = pop.Checksum(); err != nil {
+		return
+	}
+	return json.NewEncoder(settings.Record).Encode(rec)
+}
+
+// Sets up the roll of the given generation as recorded in the replay: the
+// population about to be rolled, the shared random number generator and an
+// innovation history in the states they were in, holding the history of a
+// run that kept it across generations. The settings, which should
+// otherwise be those of the recorded run, are given the rates the phased
+// search had set. Reproduce and SpeciateInto, or StepGeneration, then repeat
+// the roll. Close the innovation history when done.
+func Replay(r io.Reader, generation int, settings *Settings) (pop *Population, inno *innovation, err error) {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1<<30)
+	for scan.Scan() {
+		var rec ReplayRecord
+		if err = json.Unmarshal(scan.Bytes(), &rec); err != nil {
+			return
+		}
+		if rec.Generation != generation {
+			continue
+		}
+
+		// Check the population is as it was
+		var sum uint64
+		if sum, err = rec.Population.Checksum(); err != nil {
+			return
+		}
+		if sum != rec.Checksum {
+			err = fmt.Errorf("Population of generation %d does not match its checksum", generation)
+			return
+		}
+
+		// Restore the state
+		random.restore(rec.Random)
+		if settings != nil {
+			settings.MutateAddNode, settings.MutateAddConnection = rec.Rates.MutateAddNode, rec.Rates.MutateAddConnection
+			settings.MutateDelNode, settings.MutateDelConnection = rec.Rates.MutateDelNode, rec.Rates.MutateDelConnection
+			settings.Crossover = rec.Rates.Crossover
+		}
+		pop, inno = rec.Population, newInnovationAt(rec.NextID, rec.NextMarker)
+		if rec.Innovations != nil {
+			for _, n := range rec.Innovations.Nodes {
+				inno.nodes[nodeKey{n.X, n.Y}] = n.Marker
+			}
+			for _, c := range rec.Innovations.Conns {
+				inno.conns[connKey{c.Source, c.Target}] = c.Marker
+			}
+			inno.persistent = true
+		}
+		return
+	}
+	if err = scan.Err(); err == nil {
+		err = fmt.Errorf("Generation %d was not recorded", generation)
+	}
+	return
+}
@@ -0,0 +1,122 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Returns the records a run wrote, by generation
+func records(t *testing.T, b []byte) map[int]ReplayRecord {
+	t.Helper()
+	recs := make(map[int]ReplayRecord)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var rec ReplayRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs[rec.Generation] = rec
+	}
+	return recs
+}
+
+func TestReplay(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(s *Settings)
+	}{
+		{"fresh", func(s *Settings) {}},
+		{"persistent", func(s *Settings) { s.PersistentInnovations = true }},
+		{"shared", func(s *Settings) { s.Innovations = NewInnovation(nil) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := testSettings()
+			s.MutateAddConnection, s.MutateAddNode = 0.5, 0.2
+			tc.setup(s)
+			if s.Innovations != nil {
+				defer s.Innovations.Close()
+			}
+			var buf bytes.Buffer
+			s.Record = &buf
+			trainTest(t, s, 8)
+			recorded := buf.Bytes()
+			recs := records(t, recorded)
+			if len(recs) != 7 {
+				t.Fatalf("recorded %d generations, want 7", len(recs))
+			}
+			for g := 1; g < 7; g++ {
+				if persistent := recs[g].Innovations != nil; persistent != (tc.name != "fresh") {
+					t.Errorf("generation %d: recorded history %v", g, persistent)
+				}
+			}
+
+			// Each roll, replayed, ends in the population recorded next
+			s.Record = nil
+			for g := 1; g < 7; g++ {
+				pop, inno, err := Replay(bytes.NewReader(recorded), g, s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next, err := rollPop(s, inno, pop)
+				inno.close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = evaluateCounted(context.Background(), s, serialEval{}, next, weightEval{}, priorFitness(s, next.Organisms()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				sum, err := next.Checksum()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sum != recs[g+1].Checksum {
+					t.Errorf("generation %d: replayed roll has checksum %d, recorded %d", g, sum, recs[g+1].Checksum)
+				}
+			}
+
+			// Only recorded generations, unaltered, can be replayed
+			if _, _, err := Replay(bytes.NewReader(recorded), 8, s); err == nil || !strings.Contains(err.Error(), "was not recorded") {
+				t.Errorf("replaying an unrecorded generation: %v", err)
+			}
+			rec := recs[3]
+			rec.Checksum++
+			var bad bytes.Buffer
+			if err := json.NewEncoder(&bad).Encode(rec); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := Replay(&bad, 3, s); err == nil || !strings.Contains(err.Error(), "does not match its checksum") {
+				t.Errorf("replaying an altered population: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A node innovation in an exported registry: the position a split put the
+// node at and the marker it took
+type NodeInnovation struct {
+	X, Y   float64
+	Marker int
+}
+
+// A connection innovation in an exported registry: the markers of the nodes
+// it joins and the marker it took
+type ConnInnovation struct {
+	Source, Target int
+	Marker         int
+}
+
+// Innovation history as exported, sorted by marker
+type innovationRegistry struct {
+	Nodes []NodeInnovation
+	Conns []ConnInnovation
+}
+
+// Writes the innovation history as JSON, to be loaded into other runs with
+// LoadInnovations so that their genes share a numbering. Unless the history
+// is kept for the whole run, by Settings.PersistentInnovations or by loading
+// it, it holds only the innovations of the latest generation. Export should
+// not be called while a generation is being bred.
+func (inno *innovation) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inno.registry())
+}
+
+// Returns the innovation history sorted by marker
+func (inno *innovation) registry() *innovationRegistry {
+	reg := &innovationRegistry{}
+	for k, m := range inno.nodes {
+		reg.Nodes = append(reg.Nodes, NodeInnovation{X: k.X, Y: k.Y, Marker: m})
+	}
+	for k, m := range inno.conns {
+		reg.Conns = append(reg.Conns, ConnInnovation{Source: k.Source, Target: k.Target, Marker: m})
+	}
+	sort.Slice(reg.Nodes, func(i, j int) bool { return reg.Nodes[i].Marker < reg.Nodes[j].Marker })
+	sort.Slice(reg.Conns, func(i, j int) bool { return reg.Conns[i].Marker < reg.Conns[j].Marker })
+	return reg
+}
+
+// Creates an innovation tracker holding the history written by Export. The
+// tracker keeps its history for the whole run, handing a structural
+// innovation the marker it took in the exporting run, and gives new ones
+// markers past those of the history. Give it to Train as
+// Settings.Innovations, or use it to drive the generations directly, for
+// genomes of different runs to align by marker in distance and crossover.
+// A history giving one marker to two innovations, or two markers to one, is
+// reported as an error listing the collisions.
+func LoadInnovations(r io.Reader) (inno *innovation, err error) {
+	var reg innovationRegistry
+	if err = json.NewDecoder(r).Decode(&reg); err != nil {
+		return
+	}
+
+	// Check for collisions
+	var collisions []string
+	owner := make(map[int]string, len(reg.Nodes)+len(reg.Conns))
+	claim := func(m int, what string) {
+		if prev, ok := owner[m]; ok {
+			collisions = append(collisions, fmt.Sprintf("marker %d for both %s and %s", m, prev, what))
+			return
+		}
+		owner[m] = what
+	}
+	nodes := make(map[nodeKey]int, len(reg.Nodes))
+	for _, n := range reg.Nodes {
+		k := nodeKey{n.X, n.Y}
+		what := fmt.Sprintf("node at %v, %v", n.X, n.Y)
+		if m, ok := nodes[k]; ok {
+			collisions = append(collisions, fmt.Sprintf("markers %d and %d for %s", m, n.Marker, what))
+			continue
+		}
+		nodes[k] = n.Marker
+		claim(n.Marker, what)
+	}
+	conns := make(map[connKey]int, len(reg.Conns))
+	for _, c := range reg.Conns {
+		k := connKey{c.Source, c.Target}
+		what := fmt.Sprintf("connection %d->%d", c.Source, c.Target)
+		if m, ok := conns[k]; ok {
+			collisions = append(collisions, fmt.Sprintf("markers %d and %d for %s", m, c.Marker, what))
+			continue
+		}
+		conns[k] = c.Marker
+		claim(c.Marker, what)
+	}
+	if len(collisions) > 0 {
+		err = fmt.Errorf("Innovation history has %d collisions: %s", len(collisions), strings.Join(collisions, "; "))
+		return
+	}
+
+	// Start the sequences past the history
+	marker := 0
+	for m := range owner {
+		if m > marker {
+			marker = m
+		}
+	}
+	inno = newInnovationAt(1, marker+1)
+	inno.nodes, inno.conns, inno.persistent = nodes, conns, true
+	return
+}
+
+// Advances the sequences past the IDs and markers used in the population, as
+// when a shared tracker is given a restored population
+func (inno *innovation) skipPast(pop *Population) {
+	if pop == nil {
+		return
+	}
+	id, marker := 0, 0
+	for _, s := range pop.Species {
+		if s.ID > id {
+			id = s.ID
+		}
+		for _, o := range s.Orgs {
+			if o.ID > id {
+				id = o.ID
+			}
+			for m := range o.Nodes {
+				if m > marker {
+					marker = m
+				}
+			}
+			for m := range o.Conns {
+				if m > marker {
+					marker = m
+				}
+			}
+		}
+	}
+	for next, _ := inno.next(); next <= id; next, _ = inno.next() {
+		inno.nextID()
+	}
+	for _, next := inno.next(); next <= marker; _, next = inno.next() {
+		inno.nextMarker()
+	}
+}
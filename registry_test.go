@@ -0,0 +1,146 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Checks that the genes the two organisms share by marker are the same
+// innovation, and that the same innovation took the same marker in both,
+// returning the number of matching connection genes
+func checkAligned(t *testing.T, o1, o2 *Organism) (matching int) {
+	t.Helper()
+	for m, n1 := range o1.Nodes {
+		if n2, ok := o2.Nodes[m]; ok && (n1.X != n2.X || n1.Y != n2.Y) {
+			t.Errorf("Node %d at %v, %v in one organism and %v, %v in the other", m, n1.X, n1.Y, n2.X, n2.Y)
+		}
+	}
+	for m, c1 := range o1.Conns {
+		if c2, ok := o2.Conns[m]; ok {
+			if c1.Source != c2.Source || c1.Target != c2.Target {
+				t.Errorf("Connection %d joins %d->%d in one organism and %d->%d in the other", m, c1.Source,
+					c1.Target, c2.Source, c2.Target)
+			}
+			matching += 1
+		}
+		for _, c2 := range o2.Conns {
+			if c1.Source == c2.Source && c1.Target == c2.Target && c1.Marker != c2.Marker {
+				t.Errorf("Connection %d->%d has marker %d in one organism and %d in the other", c1.Source,
+					c1.Target, c1.Marker, c2.Marker)
+			}
+		}
+	}
+	return
+}
+
+func TestSharedInnovations(t *testing.T) {
+
+	// Run A keeps its history and exports it
+	sa := testSettings()
+	sa.Innovations = newInnovation(nil)
+	defer sa.Innovations.close()
+	champA, _ := trainTest(t, sa, 20)
+	var exported bytes.Buffer
+	if err := sa.Innovations.Export(&exported); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run B, with another seed, starts from it
+	inno, err := LoadInnovations(bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inno.close()
+	var again bytes.Buffer
+	if err = inno.Export(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), exported.Bytes()) {
+		t.Error("Loaded history exported differently")
+	}
+	sb := testSettings()
+	sb.Seed = 8
+	sb.Innovations = inno
+	champB, _ := trainTest(t, sb, 20)
+
+	// The champions align and breed
+	if n := checkAligned(t, champA, champB); n == 0 {
+		t.Error("Champions share no connection genes")
+	}
+	for i := 0; i < 20; i++ {
+		child := crossover(sb, inno, champA, champB)
+		if err = child.Validate(); err != nil {
+			t.Fatalf("Child of the champions: %v", err)
+		}
+		for m, cg := range child.Conns {
+			p, ok := champA.Conns[m]
+			if !ok {
+				p = champB.Conns[m]
+			}
+			if p == nil || p.Source != cg.Source || p.Target != cg.Target {
+				t.Fatalf("Child connection %d, %d->%d, is in neither champion", m, cg.Source, cg.Target)
+			}
+		}
+	}
+	if d := distance(sb, champA, champB); d != distance(sb, champB, champA) {
+		t.Errorf("Distance between the champions is %v one way and %v the other", d,
+			distance(sb, champB, champA))
+	}
+}
+
+func TestLoadInnovationsCollisions(t *testing.T) {
+	for _, c := range []struct {
+		name, history string
+		collisions    int
+	}{
+		{"clean", `{"Nodes": [{"X": 0.5, "Y": 0.5, "Marker": 7}], "Conns": [{"Source": 1, "Target": 7, "Marker": 8}]}`, 0},
+		{"shared marker", `{"Nodes": [{"X": 0.5, "Y": 0.5, "Marker": 7}], "Conns": [{"Source": 1, "Target": 7, "Marker": 7}]}`, 1},
+		{"two node markers", `{"Nodes": [{"X": 0.5, "Y": 0.5, "Marker": 7}, {"X": 0.5, "Y": 0.5, "Marker": 9}]}`, 1},
+		{"two connection markers", `{"Conns": [{"Source": 1, "Target": 4, "Marker": 5}, {"Source": 1, "Target": 4, "Marker": 6}]}`, 1},
+	} {
+		inno, err := LoadInnovations(strings.NewReader(c.history))
+		if c.collisions == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+				continue
+			}
+			if _, m := inno.next(); m != 9 {
+				t.Errorf("%s: next marker is %d, want 9", c.name, m)
+			}
+			inno.close()
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: loaded without error", c.name)
+		} else if !strings.Contains(err.Error(), "1 collisions") {
+			t.Errorf("%s: got %q, want 1 collision", c.name, err)
+		}
+	}
+}
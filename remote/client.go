@@ -0,0 +1,204 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package remote spreads the evaluation of a population over worker
+// processes reached by HTTP.
+//
+// The protocol is a single endpoint. The master POSTs a batch of genomes,
+// in the package's JSON format, to /evaluate on a worker:
+//
+//	{"Genomes": [{"ID": 12, "Nodes": {...}, "Conns": {...}}, ...]}
+//
+// and the worker answers 200 OK with the fitness of each genome, in order:
+//
+//	{"Fitness": [[0.93], [0.41], ...]}
+//
+// or any other status with {"Error": "..."} describing the failure.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/boggo/neat"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Body of a request to a worker
+type request struct {
+	Genomes []*neat.Genome
+}
+
+// Body of a worker's response
+type response struct {
+	Fitness [][]float64 `json:",omitempty"`
+	Error   string      `json:",omitempty"`
+}
+
+// Evaluator on the master which hands the organisms to the workers
+type remoteEvaluator struct {
+	workers     []string // Base URLs of the workers
+	concurrency int      // Requests in flight to each worker
+	retries     int      // Attempts after the first before a worker is given up on
+	client      *http.Client
+}
+
+// Returns a new evaluator sending batches to the workers at the given base
+// URLs, such as "http://10.0.0.7:8080". Each worker is sent at most
+// concurrency batches at a time, defaulting to 1, and each request is
+// retried the given number of times before the worker is dropped for the
+// rest of the generation and its batch handed to another. A zero timeout
+// lets a request run as long as it needs.
+func NewEvaluator(workers []string, concurrency, retries int, timeout time.Duration) *remoteEvaluator {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &remoteEvaluator{workers: workers, concurrency: concurrency, retries: retries,
+		client: &http.Client{Timeout: timeout}}
+}
+
+// Evaluates a single organism as a batch of one
+func (r *remoteEvaluator) Evaluate(org *neat.Organism) error {
+	return neat.EvaluateBatch(r, []*neat.Organism{org})
+}
+
+// Evaluates the organisms, dividing them into one batch for each request
+// the workers may have in flight. Failed batches are reassigned to the
+// workers which remain until none do.
+func (r *remoteEvaluator) EvaluateBatch(orgs []*neat.Organism) (fitness [][]float64, err error) {
+
+	type batch struct{ start, end int }
+
+	// Divide the organisms
+	fitness = make([][]float64, len(orgs))
+	n := len(r.workers) * r.concurrency
+	if n == 0 {
+		return nil, fmt.Errorf("There are no workers to evaluate with")
+	}
+	size := (len(orgs) + n - 1) / n
+	var remaining []batch
+	for start := 0; start < len(orgs); start += size {
+		end := start + size
+		if end > len(orgs) {
+			end = len(orgs)
+		}
+		remaining = append(remaining, batch{start, end})
+	}
+
+	// Hand out the batches until all are evaluated
+	live := append([]string(nil), r.workers...)
+	var last error
+	for len(remaining) > 0 {
+		if len(live) == 0 {
+			return nil, fmt.Errorf("Every worker failed, the last with: %v", last)
+		}
+
+		var w sync.WaitGroup
+		var m sync.Mutex
+		var failed []batch
+		dead := make(map[string]bool)
+		sems := make(map[string]chan bool, len(live))
+		for _, url := range live {
+			sems[url] = make(chan bool, r.concurrency)
+		}
+		for i, b := range remaining {
+			url := live[i%len(live)]
+			w.Add(1)
+			go func(url string, b batch) {
+				defer w.Done()
+				sems[url] <- true
+				defer func() { <-sems[url] }()
+
+				f, e2 := r.post(url, orgs[b.start:b.end])
+				m.Lock()
+				defer m.Unlock()
+				if e2 != nil {
+					failed = append(failed, b)
+					dead[url] = true
+					last = e2
+					return
+				}
+				copy(fitness[b.start:b.end], f)
+			}(url, b)
+		}
+		w.Wait()
+
+		// Drop the failed workers
+		alive := live[:0]
+		for _, url := range live {
+			if !dead[url] {
+				alive = append(alive, url)
+			}
+		}
+		live = alive
+		remaining = failed
+	}
+	return
+}
+
+// Sends the organisms' genomes to the worker, retrying on failure
+func (r *remoteEvaluator) post(url string, orgs []*neat.Organism) (fitness [][]float64, err error) {
+
+	req := request{Genomes: make([]*neat.Genome, len(orgs))}
+	for i, o := range orgs {
+		req.Genomes[i] = o.Genome
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		fitness, err = r.send(url, body)
+		if err == nil && len(fitness) != len(orgs) {
+			err = fmt.Errorf("Worker %s returned %d results for %d genomes", url, len(fitness), len(orgs))
+		}
+		if err == nil {
+			return
+		}
+	}
+	return nil, err
+}
+
+// Makes a single request of the worker
+func (r *remoteEvaluator) send(url string, body []byte) (fitness [][]float64, err error) {
+	resp, err := r.client.Post(url+"/evaluate", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var res response
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("Worker %s sent an unreadable response: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Worker %s failed with %s: %s", url, resp.Status, res.Error)
+	}
+	return res.Fitness, nil
+}
@@ -0,0 +1,251 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package remote_test
+
+import (
+	"encoding/json"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/remote"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Decodes a genome into a phenome which does nothing, the evaluator scoring
+// genomes directly
+type nullDecoder struct{}
+
+func (nullDecoder) Decode(g *neat.Genome) (neat.Phenome, error) { return nullPhenome{}, nil }
+
+type nullPhenome struct{}
+
+func (nullPhenome) Analyze(inputs []float64) ([]float64, error) { return nil, nil }
+
+// Gives each organism its ID as fitness
+type idEval struct{}
+
+func (idEval) Evaluate(org *neat.Organism) error {
+	org.Fitness = []float64{float64(org.ID)}
+	return nil
+}
+
+// A worker counting its requests, failing the first fail of them and
+// noting the most it has had in flight at once
+type worker struct {
+	*httptest.Server
+	fail     int64
+	delay    time.Duration
+	requests int64
+	inFlight int64
+	maxm     sync.Mutex
+	max      int64
+}
+
+func newWorker(fail int64, delay time.Duration) *worker {
+	w := &worker{fail: fail, delay: delay}
+	h := remote.Handler(nullDecoder{}, idEval{})
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&w.inFlight, 1)
+		defer atomic.AddInt64(&w.inFlight, -1)
+		w.maxm.Lock()
+		if n > w.max {
+			w.max = n
+		}
+		w.maxm.Unlock()
+		time.Sleep(w.delay)
+		if atomic.AddInt64(&w.requests, 1) <= w.fail {
+			rw.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(rw).Encode(map[string]string{"Error": "Failing on purpose"})
+			return
+		}
+		h.ServeHTTP(rw, r)
+	}))
+	return w
+}
+
+// Returns n organisms with IDs from 1
+func organisms(n int) []*neat.Organism {
+	orgs := make([]*neat.Organism, n)
+	for i := range orgs {
+		orgs[i] = &neat.Organism{Genome: &neat.Genome{ID: i + 1, Nodes: make(neat.NodeGeneMap),
+			Conns: make(neat.ConnGeneMap)}}
+	}
+	return orgs
+}
+
+// Checks that the fitness came back in the organisms' order
+func checkOrder(t *testing.T, fitness [][]float64) {
+	t.Helper()
+	for i, f := range fitness {
+		if len(f) != 1 || f[0] != float64(i+1) {
+			t.Fatalf("Organism %d was given fitness %v", i+1, f)
+		}
+	}
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	good := newWorker(0, 0)
+	defer good.Close()
+	fitness, err := remote.NewEvaluator([]string{good.URL}, 2, 0, 0).EvaluateBatch(organisms(25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fitness) != 25 {
+		t.Fatalf("Got %d results for 25 organisms", len(fitness))
+	}
+	checkOrder(t, fitness)
+
+	// Through neat.EvaluateBatch the organisms are given their fitness
+	orgs := organisms(5)
+	if err = neat.EvaluateBatch(remote.NewEvaluator([]string{good.URL}, 1, 0, 0), orgs); err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range orgs {
+		if o.Fitness[0] != float64(i+1) {
+			t.Errorf("Organism %d was given fitness %v", o.ID, o.Fitness)
+		}
+	}
+}
+
+func TestEvaluateBatchReassigns(t *testing.T) {
+	bad, good := newWorker(1<<30, 0), newWorker(0, 0)
+	defer bad.Close()
+	defer good.Close()
+	fitness, err := remote.NewEvaluator([]string{bad.URL, good.URL}, 1, 2, 0).EvaluateBatch(organisms(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, fitness)
+
+	// The failing worker was tried once and retried twice on its batch,
+	// then dropped, the survivor evaluating both batches
+	if n := atomic.LoadInt64(&bad.requests); n != 3 {
+		t.Errorf("Failing worker had %d requests, want 3", n)
+	}
+	if n := atomic.LoadInt64(&good.requests); n != 2 {
+		t.Errorf("Surviving worker had %d requests, want 2", n)
+	}
+}
+
+func TestEvaluateBatchAllFail(t *testing.T) {
+	bad1, bad2 := newWorker(1<<30, 0), newWorker(1<<30, 0)
+	defer bad1.Close()
+	defer bad2.Close()
+	done := make(chan error)
+	go func() {
+		_, err := remote.NewEvaluator([]string{bad1.URL, bad2.URL}, 2, 1, 0).EvaluateBatch(organisms(10))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "Every worker failed") {
+			t.Errorf("Got %v, want every worker to have failed", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Evaluation hung with every worker failing")
+	}
+
+	// Nor is there anything to do without workers
+	if _, err := remote.NewEvaluator(nil, 1, 0, 0).EvaluateBatch(organisms(1)); err == nil {
+		t.Error("Evaluated without workers")
+	}
+}
+
+func TestEvaluateBatchRetries(t *testing.T) {
+	for _, c := range []struct {
+		fail, retries int
+		ok            bool
+	}{{2, 2, true}, {2, 3, true}, {3, 2, false}} {
+		w := newWorker(int64(c.fail), 0)
+		_, err := remote.NewEvaluator([]string{w.URL}, 1, c.retries, 0).EvaluateBatch(organisms(4))
+		switch {
+		case c.ok && err != nil:
+			t.Errorf("%d failures and %d retries: %v", c.fail, c.retries, err)
+		case !c.ok && err == nil:
+			t.Errorf("%d failures and %d retries: evaluated", c.fail, c.retries)
+		}
+		want := int64(c.fail + 1) // Until one succeeds
+		if !c.ok {
+			want = int64(c.retries + 1)
+		}
+		if n := atomic.LoadInt64(&w.requests); n != want {
+			t.Errorf("%d failures and %d retries: %d requests, want %d", c.fail, c.retries, n, want)
+		}
+		w.Close()
+	}
+}
+
+func TestEvaluateBatchConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		workers := []*worker{newWorker(0, 20*time.Millisecond), newWorker(0, 20*time.Millisecond)}
+		urls := make([]string, len(workers))
+		for i, w := range workers {
+			urls[i] = w.URL
+		}
+		fitness, err := remote.NewEvaluator(urls, concurrency, 0, 0).EvaluateBatch(organisms(60))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkOrder(t, fitness)
+		for i, w := range workers {
+			if w.max > int64(concurrency) {
+				t.Errorf("Worker %d had %d requests in flight, the cap being %d", i, w.max, concurrency)
+			}
+			if n := atomic.LoadInt64(&w.requests); n != int64(concurrency) {
+				t.Errorf("Worker %d had %d requests, want %d", i, n, concurrency)
+			}
+			w.Close()
+		}
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	srv := httptest.NewServer(remote.Handler(nullDecoder{}, idEval{}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/evaluate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered %s", resp.Status)
+	}
+	resp, err = http.Post(srv.URL+"/evaluate", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct{ Error string }
+	json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || res.Error == "" {
+		t.Errorf("Unreadable request answered %s, %q", resp.Status, res.Error)
+	}
+}
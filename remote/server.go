@@ -0,0 +1,102 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package remote
+
+import (
+	"encoding/json"
+	"github.com/boggo/neat"
+	"net/http"
+)
+
+// Returns a handler serving the /evaluate endpoint of a worker. Each genome
+// received is decoded and evaluated in turn, or all at once if the evaluator
+// implements neat.BatchEval.
+func Handler(dcode neat.Decoder, eval neat.OrgEval) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(response{Error: "Only POST is accepted"})
+			return
+		}
+
+		fitness, err := evaluate(r, dcode, eval)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response{Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(response{Fitness: fitness})
+	})
+	return mux
+}
+
+// Serves the evaluator as a worker on the given address, returning only when
+// the server fails
+func ServeEvaluator(addr string, dcode neat.Decoder, eval neat.OrgEval) error {
+	return http.ListenAndServe(addr, Handler(dcode, eval))
+}
+
+// Decodes and evaluates the genomes of the request
+func evaluate(r *http.Request, dcode neat.Decoder, eval neat.OrgEval) (fitness [][]float64, err error) {
+
+	var req request
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return
+	}
+
+	// Decode the organisms
+	orgs := make([]*neat.Organism, len(req.Genomes))
+	for i, g := range req.Genomes {
+		orgs[i] = &neat.Organism{Genome: g}
+		orgs[i].Phenome, err = dcode.Decode(g)
+		if err != nil {
+			return
+		}
+	}
+
+	// Evaluate them
+	if be, ok := eval.(neat.BatchEval); ok {
+		err = neat.EvaluateBatch(be, orgs)
+	} else {
+		for _, o := range orgs {
+			if err = eval.Evaluate(o); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	fitness = make([][]float64, len(orgs))
+	for i, o := range orgs {
+		fitness[i] = o.Fitness
+	}
+	return
+}
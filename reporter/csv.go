@@ -0,0 +1,96 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package reporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"github.com/boggo/neat"
+	"io"
+	"strconv"
+)
+
+// Columns written by the CSV logger, in order
+//
+//	generation       Generation number
+//	best_fitness     Fitness of the best organism
+//	mean_fitness     Mean fitness of the evaluated organisms
+//	species          Number of species
+//	mpc              Mean population complexity
+//	elapsed_seconds  Wall-clock time taken by the generation
+//	evaluations      Organisms evaluated during the generation
+var csvColumns = []string{"generation", "best_fitness", "mean_fitness", "species", "mpc",
+	"elapsed_seconds", "evaluations"}
+
+// Statistics collector which appends one CSV row per generation
+type csvLogger struct {
+	buf    *bufio.Writer
+	out    *csv.Writer
+	header bool // Has the header been written?
+}
+
+// Returns a new statistics collector writing CSV rows to w. The header is
+// written with the first row and the output is flushed after every
+// generation.
+func NewCSV(w io.Writer) neat.StatsCollector {
+	buf := bufio.NewWriter(w)
+	return &csvLogger{buf: buf, out: csv.NewWriter(buf)}
+}
+
+func (l *csvLogger) Collect(stats *neat.Stats) (err error) {
+
+	// Start with the header
+	if !l.header {
+		err = l.out.Write(csvColumns)
+		if err != nil {
+			return
+		}
+		l.header = true
+	}
+
+	// Write the row
+	err = l.out.Write([]string{
+		strconv.Itoa(stats.Generation),
+		strconv.FormatFloat(stats.BestFitness, 'g', -1, 64),
+		strconv.FormatFloat(stats.MeanFitness, 'g', -1, 64),
+		strconv.Itoa(stats.SpeciesCount),
+		strconv.FormatFloat(stats.MPC, 'g', -1, 64),
+		strconv.FormatFloat(stats.Elapsed.Seconds(), 'f', 6, 64),
+		strconv.Itoa(stats.Evaluations)})
+	if err != nil {
+		return
+	}
+
+	// Flush so the row can be seen immediately
+	l.out.Flush()
+	err = l.out.Error()
+	if err != nil {
+		return
+	}
+	err = l.buf.Flush()
+	return
+}
@@ -0,0 +1,276 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+)
+
+// Size of the charts and network drawing
+const (
+	chartWidth  = 640
+	chartHeight = 240
+	chartMargin = 30
+)
+
+// Colours cycled through for the species
+var speciesColours = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1",
+	"#ff9da7", "#9c755f", "#bab0ac"}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>NEAT run report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+svg { border: 1px solid #ccc; background: #fff; }
+pre { background: #f4f4f4; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>NEAT run report</h1>
+<p>{{.Generations}} generations. Best fitness {{printf "%.6g" .Best}}.</p>
+<h2 id="fitness">Fitness</h2>
+{{.Fitness}}
+<h2 id="species">Species</h2>
+{{.Species}}
+<h2 id="champion">Champion</h2>
+{{if .Champion}}<p>Organism {{.ChampionID}} with {{.Nodes}} nodes and {{.Conns}} connections.</p>
+{{.Champion}}{{else}}<p>No champion.</p>{{end}}
+<h2 id="settings">Settings</h2>
+<pre>{{.Settings}}</pre>
+</body>
+</html>
+`))
+
+// Writes a self-contained HTML page describing a run: charts of the fitness
+// and species sizes over the generations, the champion's network and the
+// settings. The charts are inline SVG, so the page needs nothing else to
+// display.
+func WriteHTMLReport(w io.Writer, history []neat.Stats, champion *neat.Organism, settings *neat.Settings) (err error) {
+	data := struct {
+		Generations int
+		Best        float64
+		Fitness     template.HTML
+		Species     template.HTML
+		Champion    template.HTML
+		ChampionID  int
+		Nodes       int
+		Conns       int
+		Settings    string
+	}{Generations: len(history), Fitness: fitnessChart(history), Species: speciesChart(history)}
+
+	for i, s := range history {
+		if i == 0 || s.BestFitness > data.Best {
+			data.Best = s.BestFitness
+		}
+	}
+	if champion != nil {
+		data.Champion = networkSVG(champion.Genome)
+		data.ChampionID = champion.ID
+		data.Nodes, data.Conns = len(champion.Nodes), len(champion.Conns)
+	}
+	if settings != nil {
+		var b []byte
+		if b, err = json.MarshalIndent(settings, "", "  "); err != nil {
+			return
+		}
+		data.Settings = string(b)
+	}
+	return htmlReport.Execute(w, data)
+}
+
+// Returns an SVG line chart of the best and mean fitness
+func fitnessChart(history []neat.Stats) template.HTML {
+	best := make([]float64, len(history))
+	mean := make([]float64, len(history))
+	for i, s := range history {
+		best[i], mean[i] = s.BestFitness, s.MeanFitness
+	}
+	lo, hi := min(append(mean, best...)), max(append(mean, best...))
+	if len(history) == 0 {
+		lo, hi = 0, 1
+	}
+
+	var b bytes.Buffer
+	svgOpen(&b, chartWidth, chartHeight)
+	axes(&b, lo, hi, len(history))
+	for _, l := range []struct {
+		name   string
+		colour string
+		ys     []float64
+	}{{"best", "#e15759", best}, {"mean", "#4e79a7", mean}} {
+		fmt.Fprintf(&b, `<polyline class="%s" fill="none" stroke="%s" stroke-width="2" points="`, l.name, l.colour)
+		for i, y := range l.ys {
+			fmt.Fprintf(&b, "%.1f,%.1f ", chartX(i, len(l.ys)), chartY(y, lo, hi))
+		}
+		b.WriteString("\"/>\n")
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#e15759">best</text><text x="%d" y="15" fill="#4e79a7">mean</text>`+"\n",
+		chartMargin, chartMargin+50)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// Returns an SVG stacked-area chart of the species sizes
+func speciesChart(history []neat.Stats) template.HTML {
+
+	// Gather the sizes of every species, in ascending ID order
+	sizes := make(map[int][]float64)
+	total := float64(0)
+	for g, s := range history {
+		n := float64(0)
+		for _, ss := range s.Species {
+			if sizes[ss.ID] == nil {
+				sizes[ss.ID] = make([]float64, len(history))
+			}
+			sizes[ss.ID][g] = float64(ss.Size)
+			n += float64(ss.Size)
+		}
+		total = math.Max(total, n)
+	}
+	ids := make([]int, 0, len(sizes))
+	for id := range sizes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if total == 0 {
+		total = 1
+	}
+
+	// Stack the areas
+	var b bytes.Buffer
+	svgOpen(&b, chartWidth, chartHeight)
+	axes(&b, 0, total, len(history))
+	base := make([]float64, len(history))
+	for k, id := range ids {
+		fmt.Fprintf(&b, `<polygon class="species" data-id="%d" fill="%s" stroke="none" points="`, id,
+			speciesColours[k%len(speciesColours)])
+		for g := range history {
+			fmt.Fprintf(&b, "%.1f,%.1f ", chartX(g, len(history)), chartY(base[g]+sizes[id][g], 0, total))
+		}
+		for g := len(history) - 1; g >= 0; g-- {
+			fmt.Fprintf(&b, "%.1f,%.1f ", chartX(g, len(history)), chartY(base[g], 0, total))
+			base[g] += sizes[id][g]
+		}
+		b.WriteString("\"/>\n")
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// Returns an SVG drawing of the network, placing each node by its position
+// in the genome: inputs along the bottom and outputs along the top. Enabled
+// connections are drawn red for positive weights and blue for negative ones,
+// thicker for larger weights.
+func networkSVG(g *neat.Genome) template.HTML {
+	const w, h, r = chartWidth, 320, 8
+	x := func(ng *neat.NodeGene) float64 { return chartMargin + ng.X*(w-2*chartMargin) }
+	y := func(ng *neat.NodeGene) float64 { return h - chartMargin - ng.Y*(h-2*chartMargin) }
+
+	var b bytes.Buffer
+	svgOpen(&b, w, h)
+	ck := make([]int, 0, len(g.Conns))
+	for k := range g.Conns {
+		ck = append(ck, k)
+	}
+	sort.Ints(ck)
+	for _, k := range ck {
+		cg := g.Conns[k]
+		src, ok1 := g.Nodes[cg.Source]
+		tgt, ok2 := g.Nodes[cg.Target]
+		if !cg.Enabled || !ok1 || !ok2 {
+			continue
+		}
+		colour := "#e15759"
+		if cg.Weight < 0 {
+			colour = "#4e79a7"
+		}
+		fmt.Fprintf(&b, `<line class="conn" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.2f"/>`+"\n",
+			x(src), y(src), x(tgt), y(tgt), colour, 0.5+math.Min(math.Abs(cg.Weight), 4))
+	}
+	nk := make([]int, 0, len(g.Nodes))
+	for k := range g.Nodes {
+		nk = append(nk, k)
+	}
+	sort.Ints(nk)
+	for _, k := range nk {
+		ng := g.Nodes[k]
+		fill := "#bab0ac"
+		switch ng.Type {
+		case neural.BIAS:
+			fill = "#edc948"
+		case neural.INPUT:
+			fill = "#59a14f"
+		case neural.OUTPUT:
+			fill = "#f28e2b"
+		}
+		fmt.Fprintf(&b, `<circle class="node" cx="%.1f" cy="%.1f" r="%d" fill="%s" stroke="#333"><title>%s</title></circle>`+"\n",
+			x(ng), y(ng), r, fill, template.HTMLEscapeString(ng.String()))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+func svgOpen(b *bytes.Buffer, w, h int) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", w, h, w, h)
+}
+
+// Draws the axes of a chart with the range of values on the left and the
+// number of generations along the bottom
+func axes(b *bytes.Buffer, lo, hi float64, n int) {
+	fmt.Fprintf(b, `<path class="axes" d="M%d %d V%d H%d" stroke="#333" fill="none"/>`+"\n",
+		chartMargin, chartMargin, chartHeight-chartMargin, chartWidth-chartMargin)
+	fmt.Fprintf(b, `<text x="2" y="%d" font-size="10">%.3g</text><text x="2" y="%d" font-size="10">%.3g</text>`+"\n",
+		chartMargin+4, hi, chartHeight-chartMargin, lo)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="10">%d</text>`+"\n", chartWidth-chartMargin, chartHeight-10, n)
+}
+
+// Returns the horizontal position of the ith of n points
+func chartX(i, n int) float64 {
+	if n < 2 {
+		return chartMargin
+	}
+	return chartMargin + float64(i)*float64(chartWidth-2*chartMargin)/float64(n-1)
+}
+
+// Returns the vertical position of a value within the range
+func chartY(v, lo, hi float64) float64 {
+	if hi <= lo {
+		return chartHeight - chartMargin
+	}
+	return chartHeight - chartMargin - (v-lo)/(hi-lo)*float64(chartHeight-2*chartMargin)
+}
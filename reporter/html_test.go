@@ -0,0 +1,125 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package reporter
+
+import (
+	"bytes"
+	"flag"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// Compares the output with the golden file in testdata, rewriting the file
+// instead with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s, run with -update to accept it:\n%s", path, got)
+	}
+}
+
+var (
+	htmlTag   = regexp.MustCompile(`<(/?[a-zA-Z0-9!]+)([^>]*)>`)
+	htmlAttrs = regexp.MustCompile(`\b(class|id|data-id)="[^"]*"`)
+)
+
+// Returns the structure of an HTML page: its tags, one to a line, with only
+// the attributes naming them, leaving out the coordinates and text
+func skeleton(page string) []byte {
+	var b bytes.Buffer
+	for _, m := range htmlTag.FindAllStringSubmatch(page, -1) {
+		b.WriteString("<" + m[1])
+		for _, a := range htmlAttrs.FindAllString(m[2], -1) {
+			b.WriteString(" " + a)
+		}
+		b.WriteString(">\n")
+	}
+	return b.Bytes()
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	history := []neat.Stats{
+		{Generation: 1, BestFitness: 1, MeanFitness: 0.5, Species: []neat.SpeciesStats{{ID: 1, Size: 10}}},
+		{Generation: 2, BestFitness: 2, MeanFitness: 1, Species: []neat.SpeciesStats{{ID: 1, Size: 6}, {ID: 2, Size: 4}}},
+		{Generation: 3, BestFitness: 1.5, MeanFitness: 1.25, Species: []neat.SpeciesStats{{ID: 2, Size: 10}}},
+	}
+	g := &neat.Genome{ID: 7, Nodes: neat.NodeGeneMap{
+		1: {Marker: 1, Type: neural.BIAS, X: 0, Y: 0},
+		2: {Marker: 2, Type: neural.INPUT, X: 1, Y: 0},
+		3: {Marker: 3, Type: neural.OUTPUT, X: 0.5, Y: 1},
+		4: {Marker: 4, Type: neural.HIDDEN, X: 0.5, Y: 0.5},
+	}, Conns: neat.ConnGeneMap{
+		1: {Marker: 1, Source: 1, Target: 3, Weight: 1, Enabled: true},
+		2: {Marker: 2, Source: 2, Target: 4, Weight: -2, Enabled: true},
+		3: {Marker: 3, Source: 4, Target: 3, Weight: 0.5, Enabled: true},
+		4: {Marker: 4, Source: 2, Target: 3, Weight: 3},
+	}}
+	s := neat.SettingsForXOR()
+	s.PopulationSize = 10
+
+	var b bytes.Buffer
+	if err := WriteHTMLReport(&b, history, &neat.Organism{Genome: g}, s); err != nil {
+		t.Fatal(err)
+	}
+	page := html.UnescapeString(b.String())
+	checkGolden(t, "report.html.golden", skeleton(page))
+	for _, want := range []string{"3 generations. Best fitness 2.", "Organism 7 with 4 nodes and 4 connections.",
+		`"PopulationSize": 10`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Report lacks %q", want)
+		}
+	}
+
+	// A run without a champion or settings still makes a page
+	b.Reset()
+	if err := WriteHTMLReport(&b, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report-empty.html.golden", skeleton(b.String()))
+}
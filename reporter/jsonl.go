@@ -0,0 +1,63 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/boggo/neat"
+	"io"
+)
+
+// Statistics collector which appends one JSON object per generation. Each
+// line holds the fields of neat.Stats, including the per-species breakdown,
+// with Elapsed expressed in seconds.
+type jsonlLogger struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// Returns a new statistics collector writing JSON lines to w. The output is
+// flushed after every generation.
+func NewJSONL(w io.Writer) neat.StatsCollector {
+	buf := bufio.NewWriter(w)
+	return &jsonlLogger{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+type jsonlLine struct {
+	*neat.Stats
+	Elapsed float64 // Seconds, replaces the Duration of neat.Stats
+}
+
+func (l *jsonlLogger) Collect(stats *neat.Stats) (err error) {
+	err = l.enc.Encode(jsonlLine{Stats: stats, Elapsed: stats.Elapsed.Seconds()})
+	if err != nil {
+		return
+	}
+	err = l.buf.Flush()
+	return
+}
@@ -0,0 +1,71 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package reporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"github.com/boggo/neat"
+	"io"
+	"strconv"
+)
+
+// Writes the species history as a wide CSV suitable for a stacked-area
+// chart: one row per generation, headed "generation", and one column per
+// species ever seen, headed "species_<ID>" in ascending ID order.
+func WriteSpeciesSizes(w io.Writer, h *neat.SpeciesHistory) (err error) {
+	buf := bufio.NewWriter(w)
+	out := csv.NewWriter(buf)
+
+	// Write the header
+	ids := h.IDs()
+	row := make([]string, len(ids)+1)
+	row[0] = "generation"
+	for i, id := range ids {
+		row[i+1] = "species_" + strconv.Itoa(id)
+	}
+	if err = out.Write(row); err != nil {
+		return
+	}
+
+	// Write a row for each generation
+	for g, gen := range h.Generations {
+		row[0] = strconv.Itoa(gen)
+		for i, id := range ids {
+			row[i+1] = strconv.Itoa(h.Sizes[id][g])
+		}
+		if err = out.Write(row); err != nil {
+			return
+		}
+	}
+
+	out.Flush()
+	if err = out.Error(); err != nil {
+		return
+	}
+	return buf.Flush()
+}
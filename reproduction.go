@@ -0,0 +1,166 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+// A scheme for breeding the next generation. Reproduce is handed the
+// evaluated current population, whose species' fitness is up to date, and
+// returns the children. The next population starts with every current
+// species carried forward without organisms; a scheme may change which are
+// carried, but the children are speciated into them, the species left empty
+// pruned and the statistics collected by the run whatever the scheme. New
+// genomes take their IDs and markers from the context's Innovation, as
+// through Breed, and random choices are drawn from its Random so that seeded
+// runs repeat. Only NEATReproduction tallies Stats.Reproduction.
+type Reproduction interface {
+	Reproduce(rc *ReproductionContext) (children OrganismSlice, err error)
+}
+
+// What a Reproduction is handed for breeding one generation
+type ReproductionContext struct {
+	Settings   *Settings
+	Innovation *innovation // Tracker handing out IDs and markers
+	Random     *rng        // Generator for the scheme's random choices
+	Current    *Population // The evaluated population
+	Next       *Population // The next generation, holding the species carried forward
+	Size       int         // Children to breed, by Population.Resize or Settings.PopulationSize
+}
+
+// Tallies of how the children of one or more species were made
+type ReproductionCounts struct {
+	Elites       int // Carried over unchanged
+	Crossover    int // Mated with a member of the same species
+	Interspecies int // Mated with a member of the whole population
+	MutationOnly int // Mutated copies of a single parent
+	Filler       int // Made by the loop filling out the population
+}
+
+// Account of how the current generation was made from the previous one.
+// Each child is counted once under the species of its (first) parent.
+// Structural mutations are counted as they were selected, whether or not
+// they found anything to change.
+type ReproductionSummary struct {
+	Total         ReproductionCounts
+	Species       map[int]ReproductionCounts `json:",omitempty"` // Keyed by the parent's species
+	AddNode       int                        // Add node mutations
+	AddConnection int                        // Add connection mutations
+	DelNode       int                        // Delete node mutations
+	DelConnection int                        // Delete connection mutations
+}
+
+// The ways a child comes about
+type birthKind int
+
+const (
+	bornElite birthKind = iota
+	bornCrossover
+	bornInterspecies
+	bornMutation
+	bornFiller
+)
+
+// The structural mutations
+type structMutation int
+
+const (
+	mutatedWeights structMutation = iota
+	mutatedAddNode
+	mutatedAddConn
+	mutatedDelNode
+	mutatedDelConn
+)
+
+// Record of how a single child was made
+type birth struct {
+	species  int
+	kind     birthKind
+	mutation structMutation
+}
+
+// Records a child, taking the structural mutation last selected
+func (c *rollCounters) born(species int, kind birthKind) {
+	b := birth{species: species, kind: kind}
+	if kind != bornElite {
+		b.mutation = c.mutation
+	}
+	c.births = append(c.births, b)
+}
+
+// Records a child made by mating or mutation, which counts as mutation only
+// if it has a single parent
+func (c *rollCounters) bred(species int, child *Organism, interspecies bool) {
+	switch {
+	case len(child.Parents) < 2:
+		c.born(species, bornMutation)
+	case interspecies:
+		c.born(species, bornInterspecies)
+	default:
+		c.born(species, bornCrossover)
+	}
+}
+
+// Adds the tallies of another set of counters
+func (c *rollCounters) merge(other *rollCounters) {
+	c.capHits += other.capHits
+	c.births = append(c.births, other.births...)
+}
+
+// Tallies the recorded births
+func (c *rollCounters) summary() (rs ReproductionSummary) {
+	if len(c.births) == 0 {
+		return
+	}
+	rs.Species = make(map[int]ReproductionCounts)
+	for _, b := range c.births {
+		sc := rs.Species[b.species]
+		for _, rc := range []*ReproductionCounts{&rs.Total, &sc} {
+			switch b.kind {
+			case bornElite:
+				rc.Elites += 1
+			case bornCrossover:
+				rc.Crossover += 1
+			case bornInterspecies:
+				rc.Interspecies += 1
+			case bornMutation:
+				rc.MutationOnly += 1
+			case bornFiller:
+				rc.Filler += 1
+			}
+		}
+		rs.Species[b.species] = sc
+		switch b.mutation {
+		case mutatedAddNode:
+			rs.AddNode += 1
+		case mutatedAddConn:
+			rs.AddConnection += 1
+		case mutatedDelNode:
+			rs.DelNode += 1
+		case mutatedDelConn:
+			rs.DelConnection += 1
+		}
+	}
+	return
+}
@@ -0,0 +1,122 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"testing"
+)
+
+// Adds up the counts
+func (rc ReproductionCounts) total() int {
+	return rc.Elites + rc.Crossover + rc.Interspecies + rc.MutationOnly + rc.Filler
+}
+
+// The summary of each roll accounts for every child exactly, whether the
+// species are bred one after another or in parallel
+func TestReproductionSummary(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		s := testSettings()
+		s.PopulationSize = 100
+		s.CompatThreshold = 1
+		s.InterspeciesMating = 0.2
+		s.MutateAddConnection, s.MutateAddNode = 0.3, 0.1
+		s.ReproductionWorkers = workers
+		pop, inno := evaluatedPopulation(t, s)
+		var seen ReproductionCounts
+		for gen := 0; gen < 15; gen++ {
+			prev := make(map[*Organism]bool)
+			species := make(map[int]bool)
+			for _, sp := range pop.Species {
+				species[sp.ID] = true
+				for _, o := range sp.Orgs {
+					prev[o] = true
+				}
+			}
+			next := rollTest(t, s, inno, pop, 1)
+			rs := newStats(next, 0, 0).Reproduction
+			children := next.Organisms()
+
+			// The counts add up, overall and over the species
+			if n := rs.Total.total(); n != len(children) {
+				t.Fatalf("%d workers generation %d: counted %d children of %d: %+v", workers, next.Generation, n,
+					len(children), rs.Total)
+			}
+			var sum ReproductionCounts
+			for id, sc := range rs.Species {
+				if !species[id] {
+					t.Errorf("%d workers generation %d: counted children of species %d, not in the parents",
+						workers, next.Generation, id)
+				}
+				sum.Elites += sc.Elites
+				sum.Crossover += sc.Crossover
+				sum.Interspecies += sc.Interspecies
+				sum.MutationOnly += sc.MutationOnly
+				sum.Filler += sc.Filler
+			}
+			if sum != rs.Total {
+				t.Errorf("%d workers generation %d: species counts add to %+v, total %+v", workers,
+					next.Generation, sum, rs.Total)
+			}
+
+			// And agree with the children. The elite are the parents carried
+			// over; a filler child may have one parent or two.
+			elites, mated := 0, 0
+			for _, o := range children {
+				switch {
+				case prev[o]:
+					elites += 1
+				case len(o.Parents) > 1:
+					mated += 1
+				}
+			}
+			if elites != rs.Total.Elites {
+				t.Errorf("%d workers generation %d: %d elites, counted %d", workers, next.Generation, elites,
+					rs.Total.Elites)
+			}
+			if bred := rs.Total.Crossover + rs.Total.Interspecies; mated < bred || mated > bred+rs.Total.Filler {
+				t.Errorf("%d workers generation %d: %d mated children, counted %+v", workers, next.Generation,
+					mated, rs.Total)
+			}
+			if structural := rs.AddNode + rs.AddConnection + rs.DelNode + rs.DelConnection; structural >
+				len(children)-elites {
+				t.Errorf("%d workers generation %d: %d structural mutations for %d bred children", workers,
+					next.Generation, structural, len(children)-elites)
+			}
+			seen.Elites += rs.Total.Elites
+			seen.Crossover += rs.Total.Crossover
+			seen.Interspecies += rs.Total.Interspecies
+			seen.MutationOnly += rs.Total.MutationOnly
+			seen.Filler += rs.Total.Filler
+			pop = next
+		}
+		inno.close()
+		if seen.Elites == 0 || seen.Crossover == 0 || seen.Interspecies == 0 || seen.MutationOnly == 0 ||
+			seen.Filler == 0 {
+			t.Errorf("%d workers: some ways of making a child never came up: %+v", workers, seen)
+		}
+	}
+}
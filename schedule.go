@@ -0,0 +1,158 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+)
+
+// Schedule moving one of the settings' rates from a start value to an end
+// value over the run. The rate starts at Start for the first generation,
+// or before any evaluation, and reaches End after Length generations or
+// evaluations, staying there. A linear curve moves the rate by equal steps;
+// an exponential one by equal factors, which needs both ends positive.
+type RateSchedule struct {
+	Rate   string  // Name of the rate, as the field of Settings such as "MutateAddNode"
+	Start  float64 // Value at the beginning of the run
+	End    float64 // Value once the schedule is done
+	Curve  string  // "linear" (the default) or "exponential"
+	Over   string  // "generations" (the default) or "evaluations" made in this run
+	Length int     // Generations or evaluations taken to reach End
+}
+
+// Returns the scheduled value after the given progress, counted in
+// generations or evaluations as the schedule says
+func (rs RateSchedule) at(progress int) float64 {
+	t := math.Min(float64(progress)/float64(rs.Length), 1)
+	if rs.Curve == "exponential" {
+		return rs.Start * math.Pow(rs.End/rs.Start, t)
+	}
+	return rs.Start + (rs.End-rs.Start)*t
+}
+
+// Returns the rate of the settings with the given name, or nil if it cannot
+// be scheduled
+func (s *Settings) rateField(name string) *float64 {
+	switch name {
+	case "MutateWeight":
+		return &s.MutateWeight
+	case "MutateWeightNew":
+		return &s.MutateWeightNew
+	case "MutateEnabled":
+		return &s.MutateEnabled
+	case "MutateAddConnection":
+		return &s.MutateAddConnection
+	case "MutateAddNode":
+		return &s.MutateAddNode
+	case "MutateDelNode":
+		return &s.MutateDelNode
+	case "MutateDelConnection":
+		return &s.MutateDelConnection
+	case "MutateResponse":
+		return &s.MutateResponse
+	case "Crossover":
+		return &s.Crossover
+	case "MateAveragingProb":
+		return &s.MateAveragingProb
+	case "InterspeciesMating":
+		return &s.InterspeciesMating
+	}
+	return nil
+}
+
+// Checks the schedules
+func (s *Settings) validateSchedules() error {
+	seen := make(map[string]bool, len(s.Schedules))
+	for _, rs := range s.Schedules {
+		switch {
+		case s.rateField(rs.Rate) == nil:
+			return fmt.Errorf("Rate %q cannot be scheduled", rs.Rate)
+		case seen[rs.Rate]:
+			return fmt.Errorf("Rate %s has more than one schedule", rs.Rate)
+		case rs.Curve != "" && rs.Curve != "linear" && rs.Curve != "exponential":
+			return fmt.Errorf("Unknown schedule curve %q for %s", rs.Curve, rs.Rate)
+		case rs.Over != "" && rs.Over != "generations" && rs.Over != "evaluations":
+			return fmt.Errorf("Unknown schedule span %q for %s", rs.Over, rs.Rate)
+		case rs.Length < 1:
+			return fmt.Errorf("The schedule for %s must have a positive Length", rs.Rate)
+		case rs.Start < 0 || rs.Start > 1 || rs.End < 0 || rs.End > 1:
+			return fmt.Errorf("The schedule for %s must stay between 0 and 1", rs.Rate)
+		case rs.Curve == "exponential" && (rs.Start == 0 || rs.End == 0):
+			return fmt.Errorf("The exponential schedule for %s needs positive ends", rs.Rate)
+		}
+		seen[rs.Rate] = true
+	}
+	return nil
+}
+
+// Returns the scheduled value of the named rate for the generation, having
+// made the given evaluations, or base if the rate has no schedule
+func (s *Settings) scheduled(name string, base float64, generation, evals int) float64 {
+	for _, rs := range s.Schedules {
+		if rs.Rate != name {
+			continue
+		}
+		if rs.Over == "evaluations" {
+			return rs.at(evals)
+		}
+		return rs.at(generation - 1)
+	}
+	return base
+}
+
+// Sets each scheduled rate for breeding the generation, having made the
+// given evaluations
+func (s *Settings) applySchedules(generation, evals int) {
+	for _, rs := range s.Schedules {
+		f := s.rateField(rs.Rate)
+		*f = s.scheduled(rs.Rate, *f, generation, evals)
+	}
+}
+
+// Returns the current values of the scheduled rates, or nil if there are
+// no schedules
+func (s *Settings) scheduledRates() map[string]float64 {
+	if len(s.Schedules) == 0 {
+		return nil
+	}
+	rates := make(map[string]float64, len(s.Schedules))
+	for _, rs := range s.Schedules {
+		rates[rs.Rate] = *s.rateField(rs.Rate)
+	}
+	return rates
+}
+
+// Returns a function restoring the scheduled rates to their current values
+func (s *Settings) saveRates() func() {
+	saved := s.scheduledRates()
+	return func() {
+		for name, v := range saved {
+			*s.rateField(name) = v
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Selector picks a parent for reproduction from a pool of organisms,
+// returning nil if it cannot pick one
+type Selector interface {
+	Select(orgs OrganismSlice) *Organism
+}
+
+// Returns the selector configured by the settings for the given generation,
+// drawing from the settings' random number generator
+func newSelector(settings *Settings, generation int) (sel Selector, err error) {
+	r := settings.rand()
+	switch settings.Selection {
+	case "", "roulette":
+		sel = &rouletteSelector{rng: r}
+	case "rank":
+		p := settings.SelectionPressure
+		if p == 0 {
+			p = 1.5
+		}
+		sel = &rankSelector{pressure: p, cache: weightCache{rng: r, version: &settings.fitVer}}
+	case "boltzmann":
+		t := settings.BoltzmannTemperature
+		if settings.BoltzmannDecay > 0 {
+			t *= math.Pow(settings.BoltzmannDecay, float64(generation-1))
+		}
+		if t < 1e-6 {
+			t = 1e-6 // Keep exp() finite once annealed
+		}
+		sel = &boltzmannSelector{temperature: t, cache: weightCache{rng: r, version: &settings.fitVer}}
+	case "lexicase":
+		sel = &lexicaseSelector{rng: r, epsilon: settings.LexicaseEpsilon}
+	default:
+		err = fmt.Errorf("Unknown Selection %q", settings.Selection)
+	}
+	return
+}
+
+// Sets the fitness the selectors work with. This is the raw fitness or, with
+// Settings.SigmaScaling, the fitness scaled within the pool as
+//
+//	1 + (f - mean)/(2 stddev)
+//
+// floored at a small positive value. The raw Fitness is left untouched.
+func setSelectionFitness(settings *Settings, orgs OrganismSlice) {
+	settings.fitVer++
+	if !settings.SigmaScaling {
+		for _, o := range orgs {
+			o.selFit = o.Fitness[0]
+		}
+		return
+	}
+
+	// Find the mean and standard deviation of the pool
+	n := float64(len(orgs))
+	mean, sd := float64(0), float64(0)
+	for _, o := range orgs {
+		mean += o.Fitness[0]
+	}
+	mean /= n
+	for _, o := range orgs {
+		sd += (o.Fitness[0] - mean) * (o.Fitness[0] - mean)
+	}
+	sd = math.Sqrt(sd / n)
+
+	// Scale the fitness
+	for _, o := range orgs {
+		if sd == 0 {
+			o.selFit = 1
+			continue
+		}
+		o.selFit = 1 + (o.Fitness[0]-mean)/(2*sd)
+		if o.selFit < 0.1 {
+			o.selFit = 0.1
+		}
+	}
+}
+
+// Roulette-wheel selection
+type rouletteSelector struct {
+	rng *rng
+}
+
+func (r *rouletteSelector) Select(orgs OrganismSlice) *Organism {
+	sum := float64(0)
+	for _, o := range orgs {
+		sum += o.selFit
+	}
+	return tournament(r.rng, orgs, sum)
+}
+
+// Linear rank-based selection. With n organisms ranked from worst (0) to best
+// (n-1), rank i is picked with probability
+//
+//	(2 - p)/n + 2i(p - 1)/(n(n - 1))
+//
+// where the pressure p is between 1 (uniform) and 2.
+type rankSelector struct {
+	pressure float64
+	cache    weightCache
+}
+
+func (r *rankSelector) Select(orgs OrganismSlice) *Organism {
+	return r.cache.pick(orgs, func(sorted OrganismSlice) []float64 {
+		n := float64(len(sorted))
+		w := make([]float64, len(sorted))
+		for i := range sorted {
+			if n == 1 {
+				w[i] = 1
+			} else {
+				w[i] = (2-r.pressure)/n + 2*float64(i)*(r.pressure-1)/(n*(n-1))
+			}
+		}
+		return w
+	})
+}
+
+// Boltzmann selection, picking organisms with probability proportional to
+// exp(fitness / temperature)
+type boltzmannSelector struct {
+	temperature float64
+	cache       weightCache
+}
+
+func (b *boltzmannSelector) Select(orgs OrganismSlice) *Organism {
+	return b.cache.pick(orgs, func(sorted OrganismSlice) []float64 {
+		w := make([]float64, len(sorted))
+		max := sorted[len(sorted)-1].selFit // Shift by the best to avoid overflow
+		for i, o := range sorted {
+			w[i] = math.Exp((o.selFit - max) / b.temperature)
+		}
+		return w
+	})
+}
+
+// Lexicase selection. The test cases are taken in a fresh random order for
+// each pick, and on each case only the organisms best on it, to within the
+// epsilon, are kept. The pick is made at random from those left once one
+// remains or the cases run out, which they may well do in a pool smaller
+// than the number of cases. Only the cases every organism was scored on are
+// used.
+type lexicaseSelector struct {
+	rng     *rng
+	epsilon float64
+}
+
+func (l *lexicaseSelector) Select(orgs OrganismSlice) *Organism {
+	if len(orgs) == 0 {
+		return nil
+	}
+	n := len(orgs[0].Cases)
+	for _, o := range orgs[1:] {
+		if len(o.Cases) < n {
+			n = len(o.Cases)
+		}
+	}
+
+	// Shuffle the cases
+	order := make([]int, n)
+	for i := range order {
+		j := l.rng.Int(i + 1)
+		order[i] = order[j]
+		order[j] = i
+	}
+
+	// Filter the candidates case by case
+	cands := append(OrganismSlice(nil), orgs...)
+	for _, c := range order {
+		if len(cands) == 1 {
+			break
+		}
+		best := math.Inf(-1)
+		for _, o := range cands {
+			if o.Cases[c] > best {
+				best = o.Cases[c]
+			}
+		}
+		kept := cands[:0]
+		for _, o := range cands {
+			if o.Cases[c] >= best-l.epsilon {
+				kept = append(kept, o)
+			}
+		}
+		cands = kept
+	}
+	return cands[l.rng.Int(len(cands))]
+}
+
+// Remembers the selection weights of the most recent pool. Parents are
+// drawn from the same pool many times in a row, so this saves sorting the
+// pool and computing the weights on every draw. The weights are worked out
+// again for a pool held in a different slice, or once the selection fitness
+// has been set since. A pool must not be changed in place between draws.
+type weightCache struct {
+	rng     *rng          // Generator spinning the wheel
+	version *int          // Version of the selection fitness, see Settings.fitVer
+	seen    int           // Version the weights were computed at
+	base    **Organism    // Start of the pool's backing array
+	n       int           // Size of the pool
+	sorted  OrganismSlice // The pool in ascending order of selection fitness
+	weights []float64     // Cumulative weights of the sorted pool
+}
+
+func (c *weightCache) pick(orgs OrganismSlice, weigh func(sorted OrganismSlice) []float64) *Organism {
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	// Refresh the weights if the pool has changed
+	ver := 0
+	if c.version != nil {
+		ver = *c.version
+	}
+	if c.base != &orgs[0] || c.n != len(orgs) || c.seen != ver || c.weights == nil {
+		c.base, c.n, c.seen = &orgs[0], len(orgs), ver
+		c.sorted = make(OrganismSlice, len(orgs))
+		copy(c.sorted, orgs)
+		sort.SliceStable(c.sorted, func(i, j int) bool {
+			a, b := c.sorted[i], c.sorted[j]
+			if a.selFit == b.selFit {
+				return a.ID > b.ID
+			}
+			return a.selFit < b.selFit
+		})
+		c.weights = weigh(c.sorted)
+		for i := 1; i < len(c.weights); i++ {
+			c.weights[i] += c.weights[i-1]
+		}
+	}
+
+	// Spin the wheel
+	tgt := c.rng.Next() * c.weights[len(c.weights)-1]
+	i := sort.SearchFloat64s(c.weights, tgt)
+	if i >= len(c.sorted) {
+		i = len(c.sorted) - 1
+	}
+	return c.sorted[i]
+}
@@ -0,0 +1,202 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// Returns a pool of organisms with the fitness given, setting their selection
+// fitness
+func selectionPool(s *Settings, fitness ...float64) OrganismSlice {
+	pool := make(OrganismSlice, len(fitness))
+	for i, f := range fitness {
+		pool[i] = &Organism{Genome: &Genome{ID: i + 1, Fitness: []float64{f}}}
+	}
+	setSelectionFitness(s, pool)
+	return pool
+}
+
+// Draws from the pool many times and checks that each organism is picked
+// about as often as expected, to within four standard errors
+func checkFrequencies(t *testing.T, name string, sel Selector, pool OrganismSlice, want []float64) {
+	t.Helper()
+	const draws = 40000
+	counts := make(map[*Organism]int)
+	for i := 0; i < draws; i++ {
+		counts[sel.Select(pool)]++
+	}
+	for i, o := range pool {
+		got := float64(counts[o]) / draws
+		if se := math.Sqrt(want[i] * (1 - want[i]) / draws); math.Abs(got-want[i]) > 4*se+1e-9 {
+			t.Errorf("%s: organism %d picked %.4f of the time, want %.4f", name, i, got, want[i])
+		}
+	}
+}
+
+func TestSelectionProbabilities(t *testing.T) {
+	fitness := []float64{1, 2, 4, 8}
+
+	// Roulette picks in proportion to fitness
+	s := &Settings{}
+	s.rand().seed(1)
+	sel, _ := newSelector(s, 1)
+	checkFrequencies(t, "roulette", sel, selectionPool(s, fitness...), []float64{1. / 15, 2. / 15, 4. / 15, 8. / 15})
+
+	// Rank with pressure p picks rank i of n with (2-p)/n + 2i(p-1)/(n(n-1))
+	s = &Settings{Selection: "rank", SelectionPressure: 1.8}
+	s.rand().seed(2)
+	sel, _ = newSelector(s, 1)
+	want := make([]float64, 4)
+	for i := range want {
+		want[i] = (2-1.8)/4 + 2*float64(i)*(1.8-1)/(4*3)
+	}
+	checkFrequencies(t, "rank", sel, selectionPool(s, fitness...), want)
+
+	// Boltzmann picks in proportion to exp(f/T), with T annealed by the decay
+	s = &Settings{Selection: "boltzmann", BoltzmannTemperature: 4, BoltzmannDecay: 0.5}
+	s.rand().seed(3)
+	sel, _ = newSelector(s, 2) // T = 2
+	sum := float64(0)
+	for i, f := range fitness {
+		want[i] = math.Exp(f / 2)
+		sum += want[i]
+	}
+	for i := range want {
+		want[i] /= sum
+	}
+	checkFrequencies(t, "boltzmann", sel, selectionPool(s, fitness...), want)
+}
+
+func TestSelectionCacheRefresh(t *testing.T) {
+	s := &Settings{Selection: "rank", SelectionPressure: 2}
+	s.rand().seed(4)
+	sel, _ := newSelector(s, 1)
+
+	// Pools sharing the first organism and size are told apart. Pressure 2
+	// never picks the worst, so the second pool's best must be picked.
+	pool := selectionPool(s, 1, 2)
+	other := OrganismSlice{pool[0], &Organism{Genome: &Genome{ID: 9, Fitness: []float64{0}}}}
+	setSelectionFitness(s, other)
+	sel.Select(pool)
+	for i := 0; i < 100; i++ {
+		if o := sel.Select(other); o != other[0] {
+			t.Fatalf("Picked organism %d from stale weights", o.ID)
+		}
+	}
+
+	// Changed selection fitness is noticed with the pool unchanged
+	pool[0].Fitness[0], pool[1].Fitness[0] = 5, 1
+	setSelectionFitness(s, pool)
+	for i := 0; i < 100; i++ {
+		if o := sel.Select(pool); o != pool[0] {
+			t.Fatalf("Picked organism %d from stale weights", o.ID)
+		}
+	}
+}
+
+// Returns a pool of organisms with the case scores given
+func casePool(cases ...[]float64) OrganismSlice {
+	pool := make(OrganismSlice, len(cases))
+	for i, c := range cases {
+		pool[i] = &Organism{Genome: &Genome{ID: i + 1, Fitness: []float64{0}}, Cases: c}
+	}
+	return pool
+}
+
+func TestLexicaseProbabilities(t *testing.T) {
+	for i, c := range []struct {
+		name    string
+		epsilon float64
+		cases   [][]float64
+		want    []float64
+	}{
+		// The first case keeps A and B, which B wins on the second; the
+		// third alone picks C
+		{"specialist", 0, [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 0, 1}}, []float64{0, 2. / 3, 1. / 3}},
+
+		// A generalist best on no case is never picked
+		{"generalist", 0, [][]float64{{1, 0}, {0, 1}, {.5, .5}}, []float64{.5, .5, 0}},
+
+		// But survives every case within the epsilon, sharing the pick with
+		// the specialist kept by the first case
+		{"epsilon", 0.5, [][]float64{{1, 0}, {0, 1}, {.5, .5}}, []float64{.25, .25, .5}},
+
+		// Fewer organisms than cases, tied on them all
+		{"small", 0, [][]float64{{1, 2, 3, 4, 5}, {1, 2, 3, 4, 5}}, []float64{.5, .5}},
+
+		// Only the cases all were scored on count
+		{"ragged", 0, [][]float64{{0, 1, 9}, {1, 0}}, []float64{.5, .5}},
+	} {
+		s := &Settings{Selection: "lexicase", LexicaseEpsilon: c.epsilon}
+		s.rand().seed(int64(i + 1))
+		sel, err := newSelector(s, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFrequencies(t, c.name, sel, casePool(c.cases...), c.want)
+	}
+}
+
+// Scores each of the first ten markers as a case, as weightEval does
+type caseEval struct{}
+
+func (caseEval) Evaluate(org *Organism) error {
+	org.Cases = make([]float64, 10)
+	f := float64(0)
+	for m := range org.Cases {
+		if c, ok := org.Conns[m+1]; ok && c.Enabled {
+			d := c.Weight - math.Sin(float64(m+1))
+			org.Cases[m] = 1 / (1 + d*d)
+			f += org.Cases[m]
+		}
+	}
+	org.Fitness = []float64{f}
+	return nil
+}
+
+func TestLexicaseTrain(t *testing.T) {
+	s := testSettings()
+	s.Selection = "lexicase"
+	best, pop, err := Train(s, 20, genomeDecoder{}, serialEval{}, caseEval{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pop.Species) < 2 || best.Fitness[0] <= 0 {
+		t.Errorf("Lexicase run ended with %d species and best fitness %v", len(pop.Species), best.Fitness)
+	}
+
+	// An evaluator giving no case scores is refused
+	s = testSettings()
+	s.Selection = "lexicase"
+	_, _, err = Train(s, 2, genomeDecoder{}, serialEval{}, weightEval{}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "case scores") {
+		t.Errorf("Got %v, want an error for the missing case scores", err)
+	}
+}
@@ -26,6 +26,13 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 package neat
 
+import (
+	"fmt"
+	"github.com/boggo/neural"
+	"io"
+	"math"
+)
+
 type Loader interface {
 	Load() (*Settings, error)
 }
@@ -40,6 +47,20 @@ type Settings struct {
 	InputCount  int
 	OutputCount int
 
+	// Optional names of the input and output nodes, in order
+	InputNames  []string
+	OutputNames []string
+
+	// Optional modules of the input and output nodes, in order. "" leaves a
+	// node shared by all modules. ModuleBias is the probability that the add
+	// connection mutation joins nodes of the same module and
+	// ModuleCoefficient, if set, replaces 1 as the weight of disjoint and
+	// excess genes joining different modules in the genome distance.
+	InputModules      []string
+	OutputModules     []string
+	ModuleBias        float64
+	ModuleCoefficient float64
+
 	// Coefficients for calculating distance between genomes
 	ExcessCoefficient   float64
 	DisjointCoefficient float64
@@ -48,6 +69,11 @@ type Settings struct {
 	// Probabilities for mutation
 	MutateWeight        float64
 	MutateWeightNew     float64
+	MaxWeight           float64 // Weights are kept within +/- MaxWeight. 0 = 30
+	InitialWeight       string  // "gaussian(sigma)", "uniform(min,max)" or "xavier" for new weights. "" = gaussian(1)
+	IgnoreFrozen        bool    // Mutate frozen genes as any other
+	MaxNodes            int     // Largest number of node genes in a genome. 0 = no limit
+	MaxConnections      int     // Largest number of connection genes in a genome. 0 = no limit
 	MutateEnabled       float64
 	MutateAddConnection float64
 	MutateAddNode       float64
@@ -55,16 +81,456 @@ type Settings struct {
 	MutateDelNode       float64 // Pruning phase
 	MutateDelConnection float64 // Pruning phase
 	PruneThreshold      float64 // Pruning phase threshold
+	PruneFloor          int     // Generations without a drop in complexity before pruning ends
+
+	// Connections the add connection mutation may create besides those
+	// running forward between different nodes
+	AllowRecurrent       bool // Connections from a higher node to a lower one
+	AllowSelfConnections bool // Connections from a node to itself, with AllowRecurrent
+	ReenableSplit        bool // Let the enable mutation restore a connection disabled by splitting it
+
+	// Node responses, the gain applied to a node's summed input before its
+	// activation function
+	MutateResponse      float64 // Probability that each hidden or output node's response is perturbed
+	ResponsePower       float64 // Deviation of the perturbation. 0 = 0.5
+	MinResponse         float64 // Perturbed responses are kept at or above this. 0 = 0.1
+	MaxResponse         float64 // and at or below this. 0 = 5
+	ResponseCoefficient float64 // Weight of the mean response difference in the distance. 0 = ignored
+
+	// Schedules moving mutation and breeding rates over the run, each
+	// resolved before every generation is bred. Rates without a schedule
+	// keep their value.
+	Schedules []RateSchedule `json:",omitempty"`
+
+	// Self-adaptive mutation. Each genome carries a MutationProfile of
+	// multipliers for the mutation probabilities and weight power which is
+	// perturbed log-normally as it mutates and averaged when it mates.
+	SelfAdaptive     bool
+	SelfAdaptiveRate float64 // Learning rate of the perturbation. 0 = 0.2
 
 	// Crossover and breeding probabilities
-	Crossover          float64
+	Crossover          float64 // Probability that a child has two parents. 0 = mutation only
+	MateAveragingProb  float64 // Probability that a mating averages the weights of matching genes
+	MateEqualRandom    bool    // Equally fit parents pass on each disjoint or excess gene with even odds, instead of all of them
 	InterspeciesMating float64
 	AgeToStagnation    int
+	StagnationMetric   string  // Species fitness which must improve: "mean" (the default), "best" or "median"
+	StagnationEpsilon  float64 // Improvement needed to reset the stagnation clock
+	StagnationGrace    int     // Age before which a species cannot stagnate
+	FitnessHistory     int     // Generations of fitness each species remembers, see Species.History. 0 = none
+	StagnationWindow   bool    // Judge stagnation by improvement over the remembered generations, instead of BestFitAge
+	MinSpeciesSize     int     // Offspring guaranteed to each surviving species
 	SurvivalPercent    float64 // Percent of a species to survive for mating
 	EliteCount         int     // Number within a species to survive into the next generation
+	ElitePercent       float64 // Percent of a species to survive into the next generation, instead of EliteCount
+	EliteSizeFloor     int     // Species no larger than this may keep no elite under ElitePercent
+	GlobalEliteCount   int     // Number of the whole population to survive, instead of per-species elites
 	CompatThreshold    float64 // Compatiblity threshold for adding a genome to a species
+	InheritMeta        bool    // Copy the (fitter) parent's Meta to its offspring instead of clearing it
+	RejectDuplicates   bool    // Mutate a child again, a few times at most, while it duplicates another genome
+	MinMateDistance    float64 // Parents must be at least this far apart. 0 = no limit
+	IncestDepth        int     // Parents may not share an ancestor within this many generations. 0 = no limit
+
+	// Speciation is "genome" (the default), comparing CompatThreshold with the
+	// genome distance, or "behavior", comparing it with the Euclidean distance
+	// between the organisms' Behavior. Behavioral speciation evaluates the
+	// children before they are speciated, as does DeferSpeciation.
+	Speciation      string
+	DeferSpeciation bool
+
+	// Give each species its own compatibility threshold, beginning at
+	// CompatThreshold. A species grown past MaxSpeciesSize has its threshold
+	// tightened by ThresholdStep and is split, and one under half that size
+	// has it relaxed, always within MinCompatThreshold and MaxCompatThreshold.
+	SpeciesThresholds  bool
+	MaxSpeciesSize     int
+	ThresholdStep      float64 // Change to a species' threshold at each adjustment. 0 = a tenth of CompatThreshold
+	MinCompatThreshold float64
+	MaxCompatThreshold float64
+
+	// Keep the whole population in a single species, as suits very small
+	// populations
+	DisableSpeciation bool
+
+	// Parent selection. Selection is "roulette" (the default), "rank",
+	// "boltzmann" or "lexicase", which filters on the organisms' Cases.
+	Selection            string
+	SelectionPressure    float64 // Rank selection pressure between 1 and 2. 0 = 1.5
+	BoltzmannTemperature float64 // Initial Boltzmann temperature
+	BoltzmannDecay       float64 // Factor applied to the temperature each generation. 0 = no annealing
+	LexicaseEpsilon      float64 // Lexicase keeps those within this of the best on a case. 0 = exact
+	SigmaScaling         bool    // Sigma-scale the fitness within each species before selecting
+
+	// Activation functions the decoders give the hidden and output nodes,
+	// "sigmoid" (the default) or "linear". Output nodes always take the
+	// OutputActivation.
+	HiddenActivation string
+	OutputActivation string
+
+	// Handling of NaN or infinite fitness values returned by the evaluator.
+	// They are an error unless ReplaceInvalidFitness is set.
+	ReplaceInvalidFitness bool
+	InvalidFitness        float64 // Replacement for invalid fitness values
+
+	// Elites are evaluated again each generation. With AverageFitness their
+	// fitness is the mean over all their evaluations instead of the latest.
+	AverageFitness bool
+
+	// Noisy fitness. Each organism is evaluated EvaluationRepeats times and
+	// given the mean. With Racing, organisms stop being resampled once clearly
+	// above or below the survival cutoff.
+	EvaluationRepeats int
+	Racing            bool
+	RacingZ           float64 // Standard errors which count as clear. 0 = 1.96
+
+	// Evaluation budget. At most EvaluationBudget organisms are evaluated in
+	// a generation, shared among the species in proportion to their expected
+	// fitness with at least BudgetSpeciesMinimum each. The others are given
+	// the mean fitness of their parents times BudgetDiscount, and marked as
+	// Estimated. Organisms whose parents are unknown, as in the initial
+	// population, are always evaluated. 0 = evaluate every organism
+	EvaluationBudget     int
+	BudgetSpeciesMinimum int
+	BudgetDiscount       float64 // 0 = 1, no discount
+
+	// Goroutines breeding the species in parallel. Each species then draws
+	// from its own random number generator, seeded from the shared one, so a
+	// parallel run is repeatable but differs from a serial one. 0 = breed
+	// serially
+	ReproductionWorkers int
+
+	// Age-layered population structure (ALPS). Train splits the population
+	// into ALPSLayers layers of PopulationSize organisms by the age of their
+	// oldest genetic material, layer i holding those younger than
+	// ALPSAgeGap*(i+1) generations and the top layer the rest. Every
+	// ALPSAgeGap generations the bottom layer is promoted and reseeded with
+	// fresh genomes. 0 layers = a single population
+	ALPSLayers int
+	ALPSAgeGap int
+
+	// Keep the innovation history for the whole run instead of each
+	// generation, so that a structural innovation always takes the same
+	// marker
+	PersistentInnovations bool
 
 	// Runtime settings
-	ArchiveFrequency int // Frequency to archive the population. 0 = archive every iteration
-	ReportFrequency  int // Frequency to report on the population. 0 = report every iteration
+	Seed             int64 // Seed for the random number generator. 0 = seed from the clock
+	ArchiveFrequency int   // Frequency to archive the population. 0 = archive every iteration
+	ReportFrequency  int   // Frequency to report on the population. 0 = report every iteration
+
+	// Runtime extensions, these are not persisted with the settings
+	Collectors []StatsCollector `json:"-" xml:"-"` // Receive the statistics of every generation
+	Hooks      Hooks            `json:"-" xml:"-"` // Called at notable points of the run
+	Logger     Logger           `json:"-" xml:"-"` // Receives events from the run. nil = silent
+	Record     io.Writer        `json:"-" xml:"-"` // Receives a ReplayRecord before each roll, see Replay
+
+	// Scheme breeding each generation. nil = NEATReproduction
+	Reproduction Reproduction `json:"-" xml:"-"`
+
+	// Innovation tracker to run with, such as one shared with other runs
+	// from LoadInnovations. It is left open and keeps its history across
+	// generations. nil = a new tracker for each run
+	Innovations *innovation `json:"-" xml:"-"`
+
+	// Optional cheap evaluator for two-stage evaluation. It gives every
+	// organism a provisional fitness by which each species is culled to its
+	// SurvivalPercent, and only the survivors are then evaluated in full. The
+	// pre-evaluation cannot be combined with deferred speciation.
+	PreEval OrgEval `json:"-" xml:"-"`
+
+	weights weightInit // InitialWeight as parsed
+	rng     *rng       // Generator for a trial or for breeding a species in parallel. nil = the shared one
+	fitVer  int        // Bumped whenever selection fitness is set, so selectors drop their cached weights
+	base    *Settings  // Snapshot taken as the run began, before its rates were moved, for archiving
+}
+
+// Validates the settings, returning an error describing the first problem
+// found
+func (s *Settings) Validate() (err error) {
+
+	// Size of the population and initial genome
+	switch {
+	case s.PopulationSize < 1:
+		return fmt.Errorf("PopulationSize must be positive, not %d", s.PopulationSize)
+	case s.BiasCount < 0 || s.InputCount < 0:
+		return fmt.Errorf("BiasCount and InputCount cannot be negative")
+	case s.BiasCount+s.InputCount < 1:
+		return fmt.Errorf("The genome needs at least one bias or input node")
+	case s.OutputCount < 1:
+		return fmt.Errorf("OutputCount must be positive, not %d", s.OutputCount)
+	}
+
+	// Probabilities
+	probs := []struct {
+		name string
+		p    float64
+	}{
+		{"MutateWeight", s.MutateWeight}, {"MutateWeightNew", s.MutateWeightNew},
+		{"MutateEnabled", s.MutateEnabled}, {"MutateAddConnection", s.MutateAddConnection},
+		{"MutateAddNode", s.MutateAddNode}, {"MutateDelNode", s.MutateDelNode},
+		{"MutateDelConnection", s.MutateDelConnection}, {"Crossover", s.Crossover},
+		{"MateAveragingProb", s.MateAveragingProb}, {"ElitePercent", s.ElitePercent},
+		{"InterspeciesMating", s.InterspeciesMating}, {"SurvivalPercent", s.SurvivalPercent},
+		{"ModuleBias", s.ModuleBias}, {"MutateResponse", s.MutateResponse},
+	}
+	switch s.Selection {
+	case "", "roulette":
+	case "rank":
+		if s.SelectionPressure != 0 && (s.SelectionPressure < 1 || s.SelectionPressure > 2) {
+			return fmt.Errorf("SelectionPressure must be between 1 and 2, not %v", s.SelectionPressure)
+		}
+	case "boltzmann":
+		if s.BoltzmannTemperature <= 0 {
+			return fmt.Errorf("BoltzmannTemperature must be positive")
+		}
+		if s.BoltzmannDecay < 0 || s.BoltzmannDecay > 1 {
+			return fmt.Errorf("BoltzmannDecay must be between 0 and 1, not %v", s.BoltzmannDecay)
+		}
+	case "lexicase":
+		if s.LexicaseEpsilon < 0 {
+			return fmt.Errorf("LexicaseEpsilon cannot be negative")
+		}
+	default:
+		return fmt.Errorf("Unknown Selection %q", s.Selection)
+	}
+	if s.SpeciesThresholds {
+		if s.MaxSpeciesSize < 1 {
+			return fmt.Errorf("MaxSpeciesSize must be positive with SpeciesThresholds")
+		}
+		if s.ThresholdStep < 0 {
+			return fmt.Errorf("ThresholdStep cannot be negative")
+		}
+		if s.MinCompatThreshold <= 0 || s.MinCompatThreshold > s.CompatThreshold ||
+			s.MaxCompatThreshold < s.CompatThreshold {
+			return fmt.Errorf("CompatThreshold of %v must lie within a positive MinCompatThreshold, %v, and MaxCompatThreshold, %v",
+				s.CompatThreshold, s.MinCompatThreshold, s.MaxCompatThreshold)
+		}
+	}
+	switch s.Speciation {
+	case "", "genome", "behavior":
+	default:
+		return fmt.Errorf("Unknown Speciation %q", s.Speciation)
+	}
+	for _, a := range []string{s.HiddenActivation, s.OutputActivation} {
+		if _, ok := activations[a]; !ok {
+			return fmt.Errorf("Unknown activation %q", a)
+		}
+	}
+	switch s.StagnationMetric {
+	case "", "mean", "best", "median":
+	default:
+		return fmt.Errorf("Unknown StagnationMetric %q", s.StagnationMetric)
+	}
+	if s.StagnationEpsilon < 0 {
+		return fmt.Errorf("StagnationEpsilon cannot be negative")
+	}
+	if s.MinMateDistance < 0 || s.IncestDepth < 0 {
+		return fmt.Errorf("MinMateDistance and IncestDepth cannot be negative")
+	}
+	if s.ALPSLayers < 0 || s.ALPSAgeGap < 0 {
+		return fmt.Errorf("ALPSLayers and ALPSAgeGap cannot be negative")
+	}
+	if s.ALPSLayers > 0 && s.ALPSAgeGap == 0 {
+		return fmt.Errorf("ALPSLayers needs an ALPSAgeGap")
+	}
+	if s.FitnessHistory < 0 {
+		return fmt.Errorf("FitnessHistory cannot be negative")
+	}
+	if s.StagnationWindow && s.FitnessHistory < 2 {
+		return fmt.Errorf("StagnationWindow needs a FitnessHistory of at least 2")
+	}
+	if s.StagnationGrace < 0 || s.MinSpeciesSize < 0 {
+		return fmt.Errorf("StagnationGrace and MinSpeciesSize cannot be negative")
+	}
+	if s.EliteCount < 0 || s.GlobalEliteCount < 0 || s.EliteSizeFloor < 0 {
+		return fmt.Errorf("EliteCount, GlobalEliteCount and EliteSizeFloor cannot be negative")
+	}
+	modes := 0
+	for _, on := range []bool{s.EliteCount > 0, s.ElitePercent > 0, s.GlobalEliteCount > 0} {
+		if on {
+			modes += 1
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("Only one of EliteCount, ElitePercent and GlobalEliteCount may be set")
+	}
+	if s.AllowSelfConnections && !s.AllowRecurrent {
+		return fmt.Errorf("AllowSelfConnections requires AllowRecurrent")
+	}
+	if s.PreEval != nil && s.deferSpeciation() {
+		return fmt.Errorf("PreEval cannot be used with deferred speciation")
+	}
+	if s.MaxNodes != 0 && s.MaxNodes < s.BiasCount+s.InputCount+s.OutputCount {
+		return fmt.Errorf("MaxNodes of %d is too small for the initial genome", s.MaxNodes)
+	}
+	if s.MaxConnections != 0 && s.MaxConnections < (s.BiasCount+s.InputCount)*s.OutputCount {
+		return fmt.Errorf("MaxConnections of %d is too small for the initial genome", s.MaxConnections)
+	}
+	if s.EvaluationBudget < 0 || s.BudgetSpeciesMinimum < 0 || s.BudgetDiscount < 0 {
+		return fmt.Errorf("EvaluationBudget, BudgetSpeciesMinimum and BudgetDiscount cannot be negative")
+	}
+	if s.EvaluationBudget > 0 && (s.PreEval != nil || s.deferSpeciation()) {
+		return fmt.Errorf("EvaluationBudget cannot be used with PreEval or deferred speciation")
+	}
+	if s.EvaluationRepeats < 0 || s.RacingZ < 0 {
+		return fmt.Errorf("EvaluationRepeats and RacingZ cannot be negative")
+	}
+	if s.ReproductionWorkers < 0 {
+		return fmt.Errorf("ReproductionWorkers cannot be negative")
+	}
+	if s.SelfAdaptiveRate < 0 {
+		return fmt.Errorf("SelfAdaptiveRate cannot be negative")
+	}
+	w, err := parseInitialWeight(s.InitialWeight)
+	if err != nil {
+		return err
+	}
+	s.weights = w
+	if s.MaxWeight < 0 {
+		return fmt.Errorf("MaxWeight cannot be negative")
+	}
+	if s.ResponsePower < 0 || s.ResponseCoefficient < 0 {
+		return fmt.Errorf("ResponsePower and ResponseCoefficient cannot be negative")
+	}
+	if s.MinResponse < 0 || s.MaxResponse < 0 {
+		return fmt.Errorf("MinResponse and MaxResponse cannot be negative")
+	}
+	if lo, hi := s.responseBounds(); lo >= hi {
+		return fmt.Errorf("MinResponse of %v must be below MaxResponse of %v", lo, hi)
+	}
+	if err = s.validateSchedules(); err != nil {
+		return
+	}
+	if s.ReplaceInvalidFitness && (math.IsNaN(s.InvalidFitness) || math.IsInf(s.InvalidFitness, 0)) {
+		return fmt.Errorf("InvalidFitness must be a finite number")
+	}
+	for _, x := range probs {
+		if x.p < 0 || x.p > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, not %v", x.name, x.p)
+		}
+	}
+
+	// Node names must match the node counts and be unique
+	if len(s.InputNames) > 0 && len(s.InputNames) != s.InputCount {
+		return fmt.Errorf("There are %d InputNames for %d inputs", len(s.InputNames), s.InputCount)
+	}
+	if len(s.OutputNames) > 0 && len(s.OutputNames) != s.OutputCount {
+		return fmt.Errorf("There are %d OutputNames for %d outputs", len(s.OutputNames), s.OutputCount)
+	}
+	if len(s.InputModules) > 0 && len(s.InputModules) != s.InputCount {
+		return fmt.Errorf("There are %d InputModules for %d inputs", len(s.InputModules), s.InputCount)
+	}
+	if len(s.OutputModules) > 0 && len(s.OutputModules) != s.OutputCount {
+		return fmt.Errorf("There are %d OutputModules for %d outputs", len(s.OutputModules), s.OutputCount)
+	}
+	if s.ModuleCoefficient < 0 {
+		return fmt.Errorf("ModuleCoefficient cannot be negative")
+	}
+	seen := make(map[string]bool, len(s.InputNames)+len(s.OutputNames))
+	for _, names := range [][]string{s.InputNames, s.OutputNames} {
+		for _, n := range names {
+			if n == "" {
+				return fmt.Errorf("Node names cannot be empty")
+			}
+			if seen[n] {
+				return fmt.Errorf("Node name %q is used more than once", n)
+			}
+			seen[n] = true
+		}
+	}
+
+	return
+}
+
+// Activation functions by name
+var activations = map[string]neural.FuncType{"": neural.SIGMOID, "sigmoid": neural.SIGMOID, "linear": neural.DIRECT}
+
+// Returns the activation functions of the hidden and output nodes
+func (s *Settings) Activations() (hidden, output neural.FuncType) {
+	return activations[s.HiddenActivation], activations[s.OutputActivation]
+}
+
+// Returns the random number generator used in breeding, which is the shared
+// one unless the settings have been copied for breeding a species in
+// parallel
+func (s *Settings) rand() *rng {
+	if s.rng != nil {
+		return s.rng
+	}
+	return &random
+}
+
+// Returns true if a gene carrying the frozen flag is to be protected
+func (s *Settings) frozen(flag bool) bool {
+	return flag && !s.IgnoreFrozen
+}
+
+// Returns true if the genome has more genes than the caps allow
+func (s *Settings) overCap(g *Genome) bool {
+	return (s.MaxNodes > 0 && len(g.Nodes) > s.MaxNodes) ||
+		(s.MaxConnections > 0 && len(g.Conns) > s.MaxConnections)
+}
+
+// Returns true if the genome can take the given number of extra genes
+// without passing the caps. Refusals are tallied.
+func (s *Settings) canGrow(g *Genome, nodes, conns int, counters *rollCounters) bool {
+	if (s.MaxNodes > 0 && len(g.Nodes)+nodes > s.MaxNodes) ||
+		(s.MaxConnections > 0 && len(g.Conns)+conns > s.MaxConnections) {
+		counters.capHits += 1
+		return false
+	}
+	return true
+}
+
+// Returns true if the children are evaluated before being speciated
+func (s *Settings) deferSpeciation() bool {
+	return s.DeferSpeciation || s.Speciation == "behavior"
+}
+
+// Returns the bounds of the node responses, with their defaults
+func (s *Settings) responseBounds() (lo, hi float64) {
+	lo, hi = s.MinResponse, s.MaxResponse
+	if lo == 0 {
+		lo = 0.1
+	}
+	if hi == 0 {
+		hi = 5
+	}
+	return
+}
+
+// Returns the number of elites kept by a species of the given size. Under
+// ElitePercent the share is rounded up for species larger than the floor,
+// which so keep at least one elite, and down for the rest.
+func (s *Settings) eliteCount(size int) int {
+	if s.ElitePercent > 0 {
+		if size > s.EliteSizeFloor {
+			return int(math.Ceil(float64(size) * s.ElitePercent))
+		}
+		return int(float64(size) * s.ElitePercent)
+	}
+	return s.EliteCount
+}
+
+// Returns the number of a species of the given size to survive culling,
+// which is never fewer than its elites nor, unless the species is empty,
+// fewer than one
+func (s *Settings) survivors(size, elites int) int {
+	keep := int(s.SurvivalPercent * float64(size))
+	if keep < elites {
+		keep = elites
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	if keep > size {
+		keep = size
+	}
+	return keep
+}
+
+// Returns true if a child is to have two parents, drawing against Crossover
+// when there are mates enough to choose from
+func (s *Settings) crosses(r *rng, mates int) bool {
+	return mates > 1 && r.Next() < s.Crossover
 }
@@ -28,16 +28,111 @@ package neat
 
 import (
 	"fmt"
+	"math"
+	"sort"
 )
 
 type Species struct {
 	ID          int           // Identifier for this species
 	Orgs        OrganismSlice // Portion of the population belonging to this species
 	Age         int           // Age of species
-	BestFitness float64       // Best fitness this species has acheived
+	CreatedAt   int           // Generation in which the species was created
+	BestFitness float64       // Best fitness this species has acheived, as measured by the StagnationMetric
 	BestFitAge  int           // Age when species achieved best fitness
 	Example     *Organism     // Example organism for determining future members of this species
+	Offspring   int           // Number of offspring the species was allotted by the last roll
+	Layer       int           `json:",omitempty"`         // ALPS layer holding the species
+	Meta        Meta          `json:",omitempty" xml:"-"` // Experiment data, carried to the next generation
 	currFitness float64       // The current generation's fitness
+
+	// Ring buffer of the species' recent fitness, holding at most
+	// Settings.FitnessHistory points. Once full, the oldest is at TrailHead.
+	// Use History to read it in order.
+	Trail     []FitnessPoint `json:",omitempty"`
+	TrailHead int            `json:",omitempty"`
+
+	// The species' own compatibility threshold, see Settings.SpeciesThresholds
+	Threshold float64 `json:",omitempty"`
+}
+
+// The fitness of a species' organisms in one generation
+type FitnessPoint struct {
+	Generation int
+	Best       float64
+	Mean       float64
+}
+
+// Returns the fitness the species has remembered, oldest first
+func (s *Species) History() []FitnessPoint {
+	h := make([]FitnessPoint, 0, len(s.Trail))
+	h = append(h, s.Trail[s.TrailHead:]...)
+	return append(h, s.Trail[:s.TrailHead]...)
+}
+
+// Adds a point to the species' history, overwriting the oldest once the
+// history holds Settings.FitnessHistory points
+func (s *Species) remember(settings *Settings, p FitnessPoint) {
+	n := settings.FitnessHistory
+	switch {
+	case n <= 0:
+		s.Trail, s.TrailHead = nil, 0
+	case len(s.Trail) > n:
+		s.Trail, s.TrailHead = append(s.History()[len(s.Trail)-n+1:], p), 0 // The length was reduced
+	case len(s.Trail) < n:
+		s.Trail = append(s.Trail, p)
+	default:
+		s.Trail[s.TrailHead] = p
+		s.TrailHead = (s.TrailHead + 1) % n
+	}
+}
+
+// Returns a copy of the species' history for the next generation, sharing no
+// storage with this one
+func (s *Species) trailCopy() []FitnessPoint {
+	if s.Trail == nil {
+		return nil
+	}
+	return append(make([]FitnessPoint, 0, cap(s.Trail)), s.Trail...)
+}
+
+// Returns the compatibility threshold for joining the species
+func (s *Species) threshold(settings *Settings) float64 {
+	if settings.SpeciesThresholds && s.Threshold > 0 {
+		return s.Threshold
+	}
+	return settings.CompatThreshold
+}
+
+// Returns the species as carried into the next generation, a year older and
+// without any organisms. A species without an example takes its first
+// organism as one.
+func (s *Species) carry() *Species {
+	c := &Species{ID: s.ID, Age: s.Age + 1, CreatedAt: s.CreatedAt, BestFitness: s.BestFitness,
+		BestFitAge: s.BestFitAge, Example: s.Example, Layer: s.Layer, Meta: s.Meta, Trail: s.trailCopy(),
+		TrailHead: s.TrailHead, Threshold: s.Threshold}
+	if c.Example == nil && len(s.Orgs) > 0 {
+		c.Example = s.Orgs[0]
+	}
+	return c
+}
+
+// Returns the species' current fitness point, and false if any of its
+// organisms is yet to be evaluated
+func (s *Species) fitnessPoint() (p FitnessPoint, ok bool) {
+	p.Generation = s.CreatedAt + s.Age
+	for i, o := range s.Orgs {
+		if len(o.Fitness) == 0 {
+			return p, false
+		}
+		if i == 0 || o.Fitness[0] > p.Best {
+			p.Best = o.Fitness[0]
+		}
+		p.Mean += o.Fitness[0]
+	}
+	if len(s.Orgs) > 0 {
+		p.Mean /= float64(len(s.Orgs))
+	}
+	return p, len(s.Orgs) > 0
 }
 
 func (s Species) String() string {
@@ -45,18 +140,129 @@ func (s Species) String() string {
 		s.ID, s.Age, len(s.Orgs), s.BestFitness, s.BestFitAge)
 }
 
-func (s *Species) calcFitness() {
-	sum := float64(0)
+func (s *Species) calcFitness(settings *Settings) {
+	s.currFitness, _ = s.Orgs.MeanFitness()
+
+	if f := s.stagnationFitness(settings); s.improves(settings, f) {
+		s.BestFitness = f
+		s.BestFitAge = s.Age
+	}
+	if p, ok := s.fitnessPoint(); ok {
+		s.remember(settings, p)
+	}
+}
+
+// Returns the species' fitness as measured by Settings.StagnationMetric: the
+// mean (the default), best or median fitness of its evaluated organisms
+func (s *Species) stagnationFitness(settings *Settings) float64 {
+	fits := make([]float64, 0, len(s.Orgs))
 	for _, o := range s.Orgs {
-		sum += o.Fitness[0]
+		if len(o.Fitness) > 0 {
+			fits = append(fits, o.Fitness[0])
+		}
+	}
+	if len(fits) == 0 {
+		return 0
 	}
-	sum /= float64(len(s.Orgs))
-	s.currFitness = sum
+	switch settings.StagnationMetric {
+	case "best":
+		best := fits[0]
+		for _, f := range fits[1:] {
+			best = math.Max(best, f)
+		}
+		return best
+	case "median":
+		sort.Float64s(fits)
+		m := len(fits) / 2
+		if len(fits)%2 == 0 {
+			return (fits[m-1] + fits[m]) / 2
+		}
+		return fits[m]
+	default:
+		sum := float64(0)
+		for _, f := range fits {
+			sum += f
+		}
+		return sum / float64(len(fits))
+	}
+}
 
-	if sum > s.BestFitness {
-		s.BestFitness = sum
-		s.BestFitAge = s.Age
+// Returns true if the fitness betters the species' best by more than
+// Settings.StagnationEpsilon
+func (s *Species) improves(settings *Settings, fitness float64) bool {
+	return fitness > s.BestFitness+settings.StagnationEpsilon
+}
+
+// Returns the species' organism with the highest fitness, ignoring those not
+// yet evaluated and those whose fitness is estimated
+func (s *Species) champion() (champ *Organism) {
+	for _, o := range s.Orgs {
+		if len(o.Fitness) == 0 || o.Estimated {
+			continue
+		}
+		if champ == nil || o.Fitness[0] > champ.Fitness[0] {
+			champ = o
+		}
+	}
+	return
+}
+
+// Returns the number of generations since the species' fitness last improved
+func (s *Species) Stagnation() int {
+	return s.Age - s.BestFitAge
+}
+
+// Returns true if the species is stagnant by its fitness so far, being past
+// the grace period and without improvement for AgeToStagnation generations.
+// Under Settings.StagnationWindow it is instead stagnant once its history is
+// full and no later generation's best betters the oldest's by more than
+// Settings.StagnationEpsilon.
+func (s *Species) stagnant(settings *Settings) bool {
+	if s.Age < settings.StagnationGrace {
+		return false
+	}
+	if settings.StagnationWindow {
+		return windowStagnant(settings, s.History())
+	}
+	return s.Stagnation() >= settings.AgeToStagnation
+}
+
+// Returns true if the history spans the whole window without improvement
+func windowStagnant(settings *Settings, h []FitnessPoint) bool {
+	if len(h) < settings.FitnessHistory {
+		return false
+	}
+	h = h[len(h)-settings.FitnessHistory:]
+	for _, p := range h[1:] {
+		if p.Best > h[0].Best+settings.StagnationEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the next roll will find the species stagnant, taking into
+// account the improvement its current organisms would bring. The roll spares
+// the species holding the population's best organism regardless; see
+// Population.WillCull.
+func (s *Species) WillStagnate(settings *Settings) bool {
+	cnt := 0
+	for _, o := range s.Orgs {
+		if len(o.Fitness) > 0 {
+			cnt += 1
+		}
+	}
+	if settings.StagnationWindow {
+		h := s.History()
+		if p, ok := s.fitnessPoint(); ok {
+			h = append(h, p) // The point the roll will remember
+		}
+		return s.Age >= settings.StagnationGrace && windowStagnant(settings, h)
+	}
+	if cnt > 0 && cnt == len(s.Orgs) && s.improves(settings, s.stagnationFitness(settings)) {
+		return false // The roll will record an improvement
 	}
+	return s.stagnant(settings)
 }
 
 type SpeciesSlice []*Species
@@ -0,0 +1,320 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestApportionMinimum(t *testing.T) {
+	cases := []struct {
+		fitness []float64 // Current fitness of each species
+		min     int
+		want    []int
+	}{
+		{[]float64{9, 1}, 0, []int{90, 10}},
+		{[]float64{99, 1}, 5, []int{95, 5}},
+		{[]float64{98, 1, 1}, 5, []int{90, 5, 5}},
+		{[]float64{0, 0}, 5, []int{50, 50}},
+	}
+	for i, c := range cases {
+		s := &Settings{PopulationSize: 100, MinSpeciesSize: c.min}
+		living := make(SpeciesSlice, len(c.fitness))
+		total := float64(0)
+		for j, f := range c.fitness {
+			living[j] = &Species{ID: j + 1, currFitness: f}
+			total += f
+		}
+		got := apportion(s, s.PopulationSize, living, total)
+		for j := range got {
+			if got[j] != c.want[j] {
+				t.Errorf("Case %d: shares %v, want %v", i, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+// Scores the organisms carrying hidden node 60 poorly and the rest by their
+// weights
+type loneEval struct{}
+
+func (loneEval) Evaluate(org *Organism) error {
+	if _, ok := org.Nodes[60]; ok {
+		org.Fitness = []float64{0.01}
+		return nil
+	}
+	return weightEval{}.Evaluate(org)
+}
+
+func TestMinSpeciesSizeGrace(t *testing.T) {
+	s := testSettings()
+	s.PopulationSize = 30
+	s.MinSpeciesSize = 2
+	s.StagnationGrace = 4
+	s.AgeToStagnation = 1
+	s.MutateAddNode, s.MutateAddConnection = 0, 0
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.rand().seed(s.Seed)
+
+	// A fit species and a lone, weak and distant one
+	inno := newInnovation(nil)
+	pop, err := initialPopulation(s, inno)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inno.close()
+	lone := &Organism{Genome: testGenome(1000, 0, testConn{60, 2, 60, 1}, testConn{61, 60, 4, 1},
+		testConn{62, 3, 60, 1}, testConn{63, 1, 60, 1})}
+	pop.Species = append(pop.Species, &Species{ID: 999, Orgs: OrganismSlice{lone}, Example: lone,
+		CreatedAt: 1})
+	inno = newInnovation(pop)
+	defer inno.close()
+
+	// The lone species lives through its grace period, keeping its minimum
+	for gen := 1; gen <= 8; gen++ {
+		if err = (serialEval{}).Evaluate(pop, loneEval{}); err != nil {
+			t.Fatal(err)
+		}
+		var found *Species
+		for _, sp := range pop.Species {
+			if sp.ID == 999 {
+				found = sp
+			}
+		}
+		switch {
+		case found == nil && gen <= s.StagnationGrace:
+			t.Fatalf("Lone species died in generation %d, within its grace period", gen)
+		case found == nil:
+			return // Culled once the grace period ended
+		case gen > 1 && len(found.Orgs) < s.MinSpeciesSize:
+			t.Errorf("Lone species has %d organisms in generation %d, want at least %d", len(found.Orgs), gen,
+				s.MinSpeciesSize)
+		}
+		if pop, err = rollPop(s, inno, pop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Error("Lone species was never culled after its grace period")
+}
+
+func TestSurvivors(t *testing.T) {
+	for _, c := range []struct {
+		size    int
+		percent float64
+		elites  int
+		want    int
+	}{
+		{10, 0.2, 1, 2},
+		{10, 0.2, 5, 5},
+		{10, 0, 0, 1},
+		{2, 0.2, 0, 1},
+		{2, 0.2, 3, 2},
+		{1, 0.2, 0, 1},
+		{1, 1, 1, 1},
+		{0, 0.2, 1, 0},
+	} {
+		s := &Settings{SurvivalPercent: c.percent}
+		if got := s.survivors(c.size, c.elites); got != c.want {
+			t.Errorf("Species of %d with SurvivalPercent %v and %d elites keeps %d, want %d", c.size,
+				c.percent, c.elites, got, c.want)
+		}
+	}
+}
+
+// Rolls a single species of each size on a generation, which must fill the
+// population whatever is culled
+func TestRollSmallSpecies(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 10} {
+		for _, percent := range []float64{0, 0.01, 0.2, 1} {
+			for _, elites := range []int{0, 1, 5} {
+				s := testSettings()
+				s.PopulationSize = 10
+				s.SurvivalPercent, s.EliteCount = percent, elites
+				if err := s.Validate(); err != nil {
+					t.Fatal(err)
+				}
+				s.rand().seed(1)
+				orgs := make(OrganismSlice, size)
+				for i := range orgs {
+					orgs[i] = &Organism{Genome: testGenome(i+1, float64(i), testConn{1, 2, 4, float64(i)},
+						testConn{2, 3, 4, 1}, testConn{3, 1, 4, 1})}
+				}
+				pop := &Population{Generation: 1, Species: SpeciesSlice{{ID: 1, Orgs: orgs, Example: orgs[0]}}}
+				inno := newInnovation(pop)
+				next, err := rollPop(s, inno, pop)
+				inno.close()
+				if err != nil {
+					t.Fatalf("Size %d, SurvivalPercent %v, %d elites: %v", size, percent, elites, err)
+				}
+				if n := len(next.Organisms()); n != 10 {
+					t.Errorf("Size %d, SurvivalPercent %v, %d elites: %d children", size, percent, elites, n)
+				}
+				if size == 1 {
+					for _, o := range next.Organisms() {
+						if len(o.Parents) > 1 {
+							t.Errorf("Child %d of a lone organism has parents %v", o.ID, o.Parents)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Ages a species whose best organism stays as it is while the others
+// improve, returning the generation it stagnated in or 0
+func flatBestStagnation(metric string, epsilon, step float64) int {
+	s := &Settings{AgeToStagnation: 5, StagnationMetric: metric, StagnationEpsilon: epsilon}
+	sp := &Species{ID: 1}
+	for i := 0; i < 5; i++ {
+		sp.Orgs = append(sp.Orgs, &Organism{Genome: testGenome(i+1, 0)})
+	}
+	for gen := 1; gen <= 20; gen++ {
+		sp.Age = gen
+		sp.Orgs[0].Fitness = []float64{10}
+		for _, o := range sp.Orgs[1:] {
+			o.Fitness = []float64{1 + float64(gen)*step}
+		}
+		sp.calcFitness(s)
+		if sp.stagnant(s) {
+			return gen
+		}
+	}
+	return 0
+}
+
+func TestStagnationMetric(t *testing.T) {
+	for _, c := range []struct {
+		metric  string
+		epsilon float64
+		step    float64
+		want    int
+	}{
+		{"best", 0, 0.4, 6},
+		{"mean", 0, 0.4, 0},
+		{"", 0, 0.4, 0},
+		{"median", 0, 0.4, 0},
+		{"mean", 1e-6, 1e-9, 6},
+		{"median", 1e-6, 1e-9, 6},
+		{"mean", 0.05, 0.4, 0},
+	} {
+		if got := flatBestStagnation(c.metric, c.epsilon, c.step); got != c.want {
+			t.Errorf("Metric %q, epsilon %v, step %v: stagnated in generation %d, want %d", c.metric,
+				c.epsilon, c.step, got, c.want)
+		}
+	}
+}
+
+// Pins the culling condition: a species past its grace period is culled
+// once Age-BestFitAge reaches AgeToStagnation, unless it holds the champion
+func TestStagnationCulling(t *testing.T) {
+	s := testSettings()
+	s.AgeToStagnation, s.StagnationGrace = 5, 5
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.rand().seed(1)
+	inno := newInnovationAt(100, 100)
+	defer inno.close()
+	species := func(id, age, bestFitAge int, fitness ...float64) *Species {
+		sp := &Species{ID: id, Age: age, CreatedAt: 11 - age, BestFitness: 100, BestFitAge: bestFitAge}
+		for i, f := range fitness {
+			sp.Orgs = append(sp.Orgs, &Organism{Genome: testGenome(10*id+i, f, testConn{5, 1, 4, f})})
+		}
+		return sp
+	}
+	pop := &Population{Generation: 11, Species: SpeciesSlice{
+		species(1, 10, 5, 1, 2, 3),       // Stagnant for exactly AgeToStagnation generations
+		species(2, 10, 6, 1, 2, 3),       // One generation short of it
+		species(3, 10, 0, 1, 2, 290),     // Long stagnant, but holding the champion
+		species(4, 4, 0, 1, 2, 3),        // Long stagnant, but within the grace period
+		species(5, 5, 0, 1, 2, 3),        // Just past the grace period
+		species(6, 10, 0, 101, 101, 101), // Stagnant so far, but improving this generation
+	}}
+	want := map[int]bool{1: true, 5: true}
+	for _, sp := range pop.Species {
+		if got := pop.WillCull(s, sp); got != want[sp.ID] {
+			t.Errorf("Species %d with stagnation %d: WillCull %v, want %v", sp.ID, sp.Stagnation(), got,
+				want[sp.ID])
+		}
+		if got := sp.WillStagnate(s); got != (want[sp.ID] || sp.ID == 3) {
+			t.Errorf("Species %d: WillStagnate %v", sp.ID, got)
+		}
+	}
+
+	// The status is in the report and survives serialization
+	var buf bytes.Buffer
+	if err := pop.Report(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	reported := false
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if f := strings.Fields(line); len(f) > 6 && f[0] == "1" {
+			reported = f[1] == "1" && f[6] == "5"
+		}
+	}
+	if !reported {
+		t.Errorf("Species 1 is not reported as born in generation 1 with stagnation 5:\n%s", buf.String())
+	}
+	b, err := json.Marshal(pop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Population
+	if err = json.Unmarshal(b, &restored); err != nil {
+		t.Fatal(err)
+	}
+	for i, sp := range restored.Species {
+		if orig := pop.Species[i]; sp.CreatedAt != orig.CreatedAt || sp.Stagnation() != orig.Stagnation() ||
+			sp.WillStagnate(s) != orig.WillStagnate(s) {
+			t.Errorf("Species %d restored as created at %d with stagnation %d", sp.ID, sp.CreatedAt,
+				sp.Stagnation())
+		}
+	}
+
+	// And the roll culls just those it said it would
+	log := &recordingLogger{}
+	s.Logger = log
+	if _, err = rollPop(s, inno, pop); err != nil {
+		t.Fatal(err)
+	}
+	culled := make(map[int]bool)
+	for _, e := range log.events["species culled for stagnation"] {
+		culled[e["species"].(int)] = true
+	}
+	for id := 1; id <= 6; id++ {
+		if culled[id] != want[id] {
+			t.Errorf("Species %d culled %v by the roll", id, culled[id])
+		}
+	}
+}
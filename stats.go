@@ -0,0 +1,106 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"time"
+)
+
+// Statistics describing a single generation of a run
+type Stats struct {
+	Generation   int            // Generation the statistics describe
+	BestFitness  float64        // Fitness of the best organism
+	MeanFitness  float64        // Mean fitness of the evaluated organisms
+	SpeciesCount int            // Number of species in the population
+	MPC          float64        // Mean population complexity
+	Elapsed      time.Duration  // Wall-clock time taken by the generation
+	Evaluations  int            // Organisms evaluated during the generation
+	Estimated    int            // Organisms given an estimated fitness instead
+	CapHits      int            // Mutations and matings limited by the genome size caps
+	Duplicates   int            // Organisms whose genome duplicates that of another
+	InterModule  float64        // Mean enabled connections between modules per organism
+	Species      []SpeciesStats // Breakdown by species
+
+	// How the generation was made from the last
+	Reproduction ReproductionSummary
+
+	// Values of the scheduled rates which bred the generation, see
+	// Settings.Schedules
+	Rates map[string]float64 `json:",omitempty"`
+}
+
+// Statistics describing a single species within a generation
+type SpeciesStats struct {
+	ID          int     // Identifier of the species
+	Size        int     // Number of organisms in the species
+	Age         int     // Age of the species
+	Stagnation  int     // Generations since the species' fitness improved
+	BestFitness float64 // Fitness of the species' best organism
+	MeanFitness float64 // Mean fitness of the species' organisms
+	Meta        Meta    `json:",omitempty"` // The species' experiment data
+	Threshold   float64 `json:",omitempty"` // The species' own compatibility threshold, if it has one
+}
+
+// StatsCollector receives the statistics of each generation as the run
+// progresses
+type StatsCollector interface {
+	Collect(stats *Stats) error
+}
+
+// Computes the statistics for an evaluated population
+func newStats(pop *Population, elapsed time.Duration, evals int) *Stats {
+	stats := &Stats{Generation: pop.Generation, SpeciesCount: len(pop.Species), MPC: pop.MPC(),
+		Elapsed: elapsed, Evaluations: evals, CapHits: pop.counters.capHits,
+		Species: make([]SpeciesStats, len(pop.Species))}
+	stats.Reproduction = pop.counters.summary()
+	stats.Duplicates = pop.Duplicates()
+
+	inter, orgs := 0, 0
+	for i, s := range pop.Species {
+		ss := SpeciesStats{ID: s.ID, Size: len(s.Orgs), Age: s.Age, Stagnation: s.Age - s.BestFitAge,
+			Meta: s.Meta, Threshold: s.Threshold}
+		ss.BestFitness, _ = s.Orgs.MaxFitness()
+		ss.MeanFitness, _ = s.Orgs.MeanFitness()
+		for _, o := range s.Orgs {
+			inter += o.InterModuleConns()
+			orgs += 1
+			if o.Estimated {
+				stats.Estimated += 1
+			}
+		}
+		stats.Species[i] = ss
+	}
+	all := pop.Organisms()
+	if c := pop.Champion(); c != nil {
+		stats.BestFitness = c.Fitness[0] // Trusting only evaluated fitness
+	}
+	stats.MeanFitness, _ = all.MeanFitness()
+	if orgs > 0 {
+		stats.InterModule = float64(inter) / float64(orgs)
+	}
+	return stats
+}
@@ -0,0 +1,112 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Distribution of the weights given to new connections, parsed from
+// Settings.InitialWeight
+type weightInit struct {
+	kind     string  // "gaussian", "uniform" or "xavier"
+	a, b     float64 // Sigma for gaussian, the bounds for uniform
+	parsedOf string  // The setting this was parsed from
+}
+
+// Parses an InitialWeight setting. The empty string is gaussian(1).
+func parseInitialWeight(spec string) (w weightInit, err error) {
+	w.parsedOf = spec
+	s := strings.Replace(strings.ToLower(spec), " ", "", -1)
+	switch {
+	case s == "":
+		w.kind, w.a = "gaussian", 1
+	case s == "xavier":
+		w.kind = "xavier"
+	case strings.HasPrefix(s, "gaussian(") && strings.HasSuffix(s, ")"):
+		w.kind = "gaussian"
+		if _, err = fmt.Sscanf(s[len("gaussian("):len(s)-1], "%g", &w.a); err != nil || w.a <= 0 {
+			err = fmt.Errorf("InitialWeight %q needs a positive sigma", spec)
+		}
+	case strings.HasPrefix(s, "uniform(") && strings.HasSuffix(s, ")"):
+		w.kind = "uniform"
+		args := strings.Split(s[len("uniform("):len(s)-1], ",")
+		if len(args) != 2 {
+			err = fmt.Errorf("InitialWeight %q needs a minimum and maximum", spec)
+			break
+		}
+		_, e1 := fmt.Sscanf(args[0], "%g", &w.a)
+		_, e2 := fmt.Sscanf(args[1], "%g", &w.b)
+		if e1 != nil || e2 != nil || w.a >= w.b {
+			err = fmt.Errorf("InitialWeight %q needs a minimum below its maximum", spec)
+		}
+	default:
+		err = fmt.Errorf("Unknown InitialWeight %q", spec)
+	}
+	return
+}
+
+// Returns a weight for a new connection into a node with the given number
+// of incoming connections. Xavier scales a unit Gaussian by 1/sqrt(fanIn).
+func (s *Settings) initialWeight(fanIn int) float64 {
+	if s.weights.parsedOf != s.InitialWeight || s.weights.kind == "" {
+		w, err := parseInitialWeight(s.InitialWeight)
+		if err != nil {
+			w, _ = parseInitialWeight("")
+		}
+		s.weights = w
+	}
+	w := s.weights
+	switch w.kind {
+	case "uniform":
+		return s.rand().Between(w.a, w.b)
+	case "xavier":
+		if fanIn < 1 {
+			fanIn = 1
+		}
+		return s.rand().Gaussian() / math.Sqrt(float64(fanIn))
+	default:
+		return s.rand().Gaussian() * w.a
+	}
+}
+
+// Does the weight distribution depend on the fan-in?
+func (s *Settings) weightNeedsFanIn() bool {
+	return strings.ToLower(strings.TrimSpace(s.InitialWeight)) == "xavier"
+}
+
+// Returns the number of enabled connections into the node
+func (g *Genome) fanIn(target int) (n int) {
+	for _, cg := range g.Conns {
+		if cg.Enabled && cg.Target == target {
+			n += 1
+		}
+	}
+	return
+}
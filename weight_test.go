@@ -0,0 +1,133 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseInitialWeight(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		want weightInit // kind "" = an error
+	}{
+		{"", weightInit{kind: "gaussian", a: 1}},
+		{"Gaussian( 0.5 )", weightInit{kind: "gaussian", a: 0.5}},
+		{"uniform(-1,1)", weightInit{kind: "uniform", a: -1, b: 1}},
+		{" Xavier", weightInit{kind: "xavier"}},
+		{"gaussian(0)", weightInit{}},
+		{"gaussian()", weightInit{}},
+		{"uniform(1,-1)", weightInit{}},
+		{"uniform(1)", weightInit{}},
+		{"normal(1)", weightInit{}},
+	} {
+		w, err := parseInitialWeight(c.spec)
+		switch {
+		case c.want.kind == "" && err == nil:
+			t.Errorf("%q parsed to %+v, want an error", c.spec, w)
+		case c.want.kind != "" && (err != nil || w.kind != c.want.kind || w.a != c.want.a || w.b != c.want.b):
+			t.Errorf("%q parsed to %+v, %v, want %+v", c.spec, w, err, c.want)
+		}
+		s := SettingsForXOR()
+		s.InitialWeight = c.spec
+		if err := s.Validate(); (err == nil) != (c.want.kind != "") {
+			t.Errorf("%q: Validate gave %v", c.spec, err)
+		}
+	}
+}
+
+func TestInitialWeightDistribution(t *testing.T) {
+	for _, c := range []struct {
+		spec      string
+		mean, sd  float64
+		min, max  float64 // Bounds of uniform weights
+		oneSigma  float64 // Share within a standard deviation of the mean
+		perFanIn  bool    // Weights are scaled by the fan-in
+		mutations bool    // Also check the weight-replace mutation
+	}{
+		{spec: "", sd: 1, oneSigma: 0.6827},
+		{spec: "gaussian(0.25)", sd: 0.25, oneSigma: 0.6827, mutations: true},
+		{spec: "uniform(-2,1)", mean: -0.5, sd: 3 / math.Sqrt(12), min: -2, max: 1, oneSigma: 0.5774},
+		{spec: "xavier", sd: 1, oneSigma: 0.6827, perFanIn: true, mutations: true},
+	} {
+		s := testSettings()
+		s.PopulationSize = 3000
+		s.MaxWeight = 1000
+		s.InitialWeight = c.spec
+		if err := s.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		s.rand().seed(1)
+		inno := newInnovation(nil)
+		pop, err := initialPopulation(s, inno)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ws []float64
+		for _, o := range pop.Organisms() {
+			for _, cg := range o.Conns {
+				if c.mutations {
+					mutateWeightNew(s, o, cg)
+				}
+				w := cg.Weight
+				if c.perFanIn {
+					w *= math.Sqrt(float64(o.fanIn(cg.Target)))
+				}
+				if c.max > c.min && (w < c.min || w > c.max) {
+					t.Fatalf("%q gave weight %v outside [%v, %v]", c.spec, w, c.min, c.max)
+				}
+				ws = append(ws, w)
+			}
+		}
+		inno.close()
+
+		n := float64(len(ws))
+		var sum, sq, within float64
+		for _, w := range ws {
+			sum += w
+		}
+		mean := sum / n
+		for _, w := range ws {
+			sq += (w - mean) * (w - mean)
+			if math.Abs(w-c.mean) <= c.sd {
+				within += 1
+			}
+		}
+		sd := math.Sqrt(sq / (n - 1))
+		if math.Abs(mean-c.mean) > 4*c.sd/math.Sqrt(n) {
+			t.Errorf("%q: mean %v of %v weights, want %v", c.spec, mean, n, c.mean)
+		}
+		if math.Abs(sd-c.sd) > 4*c.sd/math.Sqrt(2*n) {
+			t.Errorf("%q: standard deviation %v, want %v", c.spec, sd, c.sd)
+		}
+		share := within / n
+		if math.Abs(share-c.oneSigma) > 4*math.Sqrt(c.oneSigma*(1-c.oneSigma)/n) {
+			t.Errorf("%q: %v of the weights within a standard deviation, want %v", c.spec, share, c.oneSigma)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat_test
+
+import (
+	"errors"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neat/popeval"
+	"math"
+	"testing"
+)
+
+var (
+	xorInputs  = [][]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}}
+	xorOutputs = []float64{0, 1, 1, 0}
+)
+
+// Scores an organism by 1 less the root mean squared error of its XOR
+// outputs, as experiments/xor does
+type xorEval struct{}
+
+func (xorEval) Evaluate(org *neat.Organism) error {
+	e := float64(0)
+	for i, in := range xorInputs {
+		out, err := org.Analyze(in)
+		if err != nil {
+			return err
+		}
+		e += (out[0] - xorOutputs[i]) * (out[0] - xorOutputs[i])
+	}
+	org.Fitness = []float64{1 - math.Sqrt(e/float64(len(xorOutputs)))}
+	return nil
+}
+
+// Stops a run once XOR is solved
+var errSolved = errors.New("Solved")
+
+// Evolves XOR with each preset, which must solve it
+func TestXORPresets(t *testing.T) {
+	build := func(b *neat.SettingsBuilder) *neat.Settings {
+		s, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	for _, c := range []struct {
+		name     string
+		settings *neat.Settings
+	}{
+		{"xor", neat.SettingsForXOR()},
+		{"default", build(neat.NewSettings().InputCount(2))},
+		{"control", neat.SettingsForControl(2, 1)},
+		{"builder", build(neat.BuildFrom(neat.SettingsForXOR()).PopulationSize(200).MutateAddNode(0.05))},
+	} {
+		s := c.settings
+		s.Seed = 1
+		s.ArchiveFrequency = 0
+		s.Hooks.OnGenerationEnd = func(pop *neat.Population, stats *neat.Stats) error {
+			if stats.BestFitness > 0.95 {
+				return errSolved
+			}
+			return nil
+		}
+		best, pop, err := neat.Train(s, 300, decoder.NewCompiledFor(s), popeval.NewSerial(), xorEval{}, nil, nil)
+		switch {
+		case err == nil:
+			t.Errorf("%s: not solved in 300 generations", c.name)
+		case !errors.Is(err, errSolved):
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		t.Logf("%s: fitness %v after %d generations", c.name, best.Fitness, pop.Generation)
+		p, err := decoder.NewCompiledFor(s).Decode(best.Genome)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for i, in := range xorInputs {
+			out, err := p.Analyze(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Round(out[0]) != xorOutputs[i] {
+				t.Errorf("%s: champion of fitness %v gives %v for %v", c.name, best.Fitness, out[0], in)
+			}
+		}
+	}
+}
+
+// Evaluates XOR a batch at a time, counting the batches
+type xorBatchEval struct{ batches *int }
+
+func (e xorBatchEval) Evaluate(org *neat.Organism) error { return xorEval{}.Evaluate(org) }
+
+func (e xorBatchEval) EvaluateBatch(orgs []*neat.Organism) (fitness [][]float64, err error) {
+	*e.batches += 1
+	for _, o := range orgs {
+		if err = (xorEval{}).Evaluate(o); err != nil {
+			return
+		}
+		fitness = append(fitness, o.Fitness)
+	}
+	return
+}
+
+// Fine-tunes a partly evolved XOR champion. Runs with the same seed share
+// their course, so longer budgets show the fitness over the tuning, which
+// must never fall.
+func TestFineTuneXOR(t *testing.T) {
+	s := neat.SettingsForXOR()
+	s.Seed, s.ArchiveFrequency = 1, 0
+	d := decoder.NewCompiledFor(s)
+	champ, _, err := neat.Train(s, 15, d, popeval.NewSerial(), xorEval{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := champ.Copy()
+
+	last := champ.Fitness[0]
+	for _, budget := range []int{10, 50, 100, 200, 400, 800} {
+		batches := 0
+		tuned, err := neat.FineTune(champ, d, xorBatchEval{&batches}, neat.FineTuneOptions{Evaluations: budget,
+			Seed: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f := tuned.Fitness[0]; f < last {
+			t.Errorf("Fitness fell from %v to %v with a budget of %d", last, f, budget)
+		} else {
+			last = f
+		}
+		if batches != budget/10 {
+			t.Errorf("Budget of %d took %d batches", budget, batches)
+		}
+		if tuned.ID != champ.ID || len(tuned.Nodes) != len(champ.Nodes) || len(tuned.Conns) != len(champ.Conns) {
+			t.Fatalf("Tuning changed the organism to %v from %v", tuned, champ)
+		}
+		for k, cg := range tuned.Conns {
+			if c := champ.Conns[k]; c == nil || c.Source != cg.Source || c.Target != cg.Target ||
+				c.Enabled != cg.Enabled {
+				t.Fatalf("Tuning changed connection %d to %v", k, cg)
+			}
+		}
+	}
+	for k, cg := range before.Conns {
+		if w := champ.Conns[k].Weight; w != cg.Weight {
+			t.Errorf("Tuning changed the champion's connection %d from %v to %v", k, cg.Weight, w)
+		}
+	}
+	if last <= before.Fitness[0] {
+		t.Errorf("Tuning did not improve on the fitness %v", last)
+	}
+	t.Logf("Fitness %v tuned to %v", before.Fitness[0], last)
+}